@@ -0,0 +1,150 @@
+package gossdb_client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//  FlushDB 的确认口令
+const ConfirmFlushDB = "yes, delete all data"
+
+var (
+	// 调用 FlushDB 时没有传入正确的确认口令
+	ErrNotConfirmed = errors.New("gossdb_client: flushdb not confirmed")
+	// DbClient.Do 或 Pipeline.Do 执行了运维命令, 应当使用 DbClient.Admin
+	ErrAdminCommand = errors.New("gossdb_client: admin command, use DbClient.Admin")
+)
+
+//  删除数据或者改变服务端配置的命令, 只能通过 Admin 执行
+var adminCmds = map[string]bool{
+	"flushdb": true, "compact": true, "clear_binlog": true,
+	"set_key_range": true, "ignore_key_range": true,
+	"add_allow_ip": true, "del_allow_ip": true, "add_deny_ip": true, "del_deny_ip": true,
+}
+
+//  args 是运维命令时返回 ErrAdminCommand
+func checkNotAdmin(args []interface{}) error {
+	if len(args) == 0 {
+		return nil
+	}
+	if cmd := strings.ToLower(toCmd(args[0])); adminCmds[cmd] {
+		return fmt.Errorf("%w: %s", ErrAdminCommand, cmd)
+	}
+	return nil
+}
+
+//  运维命令, 通过 DbClient.Admin 获取. 这些命令会删除数据或者改变服务端的配置,
+//  单独放在这里, 避免业务代码误用
+type Admin struct {
+	c *DbClient
+}
+
+//  返回运维命令的接口, 命令在这个 client 上执行
+func (c *DbClient) Admin() *Admin {
+	return &Admin{c: c}
+}
+
+//  执行一条任意的命令, 包括运维命令, 用于命令行工具等需要执行原始命令的场合, 参见 DbClient.Do
+func (a *Admin) Do(args ...interface{}) ([]string, error) {
+	return a.c.do(args...)
+}
+
+//  返回 [ok] 的命令
+func (a *Admin) doOk(args ...interface{}) error {
+	resp, err := a.c.do(args...)
+	if err != nil {
+		return err
+	}
+	if len(resp) > 0 && resp[0] == "ok" {
+		return nil
+	}
+	return handError(resp, args[1:]...)
+}
+
+//  删除数据库中的所有数据, 包括 binlog. 在主库上执行时从库也会被清空
+//  confirm 确认口令, 必须是 ConfirmFlushDB, 否则返回 ErrNotConfirmed
+//  返回 err，可能的错误，操作成功返回 nil
+func (a *Admin) FlushDB(confirm string) error {
+	if confirm != ConfirmFlushDB {
+		return ErrNotConfirmed
+	}
+	return a.doOk("flushdb")
+}
+
+//  压缩整个数据库, 回收被删除的数据占用的空间. 数据库较大时需要很长时间, 期间服务端性能下降
+//  返回 err，可能的错误，操作成功返回 nil
+func (a *Admin) Compact() error {
+	return a.doOk("compact")
+}
+
+//  返回本实例负责的 kv 区间 (Start, End], 空字符串表示不限制
+//  返回 err，可能的错误，操作成功返回 nil
+func (a *Admin) GetKeyRange() (KeyRange, error) {
+	resp, err := a.c.do("get_key_range")
+	if err != nil {
+		return KeyRange{}, err
+	}
+	if len(resp) == 3 && resp[0] == "ok" {
+		return KeyRange{Start: resp[1], End: resp[2]}, nil
+	}
+	return KeyRange{}, handError(resp)
+}
+
+//  设置本实例负责的 kv 区间, 区间之外的 key 的读写会被拒绝, 用于集群
+//  r 区间 (Start, End], 空字符串表示不限制
+//  返回 err，可能的错误，操作成功返回 nil
+func (a *Admin) SetKeyRange(r KeyRange) error {
+	return a.doOk("set_key_range", r.Start, r.End)
+}
+
+//  让当前连接不受 SetKeyRange 的限制, 用于迁移数据. 只对执行命令的连接有效,
+//  应当在 DbClient 或 Pool.Get 借出的连接上使用
+//  返回 err，可能的错误，操作成功返回 nil
+func (a *Admin) IgnoreKeyRange() error {
+	return a.doOk("ignore_key_range")
+}
+
+//  删除所有的 binlog. 从库会因此无法增量同步, 需要重新全量复制
+//  返回 err，可能的错误，操作成功返回 nil
+func (a *Admin) ClearBinlog() error {
+	return a.doOk("clear_binlog")
+}
+
+//  返回 ip 列表的命令
+func (a *Admin) listIP(cmd string) ([]string, error) {
+	resp, err := a.c.do(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) > 0 && resp[0] == "ok" {
+		return resp[1:], nil
+	}
+	return nil, handError(resp)
+}
+
+//  返回允许连接的 ip 前缀, 为空时允许所有 ip
+//  返回 err，可能的错误，操作成功返回 nil
+func (a *Admin) ListAllowIP() ([]string, error) {
+	return a.listIP("list_allow_ip")
+}
+
+//  添加允许连接的 ip 前缀, 如 127.0.0.1 或 192.168.
+//  ip ip 前缀, all 表示所有 ip
+//  返回 err，可能的错误，操作成功返回 nil
+func (a *Admin) AddAllowIP(ip string) error {
+	return a.doOk("add_allow_ip", ip)
+}
+
+//  删除允许连接的 ip 前缀
+//  ip ip 前缀
+//  返回 err，可能的错误，操作成功返回 nil
+func (a *Admin) DelAllowIP(ip string) error {
+	return a.doOk("del_allow_ip", ip)
+}
+
+//  返回禁止连接的 ip 前缀
+//  返回 err，可能的错误，操作成功返回 nil
+func (a *Admin) ListDenyIP() ([]string, error) {
+	return a.listIP("list_deny_ip")
+}
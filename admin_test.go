@@ -0,0 +1,93 @@
+package gossdb_client
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestAdmin(t *testing.T) {
+	db, _ := newTestClient(t)
+	admin := db.Admin()
+
+	db.Set("a", "1")
+	if err := admin.FlushDB("yes"); !errors.Is(err, ErrNotConfirmed) {
+		t.Fatalf("FlushDB without confirmation = %v", err)
+	}
+	if ok, _ := db.Exists("a"); !ok {
+		t.Fatal("data flushed without confirmation")
+	}
+	if err := admin.FlushDB(ConfirmFlushDB); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := db.Exists("a"); ok {
+		t.Fatal("a exists after FlushDB")
+	}
+
+	if err := admin.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.ClearBinlog(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := admin.SetKeyRange(KeyRange{"a", "m"}); err != nil {
+		t.Fatal(err)
+	}
+	if r, err := admin.GetKeyRange(); err != nil || r != (KeyRange{"a", "m"}) {
+		t.Fatalf("GetKeyRange = %+v, %v", r, err)
+	}
+	if info, _ := db.Info(); info.ServKeyRange.KV != (KeyRange{"a", "m"}) {
+		t.Fatalf("ServKeyRange = %+v", info.ServKeyRange)
+	}
+	if err := admin.IgnoreKeyRange(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ip := range []string{"192.168.", "127.0.0.1", "192.168."} {
+		if err := admin.AddAllowIP(ip); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := admin.DelAllowIP("192.168."); err != nil {
+		t.Fatal(err)
+	}
+	if ips, err := admin.ListAllowIP(); err != nil || !reflect.DeepEqual(ips, []string{"127.0.0.1"}) {
+		t.Fatalf("ListAllowIP = %v, %v", ips, err)
+	}
+	if ips, err := admin.ListDenyIP(); err != nil || len(ips) != 0 {
+		t.Fatalf("ListDenyIP = %v, %v", ips, err)
+	}
+}
+
+func TestAdminCommandsRefused(t *testing.T) {
+	db, _ := newTestClient(t)
+	db.Set("a", "1")
+
+	if _, err := db.Do("FLUSHDB"); !errors.Is(err, ErrAdminCommand) {
+		t.Fatalf("Do(flushdb) err = %v", err)
+	}
+	p := db.Pipeline()
+	p.Set("b", "2")
+	flush := p.Do("flushdb")
+	if _, err := p.Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if err := flush.Err(); !errors.Is(err, ErrAdminCommand) {
+		t.Fatalf("Pipeline.Do(flushdb) err = %v", err)
+	}
+	if keys, _ := db.Keys("", "", -1); len(keys) != 2 {
+		t.Fatalf("keys = %v, admin command was executed", keys)
+	}
+	// 其它命令不受影响
+	if resp, err := db.Do("get", "a"); err != nil || resp[1] != "1" {
+		t.Fatalf("Do(get) = %v, %v", resp, err)
+	}
+
+	if _, err := db.Admin().Do("flushdb"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := db.Exists("a"); ok {
+		t.Fatal("a exists after Admin().Do(flushdb)")
+	}
+}
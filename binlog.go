@@ -0,0 +1,325 @@
+package gossdb_client
+
+import (
+	"context"
+	"encoding/binary"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//  binlog 事件的类型
+type BinlogEventType int
+
+const (
+	// 心跳, 没有新数据时服务端定期发送
+	BinlogNoop BinlogEventType = iota
+	BinlogSet
+	BinlogDel
+	BinlogHSet
+	BinlogHDel
+	BinlogZSet
+	BinlogZDel
+	BinlogQPushBack
+	BinlogQPushFront
+	BinlogQPopBack
+	BinlogQPopFront
+	BinlogQSet
+	// 全量复制开始, 之后的事件是数据库的快照, 消费方应当丢弃之前同步的数据
+	BinlogCopyBegin
+	// 全量复制结束, 之后是增量的事件
+	BinlogCopyEnd
+	// 无法识别的命令
+	BinlogUnknown
+)
+
+var binlogEventNames = [...]string{
+	"noop", "set", "del", "hset", "hdel", "zset", "zdel",
+	"qpush_back", "qpush_front", "qpop_back", "qpop_front", "qset",
+	"copy_begin", "copy_end", "unknown",
+}
+
+func (t BinlogEventType) String() string {
+	if t >= 0 && int(t) < len(binlogEventNames) {
+		return binlogEventNames[t]
+	}
+	return "BinlogEventType(" + strconv.Itoa(int(t)) + ")"
+}
+
+//  ssdb binlog 头部中的类型和命令, 参见 ssdb 的 binlog.h
+const (
+	binlogTypeNoop   = 0
+	binlogTypeSync   = 1
+	binlogTypeMirror = 2
+	binlogTypeCopy   = 3
+
+	binlogCmdKSet       = 1
+	binlogCmdKDel       = 2
+	binlogCmdHSet       = 3
+	binlogCmdHDel       = 4
+	binlogCmdZSet       = 5
+	binlogCmdZDel       = 6
+	binlogCmdBegin      = 7
+	binlogCmdEnd        = 8
+	binlogCmdQPushBack  = 10
+	binlogCmdQPushFront = 11
+	binlogCmdQPopBack   = 12
+	binlogCmdQPopFront  = 13
+	binlogCmdQSet       = 14
+
+	// seq(8 字节, 小端) + type + cmd
+	binlogHeaderLen = 10
+)
+
+var binlogCmdEvents = map[byte]BinlogEventType{
+	binlogCmdKSet: BinlogSet, binlogCmdKDel: BinlogDel,
+	binlogCmdHSet: BinlogHSet, binlogCmdHDel: BinlogHDel,
+	binlogCmdZSet: BinlogZSet, binlogCmdZDel: BinlogZDel,
+	binlogCmdQPushBack: BinlogQPushBack, binlogCmdQPushFront: BinlogQPushFront,
+	binlogCmdQPopBack: BinlogQPopBack, binlogCmdQPopFront: BinlogQPopFront,
+	binlogCmdQSet: BinlogQSet,
+}
+
+//  一个 binlog 事件
+type BinlogEvent struct {
+	Seq  uint64
+	Type BinlogEventType
+	// 全量复制阶段的事件, 此时 Seq 是复制开始时的序号
+	Copy bool
+	// hashmap、zset 或队列的名字, kv 事件为空
+	Name string
+	// kv 的 key, hashmap 或 zset 中的 key
+	Key string
+	// 队列元素在 ssdb 内部的序号, 只对队列事件有效
+	Index uint64
+	// set、hset、qpush、qset 的值, zset 的权重(字符串形式); 删除和弹出事件为空
+	Value string
+	// ssdb 内部编码的 key, 用于记录同步位置
+	RawKey string
+}
+
+//  同步的位置, Seq 为已经处理的最后一个 binlog 的序号, 全量复制中断时 Key 为已经复制的最后一个 key(内部编码).
+//  零值表示从头开始, 服务端先发送全量复制再发送增量
+type BinlogPosition struct {
+	Seq uint64
+	Key string
+}
+
+//  binlog 的同步选项
+type BinlogOptions struct {
+	// 开始同步的位置, 通常是上次 Position 的返回值
+	From BinlogPosition
+	// 为 true 时以 mirror 方式同步, 服务端同时发送其它主库同步过来的数据, 用于双主
+	Mirror bool
+}
+
+//  以从库的身份连接 ssdb, 用 sync140 命令读取 binlog 并解码为事件, 用于数据变更订阅.
+//  使用单独的连接, 不能被多个 goroutine 同时使用. 用法:
+//
+//	r, err := gossdb_client.NewBinlogReader(ip, port, pwd, &gossdb_client.BinlogOptions{From: saved})
+//	for {
+//		ev, err := r.Next(ctx)
+//		if err != nil {
+//			// 重新创建 reader, 从 r.Position() 继续
+//		}
+//		...
+//		saved = r.Position()
+//	}
+type BinlogReader struct {
+	ip       string
+	port     int
+	password string
+
+	c   *DbClient
+	pos BinlogPosition
+
+	mu      sync.Mutex
+	info    *DbClient // 用于 Lag 的连接
+	lastAt  time.Time
+	lastSeq uint64
+}
+
+//  连接 ssdb 并从 opts.From 开始同步
+//  opts 可以为 nil, 表示从头开始
+//  返回 err，可能的错误，操作成功返回 nil
+func NewBinlogReader(ip string, port int, password string, opts *BinlogOptions) (*BinlogReader, error) {
+	var o BinlogOptions
+	if opts != nil {
+		o = *opts
+	}
+	c, err := NewDbClient(ip, port, password)
+	if err != nil {
+		return nil, err
+	}
+	typ := "sync"
+	if o.Mirror {
+		typ = "mirror"
+	}
+	// 服务端不回复握手, 直接开始发送 binlog
+	args := []interface{}{"sync140", strconv.FormatUint(o.From.Seq, 10), o.From.Key, typ}
+	if err = c.Client.Send(args...); err != nil {
+		c.CloseDbClient()
+		return nil, newCommandError(args, err)
+	}
+	return &BinlogReader{ip: ip, port: port, password: password, c: c, pos: o.From}, nil
+}
+
+//  读取下一个事件, 没有新的数据时阻塞到有数据或者 ctx 取消, 期间会收到 BinlogNoop 心跳.
+//  出错(包括 ctx 取消)后 reader 不能继续使用, 需要关闭后从 Position 重新创建
+//  返回 err，可能的错误，操作成功返回 nil
+func (r *BinlogReader) Next(ctx context.Context) (*BinlogEvent, error) {
+	resp, err := r.c.Client.RecvContext(ctx)
+	if err != nil {
+		return nil, newCommandError([]interface{}{"sync140"}, err)
+	}
+	if len(resp) == 0 || len(resp[0]) < binlogHeaderLen {
+		// 握手失败时服务端回复普通的错误响应, 例如 noauth
+		if err = respError([]interface{}{"sync140"}, resp); err == nil {
+			err = handError(resp, "sync140")
+		}
+		return nil, err
+	}
+	ev := decodeBinlog(resp)
+
+	switch {
+	case ev.Type == BinlogCopyBegin:
+		r.pos = BinlogPosition{Seq: ev.Seq}
+	case ev.Type == BinlogCopyEnd:
+		r.pos = BinlogPosition{Seq: ev.Seq}
+	case ev.Copy:
+		r.pos = BinlogPosition{Seq: ev.Seq, Key: ev.RawKey}
+	case ev.Type != BinlogNoop:
+		r.pos = BinlogPosition{Seq: ev.Seq}
+	}
+	r.mu.Lock()
+	r.lastAt = time.Now()
+	r.lastSeq = r.pos.Seq
+	r.mu.Unlock()
+	return ev, nil
+}
+
+//  返回已经读取的位置, 保存后用于下次 NewBinlogReader 时继续同步
+func (r *BinlogReader) Position() BinlogPosition {
+	return r.pos
+}
+
+//  同步的延迟
+type BinlogLag struct {
+	// 服务端最新的 binlog 序号
+	MaxSeq uint64
+	// 已经读取的序号
+	Seq uint64
+	// 落后的 binlog 个数
+	Behind int64
+	// 距离读取上一个事件(包括心跳)的时间, 持续增长说明连接可能已经中断
+	Idle time.Duration
+}
+
+//  查询服务端最新的 binlog 序号并计算落后的程度. 使用另一个连接, 可以在其它 goroutine 中调用
+//  返回 err，可能的错误，操作成功返回 nil
+func (r *BinlogReader) Lag(ctx context.Context) (BinlogLag, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.info == nil {
+		c, err := NewDbClient(r.ip, r.port, r.password)
+		if err != nil {
+			return BinlogLag{}, err
+		}
+		r.info = c
+	}
+	info, err := r.info.WithContext(ctx).Info()
+	if err != nil {
+		r.info.CloseDbClient()
+		r.info = nil
+		return BinlogLag{}, err
+	}
+	lag := BinlogLag{MaxSeq: uint64(info.Binlog.MaxSeq), Seq: r.lastSeq}
+	if lag.MaxSeq > lag.Seq {
+		lag.Behind = int64(lag.MaxSeq - lag.Seq)
+	}
+	if !r.lastAt.IsZero() {
+		lag.Idle = time.Since(r.lastAt)
+	}
+	return lag, nil
+}
+
+//  关闭连接
+func (r *BinlogReader) Close() error {
+	r.mu.Lock()
+	if r.info != nil {
+		r.info.CloseDbClient()
+		r.info = nil
+	}
+	r.mu.Unlock()
+	return r.c.CloseDbClient()
+}
+
+//  第一个字段是 binlog 头部和内部编码的 key, 第二个字段(如果有)是值
+func decodeBinlog(resp []string) *BinlogEvent {
+	h := resp[0]
+	ev := &BinlogEvent{
+		Seq:    binary.LittleEndian.Uint64([]byte(h[:8])),
+		RawKey: h[binlogHeaderLen:],
+	}
+	if len(resp) > 1 {
+		ev.Value = resp[1]
+	}
+	typ, cmd := h[8], h[9]
+	ev.Copy = typ == binlogTypeCopy
+	switch {
+	case typ == binlogTypeNoop:
+		ev.Type = BinlogNoop
+		ev.RawKey = ""
+		return ev
+	case cmd == binlogCmdBegin:
+		ev.Type = BinlogCopyBegin
+		return ev
+	case cmd == binlogCmdEnd:
+		ev.Type = BinlogCopyEnd
+		return ev
+	}
+	t, ok := binlogCmdEvents[cmd]
+	if !ok {
+		ev.Type = BinlogUnknown
+		return ev
+	}
+	ev.Type = t
+	ev.Name, ev.Key, ev.Index = decodeBinlogKey(ev.RawKey)
+	return ev
+}
+
+//  ssdb 内部的 key 编码, 参见 ssdb 的 t_kv.h、t_hash.h、t_zset.h、t_queue.h:
+//  kv 为 'k' + key; hashmap 为 'h' + 名字长度(1 字节) + 名字 + '=' + key;
+//  zset 为 's' + 名字长度(1 字节) + 名字 + key 长度(1 字节) + key;
+//  队列元素为 'q' + 名字长度(1 字节) + 名字 + 序号(8 字节, 大端).
+//  格式不对时原样作为 key 返回
+func decodeBinlogKey(raw string) (name, key string, index uint64) {
+	if len(raw) == 0 {
+		return "", "", 0
+	}
+	switch raw[0] {
+	case 'k':
+		return "", raw[1:], 0
+	case 'h', 's', 'q':
+		if len(raw) < 2 || len(raw) < 2+int(raw[1]) {
+			return "", raw, 0
+		}
+		n := int(raw[1])
+		name, rest := raw[2:2+n], raw[2+n:]
+		switch raw[0] {
+		case 'h':
+			if len(rest) > 0 && rest[0] == '=' {
+				return name, rest[1:], 0
+			}
+		case 's':
+			if len(rest) > 0 && len(rest) == 1+int(rest[0]) {
+				return name, rest[1:], 0
+			}
+		case 'q':
+			if len(rest) == 8 {
+				return name, "", binary.BigEndian.Uint64([]byte(rest))
+			}
+		}
+	}
+	return "", raw, 0
+}
@@ -0,0 +1,137 @@
+package gossdb_client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBinlogReader(t *testing.T) {
+	db, s := newTestClient(t)
+	db.Set("a", "1")
+	db.HSet("h", "f", "v")
+	db.ZSet("z", "m", 5)
+	db.QPush("q", "x")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	r, err := NewBinlogReader(s.Host(), s.Port(), testPassword, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	next := func() *BinlogEvent {
+		t.Helper()
+		for {
+			ev, err := r.Next(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ev.Type != BinlogNoop {
+				return ev
+			}
+		}
+	}
+
+	// 从头开始时先收到全量复制, 按内部编码的 key 排序
+	if ev := next(); ev.Type != BinlogCopyBegin {
+		t.Fatalf("first event = %+v", ev)
+	}
+	want := []BinlogEvent{
+		{Type: BinlogHSet, Name: "h", Key: "f", Value: "v"},
+		{Type: BinlogSet, Key: "a", Value: "1"},
+		{Type: BinlogQPushBack, Name: "q", Value: "x"},
+		{Type: BinlogZSet, Name: "z", Key: "m", Value: "5"},
+	}
+	for _, w := range want {
+		ev := next()
+		if !ev.Copy || ev.Type != w.Type || ev.Name != w.Name || ev.Key != w.Key || ev.Value != w.Value {
+			t.Fatalf("copy event = %+v, want %+v", ev, w)
+		}
+		if r.Position().Key != ev.RawKey {
+			t.Fatalf("position during copy = %+v", r.Position())
+		}
+	}
+	if ev := next(); ev.Type != BinlogCopyEnd {
+		t.Fatalf("event after copy = %+v", ev)
+	}
+	copied := r.Position()
+	if copied.Key != "" || copied.Seq != s.LastSeq() {
+		t.Fatalf("position after copy = %+v, last seq %d", copied, s.LastSeq())
+	}
+
+	db.Set("b", "2")
+	db.Del("a")
+	db.MultiHDel("h", "f")
+	db.ZDel("z", "m")
+	db.QPushFront("q", "y")
+	db.QPopFront("q")
+	want = []BinlogEvent{
+		{Type: BinlogSet, Key: "b", Value: "2"},
+		{Type: BinlogDel, Key: "a"},
+		{Type: BinlogHDel, Name: "h", Key: "f"},
+		{Type: BinlogZDel, Name: "z", Key: "m"},
+		{Type: BinlogQPushFront, Name: "q", Value: "y"},
+		{Type: BinlogQPopFront, Name: "q"},
+	}
+	for i, w := range want {
+		ev := next()
+		if ev.Copy || ev.Seq != copied.Seq+uint64(i)+1 || ev.Type != w.Type || ev.Name != w.Name || ev.Key != w.Key || ev.Value != w.Value {
+			t.Fatalf("event %d = %+v, want %+v", i, ev, w)
+		}
+	}
+
+	lag, err := r.Lag(ctx)
+	if err != nil || lag.Behind != 0 || lag.MaxSeq != s.LastSeq() {
+		t.Fatalf("Lag = %+v, %v", lag, err)
+	}
+	db.Set("c", "3")
+	db.Set("d", "4")
+	if lag, _ = r.Lag(ctx); lag.Behind != 2 {
+		t.Fatalf("Lag = %+v", lag)
+	}
+
+	// 从保存的位置继续, 不再全量复制
+	pos := r.Position()
+	r.Close()
+	r, err = NewBinlogReader(s.Host(), s.Port(), testPassword, &BinlogOptions{From: pos})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev := next(); ev.Type != BinlogSet || ev.Key != "c" || ev.Seq != pos.Seq+1 {
+		t.Fatalf("resumed event = %+v", ev)
+	}
+
+	// 取消 ctx 时 Next 立即返回
+	cctx, ccancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer ccancel()
+	next()
+	if _, err = r.Next(cctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Next after deadline = %v", err)
+	}
+}
+
+func TestDecodeBinlogKey(t *testing.T) {
+	tests := []struct {
+		raw, name, key string
+		index          uint64
+	}{
+		{"ka", "", "a", 0},
+		{"h\x04user=name", "user", "name", 0},
+		{"h\x04user==", "user", "=", 0},
+		{"s\x04rank\x03bob", "rank", "bob", 0},
+		{"q\x01q\x00\x00\x00\x00\x00\x00\x01\x02", "q", "", 258},
+		// 格式不对时原样返回
+		{"h\x04username", "", "h\x04username", 0},
+		{"s\x04rank\x05bob", "", "s\x04rank\x05bob", 0},
+		{"s\x09rank", "", "s\x09rank", 0},
+	}
+	for _, tt := range tests {
+		name, key, index := decodeBinlogKey(tt.raw)
+		if name != tt.name || key != tt.key || index != tt.index {
+			t.Errorf("decodeBinlogKey(%q) = %q, %q, %d", tt.raw, name, key, index)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package gossdb_client
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/houbin910902/gossdb_client/gossdb/ssdb"
+)
+
+//  ssdb 中一个字符串最多 2^30 位
+const maxBloomBits = 1 << 30
+
+//  可扩展布隆过滤器的选项
+type BloomFilterOptions struct {
+	// 元素个数超过容量时在后面追加新的过滤器, 而不是让误判率变高
+	Scalable bool
+	// 每个新的过滤器的容量是前一个的多少倍, 默认 2
+	Growth int64
+	// 每个新的过滤器的误判率是前一个的多少倍, 默认 0.5. 所有过滤器的误判率之和不超过创建时指定的误判率
+	Tightening float64
+}
+
+//  基于 setbit/getbit 的布隆过滤器, 每个元素的 k 个位用一次 pipeline 读写.
+//  k 个位置由两个哈希值组合得到 (h1 + i*h2) mod m, 效果与 k 个独立的哈希函数相当.
+//
+//  普通的过滤器使用一个字符串 name. 可扩展的过滤器使用字符串 name:0, name:1, ... 和保存元数据的 hashmap name:meta,
+//  其中 grown:i 字段表示已经追加了第 i 个过滤器, count:i 字段为第 i 个过滤器中的元素个数; 新的元素加入最后一个过滤器,
+//  元素个数达到容量时追加一个容量更大、误判率更低的过滤器. 批量加入时同一批元素都加入同一个过滤器, 可能略微超过容量.
+//  追加过滤器是幂等的 hset, 多个请求同时追加时只会追加一个, 追加失败时下一次加入元素会再次追加.
+//
+//  c 应当可以被多个 goroutine 同时使用, 例如 Pool.Client(). 用法:
+//
+//	bf, err := db.NewBloomFilter("seen:urls", 1000000, 0.001, nil)
+//	if err != nil {
+//		return err
+//	}
+//	bf.Add(ctx, url)
+//	ok, err := bf.Test(ctx, url)
+type BloomFilter struct {
+	c        *DbClient
+	name     string
+	capacity int64
+	fpRate   float64
+	opts     BloomFilterOptions
+}
+
+//  根据预计的元素个数 n 和误判率 p 计算需要的位数和哈希函数的个数
+//  返回 err，n 不大于 0 或者 p 不在 (0, 1) 之间时返回 ssdb.ErrBadArguments
+func BloomFilterSize(n int64, p float64) (bits int64, hashes int, err error) {
+	if err = checkBloomParams(n, p); err != nil {
+		return 0, 0, err
+	}
+	bits, hashes = bloomSize(n, p)
+	return bits, hashes, nil
+}
+
+//  BloomFilterSize 的计算部分, 参数已经检查过
+func bloomSize(n int64, p float64) (bits int64, hashes int) {
+	m := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	bits = int64(math.Min(math.Max(m, 8), maxBloomBits))
+	hashes = int(math.Max(1, math.Round(float64(bits)/float64(n)*math.Ln2)))
+	return bits, hashes
+}
+
+//  创建布隆过滤器, 不会访问服务端
+//  name 过滤器的名字
+//  capacity 预计的元素个数, 可扩展的过滤器为第一个过滤器的容量, 必须大于 0
+//  fpRate 误判率, 例如 0.01, 必须在 (0, 1) 之间. 位数超过 2^30 时截断, 实际的误判率会更高
+//  opts 选项, 可以为 nil
+//  返回 err，capacity 或 fpRate 不合法时返回 ssdb.ErrBadArguments
+func (c *DbClient) NewBloomFilter(name string, capacity int64, fpRate float64, opts *BloomFilterOptions) (*BloomFilter, error) {
+	if err := checkBloomParams(capacity, fpRate); err != nil {
+		return nil, err
+	}
+	var o BloomFilterOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Growth <= 0 {
+		o.Growth = 2
+	}
+	if o.Tightening <= 0 || o.Tightening >= 1 {
+		o.Tightening = 0.5
+	}
+	return &BloomFilter{c: c, name: name, capacity: capacity, fpRate: fpRate, opts: o}, nil
+}
+
+func checkBloomParams(capacity int64, fpRate float64) error {
+	if capacity <= 0 {
+		return fmt.Errorf("%w: bloom filter capacity must be positive, got %d", ssdb.ErrBadArguments, capacity)
+	}
+	if !(fpRate > 0 && fpRate < 1) {
+		return fmt.Errorf("%w: bloom filter fpRate must be in (0, 1), got %v", ssdb.ErrBadArguments, fpRate)
+	}
+	return nil
+}
+
+//  第 i 个过滤器的参数
+type bloomLayer struct {
+	key      string
+	capacity int64
+	bits     int64
+	hashes   int
+}
+
+func (bf *BloomFilter) layer(i int) bloomLayer {
+	if !bf.opts.Scalable {
+		bits, hashes := bloomSize(bf.capacity, bf.fpRate)
+		return bloomLayer{key: bf.name, capacity: bf.capacity, bits: bits, hashes: hashes}
+	}
+	capacity := bf.capacity
+	for j := 0; j < i && capacity < math.MaxInt64/bf.opts.Growth; j++ {
+		capacity *= bf.opts.Growth
+	}
+	// 第 i 个过滤器的误判率为 p*(1-r)*r^i, 总和不超过 p
+	p := bf.fpRate * (1 - bf.opts.Tightening) * math.Pow(bf.opts.Tightening, float64(i))
+	bits, hashes := bloomSize(capacity, p)
+	return bloomLayer{key: bf.name + ":" + strconv.Itoa(i), capacity: capacity, bits: bits, hashes: hashes}
+}
+
+//  元素在过滤器中的位置
+func (l bloomLayer) offsets(h1, h2 uint64) []int64 {
+	offsets := make([]int64, l.hashes)
+	for i := range offsets {
+		offsets[i] = int64((h1 + uint64(i)*h2) % uint64(l.bits))
+	}
+	return offsets
+}
+
+func bloomHash(item string) (h1, h2 uint64) {
+	h := fnv.New128a()
+	h.Write([]byte(item))
+	sum := h.Sum(nil)
+	// h2 为奇数, 避免 h2 为 0 时所有位置相同
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:]) | 1
+}
+
+func (bf *BloomFilter) meta() string {
+	return bf.name + ":meta"
+}
+
+//  返回过滤器的个数, 普通的过滤器总是 1
+func (bf *BloomFilter) filters(c *DbClient) (int, error) {
+	if !bf.opts.Scalable {
+		return 1, nil
+	}
+	// grown:i 字段总是从 1 开始连续的: 只有看到 i 个过滤器的请求才会追加 grown:i
+	grown, err := c.HKeys(bf.meta(), "grown:", "grown;", -1)
+	if err != nil {
+		return 0, err
+	}
+	return 1 + len(grown), nil
+}
+
+//  加入一个元素
+//  返回 added, 元素之前不在过滤器中; 误判时为 false
+//  返回 err，可能的错误，操作成功返回 nil
+func (bf *BloomFilter) Add(ctx context.Context, item string) (bool, error) {
+	added, err := bf.AddMulti(ctx, item)
+	if err != nil {
+		return false, err
+	}
+	return added[0], nil
+}
+
+//  判断元素是否在过滤器中, 可能误判为存在, 不会误判为不存在
+//  返回 err，可能的错误，操作成功返回 nil
+func (bf *BloomFilter) Test(ctx context.Context, item string) (bool, error) {
+	found, err := bf.TestMulti(ctx, item)
+	if err != nil {
+		return false, err
+	}
+	return found[0], nil
+}
+
+//  批量加入元素, 所有的位操作在一次 pipeline 中完成
+//  返回 added, 与 items 一一对应, 元素之前是否不在过滤器中
+//  返回 err，可能的错误，操作成功返回 nil
+func (bf *BloomFilter) AddMulti(ctx context.Context, items ...string) ([]bool, error) {
+	c := bf.c.WithContext(ctx)
+	n, err := bf.filters(c)
+	if err != nil {
+		return nil, err
+	}
+	layers := make([]bloomLayer, n)
+	for i := range layers {
+		layers[i] = bf.layer(i)
+	}
+	last := layers[n-1]
+
+	// 检查之前的过滤器, 同时在最后一个过滤器中设置位, setbit 返回的原值表示元素是否已经存在.
+	// 为了只用一次 pipeline, 已经在之前的过滤器中的元素也会设置位, 但不计入元素个数
+	p := c.Pipeline()
+	older := make([][]*Reply, len(items))
+	sets := make([][]*Reply, len(items))
+	for i, item := range items {
+		h1, h2 := bloomHash(item)
+		for _, l := range layers[:n-1] {
+			for _, off := range l.offsets(h1, h2) {
+				older[i] = append(older[i], p.GetBit(l.key, off))
+			}
+		}
+		for _, off := range last.offsets(h1, h2) {
+			sets[i] = append(sets[i], p.SetBit(last.key, off, 1))
+		}
+	}
+	if _, err = p.Exec(); err != nil {
+		return nil, err
+	}
+
+	added := make([]bool, len(items))
+	var count int64
+	for i := range items {
+		found, err := bloomFound(sets[i])
+		if err != nil {
+			return nil, err
+		}
+		if !found && n > 1 {
+			if found, err = bloomFoundInLayers(older[i], layers[:n-1]); err != nil {
+				return nil, err
+			}
+		}
+		added[i] = !found
+		if !found {
+			count++
+		}
+	}
+	if !bf.opts.Scalable || count == 0 {
+		return added, nil
+	}
+
+	field := "count:" + strconv.Itoa(n-1)
+	total, err := c.HIncR(bf.meta(), field, count)
+	if err != nil {
+		return nil, err
+	}
+	// 元素个数达到容量时追加第 n 个过滤器. hset 是幂等的: 同时追加的请求写入同一个字段,
+	// 过时的请求写入已经存在的字段, 追加失败时之后的请求会再次追加
+	if total >= last.capacity {
+		if err = c.HSet(bf.meta(), "grown:"+strconv.Itoa(n), 1); err != nil {
+			return nil, err
+		}
+	}
+	return added, nil
+}
+
+//  批量判断元素是否在过滤器中, 所有的位操作在一次 pipeline 中完成
+//  返回 found, 与 items 一一对应
+//  返回 err，可能的错误，操作成功返回 nil
+func (bf *BloomFilter) TestMulti(ctx context.Context, items ...string) ([]bool, error) {
+	c := bf.c.WithContext(ctx)
+	n, err := bf.filters(c)
+	if err != nil {
+		return nil, err
+	}
+	layers := make([]bloomLayer, n)
+	for i := range layers {
+		layers[i] = bf.layer(i)
+	}
+	p := c.Pipeline()
+	bits := make([][]*Reply, len(items))
+	for i, item := range items {
+		h1, h2 := bloomHash(item)
+		for _, l := range layers {
+			for _, off := range l.offsets(h1, h2) {
+				bits[i] = append(bits[i], p.GetBit(l.key, off))
+			}
+		}
+	}
+	if _, err = p.Exec(); err != nil {
+		return nil, err
+	}
+	found := make([]bool, len(items))
+	for i := range items {
+		if found[i], err = bloomFoundInLayers(bits[i], layers); err != nil {
+			return nil, err
+		}
+	}
+	return found, nil
+}
+
+//  所有的位都为 1 时元素存在
+func bloomFound(bits []*Reply) (bool, error) {
+	found := true
+	for _, r := range bits {
+		v, err := r.Int64()
+		if err != nil {
+			return false, err
+		}
+		if v == 0 {
+			found = false
+		}
+	}
+	return found, nil
+}
+
+//  bits 依次为每个过滤器的位, 在任意一个过滤器中存在即存在
+func bloomFoundInLayers(bits []*Reply, layers []bloomLayer) (bool, error) {
+	for _, l := range layers {
+		found, err := bloomFound(bits[:l.hashes])
+		if err != nil || found {
+			return found, err
+		}
+		bits = bits[l.hashes:]
+	}
+	return false, nil
+}
+
+//  返回可扩展的过滤器中的元素个数, 误判为已经存在的元素不计入; 普通的过滤器不记录元素个数, 总是返回 0
+//  返回 err，可能的错误，操作成功返回 nil
+func (bf *BloomFilter) Count(ctx context.Context) (int64, error) {
+	if !bf.opts.Scalable {
+		return 0, nil
+	}
+	m, err := bf.c.WithContext(ctx).HGetAll(bf.meta())
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for k, v := range m {
+		if strings.HasPrefix(k, "count:") {
+			n, _ := strconv.ParseInt(v, 10, 64)
+			total += n
+		}
+	}
+	return total, nil
+}
+
+//  删除过滤器的所有数据
+//  返回 err，可能的错误，操作成功返回 nil
+func (bf *BloomFilter) Clear(ctx context.Context) error {
+	c := bf.c.WithContext(ctx)
+	n, err := bf.filters(c)
+	if err != nil {
+		return err
+	}
+	p := c.Pipeline()
+	for i := 0; i < n; i++ {
+		p.Del(bf.layer(i).key)
+	}
+	if bf.opts.Scalable {
+		p.Do("hclear", bf.meta())
+	}
+	replies, err := p.Exec()
+	if err != nil {
+		return err
+	}
+	for _, r := range replies {
+		if err = r.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
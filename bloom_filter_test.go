@@ -0,0 +1,185 @@
+package gossdb_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/houbin910902/gossdb_client/gossdb/ssdb"
+)
+
+func newTestBloomFilter(t *testing.T, db *DbClient, capacity int64, opts *BloomFilterOptions) *BloomFilter {
+	bf, err := db.NewBloomFilter("bf", capacity, 0.01, opts)
+	if err != nil {
+		t.Fatalf("NewBloomFilter fail err: %v", err)
+	}
+	return bf
+}
+
+func TestBloomFilterSize(t *testing.T) {
+	if bits, hashes, err := BloomFilterSize(1000, 0.01); err != nil || bits != 9586 || hashes != 7 {
+		t.Errorf("BloomFilterSize(1000, 0.01) = %d, %d, %v", bits, hashes, err)
+	}
+	if bits, _, err := BloomFilterSize(1<<40, 0.001); err != nil || bits != maxBloomBits {
+		t.Errorf("bits not capped: %d, %v", bits, err)
+	}
+	for _, tt := range []struct {
+		n int64
+		p float64
+	}{{0, 0.01}, {-1, 0.01}, {100, 0}, {100, 1}, {100, -0.5}, {100, math.NaN()}} {
+		if _, _, err := BloomFilterSize(tt.n, tt.p); !errors.Is(err, ssdb.ErrBadArguments) {
+			t.Errorf("BloomFilterSize(%d, %v) err = %v, want ErrBadArguments", tt.n, tt.p, err)
+		}
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	db, _ := newTestClient(t)
+	ctx := context.Background()
+	bf := newTestBloomFilter(t, db, 1000, nil)
+
+	if added, err := bf.Add(ctx, "a"); err != nil || !added {
+		t.Fatalf("Add = %v, %v", added, err)
+	}
+	if added, _ := bf.Add(ctx, "a"); added {
+		t.Error("second Add should return false")
+	}
+	added, err := bf.AddMulti(ctx, "x", "y", "x", "a")
+	if err != nil || fmt.Sprint(added) != "[true true false false]" {
+		t.Errorf("AddMulti = %v, %v", added, err)
+	}
+	found, err := bf.TestMulti(ctx, "a", "x", "y", "b")
+	if err != nil || fmt.Sprint(found) != "[true true true false]" {
+		t.Errorf("TestMulti = %v, %v", found, err)
+	}
+
+	// 达到容量时的误判率
+	items := make([]string, 1000)
+	for i := range items {
+		items[i] = fmt.Sprint("item:", i)
+	}
+	bf.AddMulti(ctx, items...)
+	others := make([]string, 2000)
+	for i := range others {
+		others[i] = fmt.Sprint("other:", i)
+	}
+	found, _ = bf.TestMulti(ctx, others...)
+	fp := 0
+	for _, f := range found {
+		if f {
+			fp++
+		}
+	}
+	if fp > 60 {
+		t.Errorf("%d false positives of %d", fp, len(others))
+	}
+
+	if err = bf.Clear(ctx); err != nil {
+		t.Fatalf("Clear fail err: %v", err)
+	}
+	if ok, _ := bf.Test(ctx, "a"); ok {
+		t.Error("Test after Clear should return false")
+	}
+}
+
+func TestBloomFilterScalable(t *testing.T) {
+	db, _ := newTestClient(t)
+	ctx := context.Background()
+	bf := newTestBloomFilter(t, db, 100, &BloomFilterOptions{Scalable: true})
+
+	var total int64
+	for i := 0; i < 700; i += 10 {
+		batch := make([]string, 10)
+		for j := range batch {
+			batch[j] = fmt.Sprint("item:", i+j)
+		}
+		added, err := bf.AddMulti(ctx, batch...)
+		if err != nil {
+			t.Fatalf("AddMulti fail err: %v", err)
+		}
+		for _, a := range added {
+			if a {
+				total++
+			}
+		}
+	}
+	// 容量依次为 100, 200, 400
+	if grown, _ := db.HKeys("bf:meta", "grown:", "grown;", -1); !reflect.DeepEqual(grown, []string{"grown:1", "grown:2"}) {
+		t.Errorf("grown = %q, want 2 filters appended", grown)
+	}
+	if n, _ := bf.Count(ctx); n != total || n < 690 {
+		t.Errorf("Count = %d, added %d", n, total)
+	}
+	for i := 0; i < 700; i++ {
+		if ok, _ := bf.Test(ctx, fmt.Sprint("item:", i)); !ok {
+			t.Fatalf("item:%d not found", i)
+		}
+	}
+	fp := 0
+	for i := 0; i < 1000; i++ {
+		if ok, _ := bf.Test(ctx, fmt.Sprint("other:", i)); ok {
+			fp++
+		}
+	}
+	if fp > 30 {
+		t.Errorf("%d false positives of 1000", fp)
+	}
+
+	bf.Clear(ctx)
+	if keys, _ := db.Keys("bf", "bg", 10); len(keys) != 0 {
+		t.Errorf("keys after Clear = %v", keys)
+	}
+	if size, _ := db.HSize("bf:meta"); size != 0 {
+		t.Errorf("meta size after Clear = %d", size)
+	}
+}
+
+func TestBloomFilterGrowRetry(t *testing.T) {
+	db, s := newTestClient(t)
+	ctx := context.Background()
+	bf := newTestBloomFilter(t, db, 10, &BloomFilterOptions{Scalable: true})
+	items := func(prefix string, n int) []string {
+		batch := make([]string, n)
+		for i := range batch {
+			batch[i] = fmt.Sprint(prefix, i)
+		}
+		return batch
+	}
+
+	// 达到容量时追加失败, 之后加入元素时再次追加
+	s.FailCommand("hset", "error")
+	if _, err := bf.AddMulti(ctx, items("a", 10)...); err == nil {
+		t.Fatal("AddMulti should fail when growing fails")
+	}
+	s.FailCommand("hset", "")
+	if n, _ := bf.filters(db); n != 1 {
+		t.Fatalf("filters = %d, want 1", n)
+	}
+	bf.AddMulti(ctx, "b")
+	if n, _ := bf.filters(db); n != 2 {
+		t.Fatalf("filters after retry = %d, want 2", n)
+	}
+	// 之后的元素加入新的过滤器
+	bf.AddMulti(ctx, items("c", 3)...)
+	if n, _ := db.HGet("bf:meta", "count:1"); n != "3" {
+		t.Errorf("count:1 = %q, want 3", n)
+	}
+	for _, item := range append(items("a", 10), "b") {
+		if ok, _ := bf.Test(ctx, item); !ok {
+			t.Errorf("%s not found", item)
+		}
+	}
+}
+
+func TestNewBloomFilterInvalid(t *testing.T) {
+	db, _ := newTestClient(t)
+	if _, err := db.NewBloomFilter("bf", 100, 1, nil); !errors.Is(err, ssdb.ErrBadArguments) {
+		t.Errorf("NewBloomFilter with fpRate 1 err = %v, want ErrBadArguments", err)
+	}
+	if _, err := db.NewBloomFilter("bf", 0, 0.01, nil); !errors.Is(err, ssdb.ErrBadArguments) {
+		t.Errorf("NewBloomFilter with capacity 0 err = %v, want ErrBadArguments", err)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import "sort"
+
+//  响应的格式
+type replyKind int
+
+const (
+	replyStatus replyKind = iota // 只有状态码
+	replyValue                   // 一个值
+	replyInt                     // 一个整数
+	replyList                    // 列表
+	replyPairs                   // key-value 对
+	replyScores                  // key-score 对
+	replyInfo                    // info 的响应, 第一个元素是服务名
+)
+
+//  第一个参数的类型, 用于补全
+type nameKind int
+
+const (
+	nameNone nameKind = iota
+	nameKV
+	nameHash
+	nameZSet
+	nameQueue
+)
+
+type command struct {
+	reply replyKind
+	name  nameKind
+}
+
+var commands = map[string]command{
+	"set": {replyStatus, nameKV}, "setx": {replyStatus, nameKV}, "setnx": {replyInt, nameKV},
+	"get": {replyValue, nameKV}, "getset": {replyValue, nameKV}, "del": {replyStatus, nameKV},
+	"incr": {replyInt, nameKV}, "exists": {replyInt, nameKV}, "ttl": {replyInt, nameKV},
+	"expire": {replyInt, nameKV}, "strlen": {replyInt, nameKV}, "substr": {replyValue, nameKV},
+	"getbit": {replyInt, nameKV}, "setbit": {replyInt, nameKV},
+	"keys": {replyList, nameKV}, "rkeys": {replyList, nameKV},
+	"scan": {replyPairs, nameKV}, "rscan": {replyPairs, nameKV},
+	"multi_get": {replyPairs, nameKV}, "multi_set": {replyInt, nameKV}, "multi_del": {replyInt, nameKV},
+
+	"hset": {replyInt, nameHash}, "hget": {replyValue, nameHash}, "hdel": {replyInt, nameHash},
+	"hincr": {replyInt, nameHash}, "hexists": {replyInt, nameHash}, "hsize": {replyInt, nameHash},
+	"hlist": {replyList, nameHash}, "hrlist": {replyList, nameHash}, "hkeys": {replyList, nameHash},
+	"hgetall": {replyPairs, nameHash}, "hscan": {replyPairs, nameHash}, "hrscan": {replyPairs, nameHash},
+	"hclear": {replyInt, nameHash}, "multi_hset": {replyInt, nameHash},
+	"multi_hget": {replyPairs, nameHash}, "multi_hdel": {replyInt, nameHash},
+
+	"zset": {replyInt, nameZSet}, "zget": {replyValue, nameZSet}, "zdel": {replyInt, nameZSet},
+	"zincr": {replyValue, nameZSet}, "zexists": {replyInt, nameZSet}, "zsize": {replyInt, nameZSet},
+	"zlist": {replyList, nameZSet}, "zrlist": {replyList, nameZSet}, "zkeys": {replyList, nameZSet},
+	"zscan": {replyScores, nameZSet}, "zrscan": {replyScores, nameZSet},
+	"zrank": {replyInt, nameZSet}, "zrrank": {replyInt, nameZSet},
+	"zrange": {replyScores, nameZSet}, "zrrange": {replyScores, nameZSet},
+	"zclear": {replyInt, nameZSet}, "zcount": {replyInt, nameZSet},
+	"zsum": {replyValue, nameZSet}, "zavg": {replyValue, nameZSet},
+	"zremrangebyrank": {replyInt, nameZSet}, "zremrangebyscore": {replyInt, nameZSet},
+	"zpop_front": {replyScores, nameZSet}, "zpop_back": {replyScores, nameZSet},
+	"multi_zset": {replyInt, nameZSet}, "multi_zget": {replyScores, nameZSet}, "multi_zdel": {replyInt, nameZSet},
+
+	"qpush": {replyInt, nameQueue}, "qpush_front": {replyInt, nameQueue}, "qpush_back": {replyInt, nameQueue},
+	"qpop": {replyList, nameQueue}, "qpop_front": {replyList, nameQueue}, "qpop_back": {replyList, nameQueue},
+	"qsize": {replyInt, nameQueue}, "qclear": {replyInt, nameQueue},
+	"qfront": {replyValue, nameQueue}, "qback": {replyValue, nameQueue},
+	"qget": {replyValue, nameQueue}, "qset": {replyStatus, nameQueue},
+	"qrange": {replyList, nameQueue}, "qslice": {replyList, nameQueue},
+	"qtrim_front": {replyInt, nameQueue}, "qtrim_back": {replyInt, nameQueue},
+	"qlist": {replyList, nameQueue}, "qrlist": {replyList, nameQueue},
+
+	"ping": {replyStatus, nameNone}, "version": {replyValue, nameNone}, "dbsize": {replyInt, nameNone},
+	"info": {replyInfo, nameNone}, "auth": {replyStatus, nameNone},
+	"flushdb": {replyStatus, nameNone}, "compact": {replyStatus, nameNone}, "clear_binlog": {replyStatus, nameNone},
+	"get_key_range": {replyList, nameNone}, "set_key_range": {replyStatus, nameNone},
+	"list_allow_ip": {replyList, nameNone}, "add_allow_ip": {replyStatus, nameNone}, "del_allow_ip": {replyStatus, nameNone},
+	"list_deny_ip": {replyList, nameNone}, "add_deny_ip": {replyStatus, nameNone}, "del_deny_ip": {replyStatus, nameNone},
+}
+
+//  返回命令的响应格式, 未知的命令按列表输出
+func replyOf(cmd string) replyKind {
+	if c, ok := commands[cmd]; ok {
+		return c.reply
+	}
+	return replyList
+}
+
+//  只在命令行中处理的命令
+var localCommands = []string{"help", "quit", "exit"}
+
+//  所有命令的名字, 已排序
+func commandNames() []string {
+	names := append([]string(nil), localCommands...)
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/houbin910902/gossdb_client"
+)
+
+//  输出一条命令的结果
+type printer interface {
+	result(cmd string, resp []string) error
+	error(cmd string, err error) error
+}
+
+//  可读的文本格式, 与 ssdb-cli 相似
+type textPrinter struct {
+	w io.Writer
+}
+
+//  值中有不可打印的字符或者首尾有空白时加上引号和转义
+func display(s string) string {
+	if s == "" {
+		return `""`
+	}
+	if !utf8.ValidString(s) || strings.TrimSpace(s) != s || strings.HasPrefix(s, `"`) {
+		return strconv.Quote(s)
+	}
+	for _, r := range s {
+		if !unicode.IsPrint(r) {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
+
+func (p *textPrinter) result(cmd string, resp []string) error {
+	var data []string
+	if len(resp) > 0 {
+		data = resp[1:]
+	}
+	var b strings.Builder
+	kind := replyOf(cmd)
+	switch kind {
+	case replyStatus, replyValue, replyInt:
+		if kind == replyStatus || len(data) == 0 {
+			b.WriteString("ok\n")
+			break
+		}
+		b.WriteString(display(data[0]))
+		b.WriteString("\n")
+	case replyPairs, replyScores:
+		width := 3
+		for i := 0; i < len(data); i += 2 {
+			if n := len(display(data[i])); n > width {
+				width = n
+			}
+		}
+		header := "value"
+		if kind == replyScores {
+			header = "score"
+		}
+		fmt.Fprintf(&b, "%-*s  %s\n", width, "key", header)
+		fmt.Fprintf(&b, "%s\n", strings.Repeat("-", width+2+len(header)))
+		for i := 0; i+1 < len(data); i += 2 {
+			fmt.Fprintf(&b, "%-*s  %s\n", width, display(data[i]), display(data[i+1]))
+		}
+		fmt.Fprintf(&b, "%d result(s)\n", len(data)/2)
+	case replyInfo:
+		if len(data) > 0 && data[0] == "ssdb-server" {
+			data = data[1:]
+		}
+		for i := 0; i+1 < len(data); i += 2 {
+			v := data[i+1]
+			if strings.Contains(v, "\n") {
+				fmt.Fprintf(&b, "%s\n%s\n", data[i], strings.TrimRight(v, "\n"))
+			} else {
+				fmt.Fprintf(&b, "%s: %s\n", data[i], v)
+			}
+		}
+	default:
+		for i, v := range data {
+			fmt.Fprintf(&b, "%d) %s\n", i+1, display(v))
+		}
+		fmt.Fprintf(&b, "%d result(s)\n", len(data))
+	}
+	_, err := io.WriteString(p.w, b.String())
+	return err
+}
+
+func (p *textPrinter) error(cmd string, err error) error {
+	var ce *gossdb_client.CommandError
+	if errors.As(err, &ce) && ce.Code != "" {
+		msg := ce.Code
+		if ce.Msg != "" {
+			msg += ": " + ce.Msg
+		}
+		_, werr := fmt.Fprintf(p.w, "error: %s\n", msg)
+		return werr
+	}
+	_, werr := fmt.Fprintf(p.w, "error: %v\n", err)
+	return werr
+}
+
+//  每条命令输出一行 json
+type jsonPrinter struct {
+	enc *json.Encoder
+}
+
+func newJSONPrinter(w io.Writer) *jsonPrinter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &jsonPrinter{enc: enc}
+}
+
+type jsonPair struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+type jsonError struct {
+	Error   string `json:"error"`
+	Message string `json:"message,omitempty"`
+}
+
+func (p *jsonPrinter) result(cmd string, resp []string) error {
+	var data []string
+	if len(resp) > 0 {
+		data = resp[1:]
+	}
+	var v interface{}
+	kind := replyOf(cmd)
+	switch kind {
+	case replyStatus, replyValue:
+		if kind == replyStatus || len(data) == 0 {
+			v = "ok"
+			break
+		}
+		v = data[0]
+	case replyInt:
+		if len(data) == 0 {
+			v = "ok"
+			break
+		}
+		if n, err := strconv.ParseInt(data[0], 10, 64); err == nil {
+			v = n
+		} else {
+			v = data[0]
+		}
+	case replyPairs, replyScores:
+		pairs := make([]jsonPair, 0, len(data)/2)
+		for i := 0; i+1 < len(data); i += 2 {
+			var val interface{} = data[i+1]
+			if kind == replyScores {
+				if f, err := strconv.ParseFloat(data[i+1], 64); err == nil {
+					val = f
+				}
+			}
+			pairs = append(pairs, jsonPair{data[i], val})
+		}
+		v = pairs
+	case replyInfo:
+		if len(data) > 0 && data[0] == "ssdb-server" {
+			data = data[1:]
+		}
+		pairs := make([]jsonPair, 0, len(data)/2)
+		for i := 0; i+1 < len(data); i += 2 {
+			pairs = append(pairs, jsonPair{data[i], data[i+1]})
+		}
+		v = pairs
+	default:
+		if data == nil {
+			data = []string{}
+		}
+		v = data
+	}
+	return p.enc.Encode(v)
+}
+
+func (p *jsonPrinter) error(cmd string, err error) error {
+	var ce *gossdb_client.CommandError
+	if errors.As(err, &ce) && ce.Code != "" {
+		return p.enc.Encode(jsonError{ce.Code, ce.Msg})
+	}
+	return p.enc.Encode(jsonError{"error", err.Error()})
+}
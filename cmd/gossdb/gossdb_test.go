@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/houbin910902/gossdb_client/ssdbtest"
+)
+
+func TestSplitArgs(t *testing.T) {
+	cases := []struct {
+		line string
+		want []string
+	}{
+		{"", nil},
+		{"  get   a ", []string{"get", "a"}},
+		{`set k 'hello world'`, []string{"set", "k", "hello world"}},
+		{`set k "a\tb\x00" x`, []string{"set", "k", "a\tb\x00", "x"}},
+		{`set k ""`, []string{"set", "k", ""}},
+		{`set k a'b c'"\""`, []string{"set", "k", `ab c"`}},
+	}
+	for _, c := range cases {
+		got, err := splitArgs(c.line)
+		if err != nil {
+			t.Fatalf("splitArgs(%q) err: %v", c.line, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("splitArgs(%q) = %q, want %q", c.line, got, c.want)
+		}
+	}
+	for _, line := range []string{`get "a`, `get 'a`, `get "\q"`} {
+		if _, err := splitArgs(line); err == nil {
+			t.Errorf("splitArgs(%q) should fail", line)
+		}
+	}
+}
+
+func TestCompleter(t *testing.T) {
+	var gotKind nameKind
+	complete := completer(func(kind nameKind, prefix string) ([]string, error) {
+		gotKind = kind
+		return []string{"user:1", "user:2", "user 3", "other"}, nil
+	})
+	if got := complete(nil, "hget"); !reflect.DeepEqual(got, []string{"hget", "hgetall"}) {
+		t.Errorf("complete command = %q", got)
+	}
+	if got := complete([]string{"HGETALL"}, "user:"); !reflect.DeepEqual(got, []string{"user:1", "user:2"}) || gotKind != nameHash {
+		t.Errorf("complete name = %q, kind %d", got, gotKind)
+	}
+	if got := complete([]string{"ping"}, ""); got != nil {
+		t.Errorf("complete ping = %q", got)
+	}
+	if got := commonPrefix([]string{"user:1", "user:2"}); got != "user:" {
+		t.Errorf("commonPrefix = %q", got)
+	}
+
+	start, end := prefixRange("ab")
+	for _, k := range []string{"ab", "ab:1", "ab\xff"} {
+		if !(k > start && k <= end) {
+			t.Errorf("%q not in prefixRange (%q, %q]", k, start, end)
+		}
+	}
+}
+
+func TestLineEditor(t *testing.T) {
+	// 输入 "get b", 左移两次插入 "a", 回车; 然后用上方向键取回历史命令
+	in := "get b\x1b[D\x1b[Da\r\x1b[A\r\x04"
+	var out bytes.Buffer
+	e := newLineEditor(strings.NewReader(in), &out)
+	line, err := e.readLine("> ")
+	if err != nil || line != "geta b" {
+		t.Fatalf("readLine = %q, %v", line, err)
+	}
+	e.addHistory(line)
+	if line, err = e.readLine("> "); err != nil || line != "geta b" {
+		t.Fatalf("readLine history = %q, %v", line, err)
+	}
+	if _, err = e.readLine("> "); err == nil {
+		t.Fatal("Ctrl-D should return EOF")
+	}
+
+	e = newLineEditor(strings.NewReader("hgeta\t\r"), &out)
+	e.complete = completer(func(nameKind, string) ([]string, error) { return nil, nil })
+	if line, _ = e.readLine("> "); line != "hgetall " {
+		t.Errorf("readLine with tab = %q", line)
+	}
+}
+
+func runCLI(t *testing.T, s *ssdbtest.Server, o options, stdin string) (string, int) {
+	t.Helper()
+	o.host, o.port = s.Host(), s.Port()
+	var out, errOut bytes.Buffer
+	code := run(&o, strings.NewReader(stdin), &out, &errOut)
+	if errOut.Len() > 0 {
+		t.Logf("stderr: %s", errOut.String())
+	}
+	return out.String(), code
+}
+
+func TestRunText(t *testing.T) {
+	s := ssdbtest.NewServer()
+	defer s.Close()
+
+	out, code := runCLI(t, s, options{command: `hset h "a b" 1`}, "")
+	if code != 0 || out != "1\n" {
+		t.Fatalf("-c hset = %q, %d", out, code)
+	}
+
+	script := `
+# 注释和空行会被跳过
+HSET h c 2
+hgetall h
+zset z m 15
+zscan z "" "" "" 10
+qpush_back q x y
+qrange q 0 -1
+get missing
+`
+	out, code = runCLI(t, s, options{}, script)
+	if code != 1 {
+		t.Errorf("exit code = %d, want 1 because of not_found", code)
+	}
+	want := `1
+key  value
+----------
+a b  1
+c    2
+2 result(s)
+1
+key  score
+----------
+m    15
+1 result(s)
+2
+1) x
+2) y
+2 result(s)
+error: not_found
+`
+	if out != want {
+		t.Errorf("output:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestRunJSON(t *testing.T) {
+	s := ssdbtest.NewServer()
+	defer s.Close()
+
+	script := "set k v\nget k\nincr n 3\nzset z m 2\nzscan z '' '' '' 10\nqpush_back q x\nqrange q 0 -1\nhgetall none\nget missing\nnosuchcmd\n"
+	out, code := runCLI(t, s, options{json: true}, script)
+	if code != 1 {
+		t.Errorf("exit code = %d, want 1", code)
+	}
+	want := []string{
+		`"ok"`,
+		`"v"`,
+		`3`,
+		`1`,
+		`[{"key":"m","value":2}]`,
+		`1`,
+		`["x"]`,
+		`[]`,
+		`{"error":"not_found"}`,
+	}
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) != len(want)+1 {
+		t.Fatalf("output:\n%s", out)
+	}
+	for i, w := range want {
+		if lines[i] != w {
+			t.Errorf("line %d = %s, want %s", i, lines[i], w)
+		}
+	}
+	if !strings.HasPrefix(lines[len(want)], `{"error":"`) {
+		t.Errorf("unknown command = %s", lines[len(want)])
+	}
+}
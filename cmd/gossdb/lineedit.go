@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+//  最多保存的历史命令数
+const maxHistory = 1000
+
+//  补全 key 时最多查询的个数
+const completeLimit = 20
+
+//  简单的行编辑器, 支持光标移动、历史命令和 tab 补全. 终端需要处于 raw 模式
+type lineEditor struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	history []string
+	// 补全候选项, 参数为已经输入的参数和正在输入的前缀
+	complete func(args []string, prefix string) []string
+}
+
+func newLineEditor(in io.Reader, out io.Writer) *lineEditor {
+	return &lineEditor{in: bufio.NewReader(in), out: out}
+}
+
+//  读取一行, 不包括换行符. 空行时输入 Ctrl-D 返回 io.EOF
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	var line []rune
+	pos := 0
+	hist := len(e.history)
+	saved := ""
+	lastTab := false
+
+	redraw := func() {
+		fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(line))
+		if n := len(line) - pos; n > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", n)
+		}
+	}
+	setLine := func(s string) {
+		line = []rune(s)
+		pos = len(line)
+		redraw()
+	}
+	redraw()
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		tab := false
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(line), nil
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(line) {
+				line = append(line[:pos], line[pos+1:]...)
+				redraw()
+			}
+		case 3: // Ctrl-C, 放弃当前行
+			fmt.Fprint(e.out, "^C\r\n")
+			line, pos = nil, 0
+			hist = len(e.history)
+			redraw()
+		case 127, 8: // Backspace
+			if pos > 0 {
+				line = append(line[:pos-1], line[pos:]...)
+				pos--
+				redraw()
+			}
+		case 1: // Ctrl-A
+			pos = 0
+			redraw()
+		case 5: // Ctrl-E
+			pos = len(line)
+			redraw()
+		case 11: // Ctrl-K
+			line = line[:pos]
+			redraw()
+		case 21: // Ctrl-U
+			line = append([]rune(nil), line[pos:]...)
+			pos = 0
+			redraw()
+		case 23: // Ctrl-W, 删除光标前的一个单词
+			i := pos
+			for i > 0 && line[i-1] == ' ' {
+				i--
+			}
+			for i > 0 && line[i-1] != ' ' {
+				i--
+			}
+			line = append(line[:i], line[pos:]...)
+			pos = i
+			redraw()
+		case 12: // Ctrl-L
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+			redraw()
+		case '\t':
+			tab = true
+			e.completeLine(&line, &pos, lastTab)
+			redraw()
+		case 27: // 方向键等转义序列
+			seq := e.readEscape()
+			switch seq {
+			case "[A": // 上
+				if hist > 0 {
+					if hist == len(e.history) {
+						saved = string(line)
+					}
+					hist--
+					setLine(e.history[hist])
+				}
+			case "[B": // 下
+				if hist < len(e.history) {
+					hist++
+					if hist == len(e.history) {
+						setLine(saved)
+					} else {
+						setLine(e.history[hist])
+					}
+				}
+			case "[C": // 右
+				if pos < len(line) {
+					pos++
+					redraw()
+				}
+			case "[D": // 左
+				if pos > 0 {
+					pos--
+					redraw()
+				}
+			case "[H", "OH", "[1~":
+				pos = 0
+				redraw()
+			case "[F", "OF", "[4~":
+				pos = len(line)
+				redraw()
+			case "[3~": // Delete
+				if pos < len(line) {
+					line = append(line[:pos], line[pos+1:]...)
+					redraw()
+				}
+			}
+		default:
+			if r < ' ' {
+				break
+			}
+			line = append(line[:pos], append([]rune{r}, line[pos:]...)...)
+			pos++
+			redraw()
+		}
+		lastTab = tab
+	}
+}
+
+//  读取 ESC 之后的转义序列, 例如 "[A" 或 "[3~"
+func (e *lineEditor) readEscape() string {
+	b, err := e.in.ReadByte()
+	if err != nil || (b != '[' && b != 'O') {
+		return ""
+	}
+	seq := []byte{b}
+	for {
+		c, err := e.in.ReadByte()
+		if err != nil {
+			return ""
+		}
+		seq = append(seq, c)
+		if c >= '@' && c <= '~' && !(b == '[' && c >= '0' && c <= '9') {
+			return string(seq)
+		}
+		if len(seq) > 8 {
+			return ""
+		}
+	}
+}
+
+//  补全光标前的参数. 有唯一的候选项时补全并加上空格, 否则补全到公共前缀;
+//  无法继续补全时连续按两次 tab 列出所有候选项
+func (e *lineEditor) completeLine(line *[]rune, pos *int, list bool) {
+	if e.complete == nil {
+		return
+	}
+	before := string((*line)[:*pos])
+	start := strings.LastIndexByte(before, ' ') + 1
+	prefix := before[start:]
+	args := strings.Fields(before[:start])
+	cands := e.complete(args, prefix)
+	if len(cands) == 0 {
+		return
+	}
+	insert := commonPrefix(cands)[len(prefix):]
+	if len(cands) == 1 {
+		insert += " "
+	}
+	if insert == "" {
+		if list {
+			fmt.Fprint(e.out, "\r\n"+strings.Join(cands, "  ")+"\r\n")
+		}
+		return
+	}
+	ins := []rune(insert)
+	rest := append([]rune(nil), (*line)[*pos:]...)
+	*line = append(append((*line)[:*pos], ins...), rest...)
+	*pos += len(ins)
+}
+
+func commonPrefix(ss []string) string {
+	p := ss[0]
+	for _, s := range ss[1:] {
+		for !strings.HasPrefix(s, p) {
+			p = p[:len(p)-1]
+		}
+	}
+	for !utf8.ValidString(p) {
+		p = p[:len(p)-1]
+	}
+	return p
+}
+
+//  补全命令名和第一个参数(key 或容器的名字), 名字通过 keys/hlist/zlist/qlist 查询
+func completer(list func(kind nameKind, prefix string) ([]string, error)) func([]string, string) []string {
+	return func(args []string, prefix string) []string {
+		var cands []string
+		switch len(args) {
+		case 0:
+			for _, name := range commandNames() {
+				if strings.HasPrefix(name, prefix) {
+					cands = append(cands, name)
+				}
+			}
+		case 1:
+			cmd, ok := commands[strings.ToLower(args[0])]
+			if !ok || cmd.name == nameNone {
+				return nil
+			}
+			names, err := list(cmd.name, prefix)
+			if err != nil {
+				return nil
+			}
+			for _, name := range names {
+				// 含有空白的名字无法直接输入
+				if strings.HasPrefix(name, prefix) && !strings.ContainsAny(name, " \t\r\n") {
+					cands = append(cands, name)
+				}
+			}
+		}
+		sort.Strings(cands)
+		return cands
+	}
+}
+
+//  历史命令文件 ~/.gossdb_history
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".gossdb_history")
+}
+
+func (e *lineEditor) loadHistory(path string) {
+	if path == "" {
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if line := s.Text(); line != "" {
+			e.history = append(e.history, line)
+		}
+	}
+	if n := len(e.history); n > maxHistory {
+		e.history = e.history[n-maxHistory:]
+	}
+}
+
+//  记录一条历史命令, 与上一条相同的命令和 auth 命令(含有密码)不记录
+func (e *lineEditor) addHistory(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(strings.ToLower(line), "auth ") {
+		return
+	}
+	if n := len(e.history); n > 0 && e.history[n-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+	if n := len(e.history); n > maxHistory {
+		e.history = e.history[n-maxHistory:]
+	}
+}
+
+func (e *lineEditor) saveHistory(path string) error {
+	if path == "" {
+		return errors.New("no home directory")
+	}
+	data := strings.Join(e.history, "\n")
+	if data != "" {
+		data += "\n"
+	}
+	return os.WriteFile(path, []byte(data), 0600)
+}
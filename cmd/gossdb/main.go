@@ -0,0 +1,222 @@
+//  gossdb 是 ssdb 的命令行客户端, 可以代替 ssdb-cli.
+//
+//	gossdb -h 127.0.0.1 -p 8888               交互模式, 支持历史命令和 tab 补全
+//	gossdb -c 'hgetall user:1'                执行一条命令
+//	gossdb --json < commands.txt              从标准输入逐行读取命令, 每条命令输出一行 json
+//
+//  参数用空白分隔, 可以用单引号或双引号包含空白, 双引号中支持 Go 的转义, 例如 "\x00".
+//  非交互模式下有命令失败时退出码为 1.
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/houbin910902/gossdb_client"
+)
+
+type options struct {
+	host     string
+	port     int
+	password string
+	command  string
+	json     bool
+	timeout  time.Duration
+}
+
+func main() {
+	var o options
+	flag.StringVar(&o.host, "h", "127.0.0.1", "ssdb 的地址")
+	flag.IntVar(&o.port, "p", 8888, "ssdb 的端口")
+	flag.StringVar(&o.password, "a", "", "密码")
+	flag.StringVar(&o.command, "c", "", "执行一条命令后退出")
+	flag.BoolVar(&o.json, "json", false, "以 json 格式输出, 每条命令一行")
+	flag.DurationVar(&o.timeout, "t", 0, "每条命令的超时时间, 0 表示不限制")
+	flag.Parse()
+	os.Exit(run(&o, os.Stdin, os.Stdout, os.Stderr))
+}
+
+//  返回进程的退出码
+func run(o *options, stdin io.Reader, stdout, stderr io.Writer) int {
+	db, err := gossdb_client.NewDbClient(o.host, o.port, o.password)
+	if err != nil {
+		fmt.Fprintln(stderr, "gossdb:", err)
+		return 1
+	}
+	defer db.CloseDbClient()
+	db.SetReconnect(&gossdb_client.ReconnectPolicy{MaxRetries: 2})
+
+	var p printer = &textPrinter{w: stdout}
+	if o.json {
+		p = newJSONPrinter(stdout)
+	}
+	s := &session{db: db, p: p, timeout: o.timeout}
+
+	if o.command != "" {
+		if !s.exec(o.command) {
+			return 1
+		}
+		return 0
+	}
+	if f, ok := stdin.(*os.File); ok && !o.json && isTerminal(int(f.Fd())) {
+		if err := s.repl(f, stdout, fmt.Sprintf("%s:%d> ", o.host, o.port)); err != nil {
+			fmt.Fprintln(stderr, "gossdb:", err)
+			return 1
+		}
+		return 0
+	}
+	return s.script(stdin, stderr)
+}
+
+type session struct {
+	db      *gossdb_client.DbClient
+	p       printer
+	timeout time.Duration
+}
+
+//  执行一行命令, 输出结果或错误. 返回命令是否成功, 空行视为成功
+func (s *session) exec(line string) bool {
+	args, err := splitArgs(line)
+	if err != nil {
+		s.p.error("", err)
+		return false
+	}
+	if len(args) == 0 {
+		return true
+	}
+	cmd := strings.ToLower(args[0])
+	iargs := make([]interface{}, len(args))
+	iargs[0] = cmd
+	for i, a := range args[1:] {
+		iargs[i+1] = a
+	}
+
+	c := s.db
+	if s.timeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+		defer cancel()
+		c = c.WithContext(ctx)
+	}
+	// 命令行工具可以执行包括运维命令在内的任意命令
+	resp, err := c.Admin().Do(iargs...)
+	if err != nil {
+		s.p.error(cmd, err)
+		return false
+	}
+	s.p.result(cmd, resp)
+	return true
+}
+
+//  从 r 逐行读取命令, 跳过空行和 # 开头的注释. 返回退出码
+func (s *session) script(r io.Reader, stderr io.Writer) int {
+	code := 0
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 64<<20)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !s.exec(line) {
+			code = 1
+		}
+	}
+	if err := sc.Err(); err != nil {
+		fmt.Fprintln(stderr, "gossdb:", err)
+		return 1
+	}
+	return code
+}
+
+//  交互模式
+func (s *session) repl(tty *os.File, out io.Writer, prompt string) error {
+	restore, err := makeRaw(int(tty.Fd()))
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	// raw 模式下 \n 不会回到行首
+	w := &crlfWriter{w: out}
+	s.p = &textPrinter{w: w}
+	e := newLineEditor(tty, w)
+	e.complete = completer(s.listNames)
+	hist := historyPath()
+	e.loadHistory(hist)
+	defer e.saveHistory(hist)
+
+	for {
+		line, err := e.readLine(prompt)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		e.addHistory(line)
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "":
+			continue
+		case "quit", "exit":
+			return nil
+		case "help":
+			fmt.Fprintln(w, strings.Join(commandNames(), " "))
+			continue
+		}
+		start := time.Now()
+		s.exec(line)
+		fmt.Fprintf(w, "(%.3f sec)\n", time.Since(start).Seconds())
+	}
+}
+
+//  查询以 prefix 开头的 key 或容器名字, 用于补全
+func (s *session) listNames(kind nameKind, prefix string) ([]string, error) {
+	start, end := prefixRange(prefix)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	c := s.db.WithContext(ctx)
+	switch kind {
+	case nameKV:
+		return c.Keys(start, end, completeLimit)
+	case nameHash:
+		return c.HList(start, end, completeLimit)
+	case nameZSet:
+		return c.ZList(start, end, completeLimit)
+	case nameQueue:
+		return c.QList(start, end, completeLimit)
+	}
+	return nil, nil
+}
+
+//  返回包含所有以 prefix 开头的名字的区间 (start, end], 用于 keys 等命令.
+//  区间可能包含少量不以 prefix 开头的名字, 需要调用方过滤
+func prefixRange(prefix string) (start, end string) {
+	if prefix == "" {
+		return "", ""
+	}
+	n := len(prefix) - 1
+	start = prefix[:n]
+	if prefix[n] > 0 {
+		start += string([]byte{prefix[n] - 1}) + "\xff\xff\xff\xff"
+	}
+	return start, prefix + "\xff\xff\xff\xff"
+}
+
+//  把 \n 转换为 \r\n
+type crlfWriter struct {
+	w io.Writer
+}
+
+func (c *crlfWriter) Write(p []byte) (int, error) {
+	s := strings.ReplaceAll(strings.ReplaceAll(string(p), "\r\n", "\n"), "\n", "\r\n")
+	if _, err := io.WriteString(c.w, s); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
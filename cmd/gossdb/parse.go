@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//  把一行命令拆分为参数. 参数之间用空白分隔, 可以用单引号或双引号包含空白,
+//  双引号中支持 Go 的转义, 例如 "a\tb" 或 "\x00"
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		case ch == '"':
+			end := closingQuote(line, i)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote")
+			}
+			s, err := strconv.Unquote(line[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted string %s", line[i:end+1])
+			}
+			cur.WriteString(s)
+			inArg = true
+			i = end
+		case ch == '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote")
+			}
+			cur.WriteString(line[i+1 : i+1+end])
+			inArg = true
+			i += end + 1
+		default:
+			cur.WriteByte(ch)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+//  返回从 start 开始的双引号字符串的结束位置, 跳过转义的引号
+func closingQuote(line string, start int) int {
+	for i := start + 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd
+
+package main
+
+import "errors"
+
+func isTerminal(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("raw terminal mode is not supported")
+}
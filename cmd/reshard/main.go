@@ -0,0 +1,122 @@
+//  reshard 把数据从一个分片配置迁移到另一个分片配置, 分片的分布与 gossdb_client.ShardedClient 相同.
+//
+//	reshard -from s0=10.0.0.1:8888,s1=10.0.0.2:8888 \
+//		-to s0=10.0.0.1:8888,s1=10.0.0.2:8888,s2=10.0.0.3:8888 \
+//		-checkpoint reshard.json
+//
+//  分片的格式为 name=host:port, 可以在后面加上 *weight 指定权重. 两个配置中名字相同的分片必须是同一个实例.
+//  先用 -dry-run 查看需要迁移的数据量; 中断后用同一个 -checkpoint 重新执行即可从中断的位置继续.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/houbin910902/gossdb_client"
+)
+
+func main() {
+	from := flag.String("from", "", "迁移前的分片, name=host:port[*weight],...")
+	to := flag.String("to", "", "迁移后的分片, name=host:port[*weight],...")
+	password := flag.String("password", "", "所有分片的密码")
+	vnodes := flag.Int("vnodes", gossdb_client.DefaultVirtualNodes, "每个权重单位的虚拟节点数, 必须与应用的配置一致")
+	checkpoint := flag.String("checkpoint", "", "检查点文件")
+	pageSize := flag.Int64("page", gossdb_client.DefaultScanPageSize, "每页的元素个数")
+	dryRun := flag.Bool("dry-run", false, "只统计需要迁移的数据")
+	verbose := flag.Bool("v", false, "输出每个迁移的 key 和容器")
+	flag.Parse()
+
+	if err := run(*from, *to, *password, *vnodes, *checkpoint, *pageSize, *dryRun, *verbose); err != nil {
+		fmt.Fprintln(os.Stderr, "reshard:", err)
+		os.Exit(1)
+	}
+}
+
+func run(from, to, password string, vnodes int, checkpoint string, pageSize int64, dryRun, verbose bool) error {
+	fromConf, err := parseShards(from, password, vnodes)
+	if err != nil {
+		return fmt.Errorf("-from: %w", err)
+	}
+	toConf, err := parseShards(to, password, vnodes)
+	if err != nil {
+		return fmt.Errorf("-to: %w", err)
+	}
+	fromClient, err := gossdb_client.NewShardedClient(fromConf)
+	if err != nil {
+		return err
+	}
+	defer fromClient.Close()
+	toClient, err := gossdb_client.NewShardedClient(toConf)
+	if err != nil {
+		return err
+	}
+	defer toClient.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	conf := &gossdb_client.ReshardConfig{
+		From:       fromClient,
+		To:         toClient,
+		Checkpoint: checkpoint,
+		PageSize:   pageSize,
+		DryRun:     dryRun,
+	}
+	if verbose {
+		conf.Progress = func(m gossdb_client.ReshardMove) {
+			fmt.Printf("%s %s: %s -> %s\n", m.Kind, m.Name, m.From, m.To)
+		}
+	}
+	stats, err := gossdb_client.Reshard(ctx, conf)
+	for _, kind := range []string{gossdb_client.ReshardKV, gossdb_client.ReshardHash, gossdb_client.ReshardZSet, gossdb_client.ReshardQueue} {
+		fmt.Printf("%-6s scanned %d, moved %d\n", kind, stats.Scanned[kind], stats.Moved[kind])
+	}
+	if err != nil && checkpoint != "" && !dryRun {
+		fmt.Fprintf(os.Stderr, "reshard: interrupted, rerun with -checkpoint %s to resume\n", checkpoint)
+	}
+	return err
+}
+
+//  解析 name=host:port[*weight],...
+func parseShards(s, password string, vnodes int) (*gossdb_client.ShardedConfig, error) {
+	conf := &gossdb_client.ShardedConfig{VirtualNodes: vnodes}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		var sc gossdb_client.ShardConfig
+		eq := strings.IndexByte(item, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("invalid shard %q, want name=host:port", item)
+		}
+		sc.Name, item = item[:eq], item[eq+1:]
+		if star := strings.IndexByte(item, '*'); star >= 0 {
+			w, err := strconv.Atoi(item[star+1:])
+			if err != nil || w <= 0 {
+				return nil, fmt.Errorf("invalid weight in %q", s)
+			}
+			sc.Weight, item = w, item[:star]
+		}
+		host, port, err := net.SplitHostPort(item)
+		if err != nil {
+			return nil, err
+		}
+		if sc.Port, err = strconv.Atoi(port); err != nil {
+			return nil, fmt.Errorf("invalid port %q", port)
+		}
+		sc.Ip, sc.Password = host, password
+		conf.Shards = append(conf.Shards, sc)
+	}
+	if len(conf.Shards) == 0 {
+		return nil, fmt.Errorf("no shards")
+	}
+	return conf, nil
+}
@@ -0,0 +1,123 @@
+package gossdb_client
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+)
+
+//  值的编解码方式, 用于 SetObject、HSetObject、QPushObject 等方法存取复杂类型.
+//  实现必须可以被多个 goroutine 同时使用. 传给 Unmarshal 的 data 不会再被 client 修改, 返回后仍然可以引用
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+//  没有设置 Codec 时使用的编解码方式
+var DefaultCodec Codec = JSONCodec{}
+
+//  使用 encoding/json 编解码
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+//  使用 encoding/gob 编解码. 每个值单独编码, 都带有类型信息, 自定义的接口类型需要先 gob.Register
+type GobCodec struct{}
+
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+//  紧凑的二进制编解码, 不带任何类型信息:
+//  实现了 encoding.BinaryMarshaler/BinaryUnmarshaler 的类型(如 protobuf 消息的包装)使用自身的方法,
+//  []byte 和 string 原样保存, 其余的定长类型(数值、bool 以及只包含它们的数组和结构体)按小端序用 encoding/binary 编码
+type BinaryCodec struct{}
+
+func (BinaryCodec) Marshal(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case encoding.BinaryMarshaler:
+		return v.MarshalBinary()
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	if binary.Size(v) < 0 {
+		return nil, fmt.Errorf("gossdb_client: BinaryCodec cannot encode %T", v)
+	}
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (BinaryCodec) Unmarshal(data []byte, v interface{}) error {
+	switch v := v.(type) {
+	case encoding.BinaryUnmarshaler:
+		return v.UnmarshalBinary(data)
+	case *[]byte:
+		*v = append((*v)[:0], data...)
+		return nil
+	case *string:
+		*v = string(data)
+		return nil
+	}
+	if n := binary.Size(v); n != len(data) {
+		return fmt.Errorf("gossdb_client: BinaryCodec cannot decode %d bytes into %T", len(data), v)
+	}
+	return binary.Read(bytes.NewReader(data), binary.LittleEndian, v)
+}
+
+//  设置 *Object 系列方法使用的编解码方式, 为 nil 时使用 DefaultCodec.
+//  WithContext 返回的副本会继承这个设置
+func (c *DbClient) SetCodec(codec Codec) {
+	c.codec = codec
+}
+
+//  返回 client 使用的编解码方式
+func (c *DbClient) Codec() Codec {
+	if c.codec != nil {
+		return c.codec
+	}
+	return DefaultCodec
+}
+
+func (c *DbClient) marshal(v interface{}) ([]byte, error) {
+	data, err := c.Codec().Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("gossdb_client: encode %T: %w", v, err)
+	}
+	return data, nil
+}
+
+//  data 交给 Codec 后不能再修改, 连接的接收缓冲区需要先复制, 参见 unmarshalBorrowed
+func (c *DbClient) unmarshal(data []byte, v interface{}) error {
+	if err := c.Codec().Unmarshal(data, v); err != nil {
+		return fmt.Errorf("gossdb_client: decode %T: %w", v, err)
+	}
+	return nil
+}
+
+//  解码 GetBytes 等方法返回的切片. 它引用连接的接收缓冲区, 下一条命令会覆盖它,
+//  而 Codec 可以保留 data, 例如解码到 []byte 或 json.RawMessage, 因此先复制一份
+func (c *DbClient) unmarshalBorrowed(data []byte, v interface{}) error {
+	return c.unmarshal(bytes.Clone(data), v)
+}
@@ -1,12 +1,26 @@
 package gossdb_client
 
 import (
+	"context"
 	"github.com/houbin910902/gossdb_client/gossdb/ssdb"
-	"fmt"
 )
 
 type DbClient struct {
 	Client *ssdb.Client
+	ctx    context.Context
+	ex     executor
+	codec  Codec
+
+	// 断线重连时用于重新认证
+	password  string
+	reconnect *ReconnectPolicy
+}
+
+//  命令执行器, 设置后 DbClient 不再使用自身的 Client, 而是把命令交给执行器, 例如连接池
+type executor interface {
+	exec(ctx context.Context, args []interface{}) ([]string, error)
+	// 在同一个连接上一次发出多条命令, 按顺序返回每条命令的响应
+	execPipeline(ctx context.Context, cmds [][]interface{}) ([][]string, error)
 }
 
 func NewDbClient(ip string, port int, Password string) (*DbClient, error) {
@@ -16,6 +30,7 @@ func NewDbClient(ip string, port int, Password string) (*DbClient, error) {
 		return &db, err
 	}
 	db.Client = c
+	db.password = Password
 	if Password == ""{
 		return &db, nil
 	}
@@ -25,7 +40,7 @@ func NewDbClient(ip string, port int, Password string) (*DbClient, error) {
 		return &db, nil
 	}
 	db.CloseDbClient()
-	return nil, fmt.Errorf("auth failed, password is wrong")
+	return nil, err
 }
 
 
@@ -42,15 +57,122 @@ func (c *DbClient) CloseDbClient() error {
 
 func (c *DbClient) Auth(Password string) ([]string, error) {
 	if Password != "" {
-		resp, err := c.Client.Do("auth", []string{Password})
+		resp, err := c.do("auth", []string{Password})
 		if err != nil {
-			return nil, fmt.Errorf("%s authentication failed", err)
+			return resp, err
 		}
-		if len(resp) > 0 && resp[0] == "ok" {
-			//验证成功
-			return resp, nil
+		//验证成功, 记住密码用于断线重连后重新认证
+		c.password = Password
+		return resp, nil
+	}
+	return nil, &CommandError{Cmd: "auth", Err: ErrAuth}
+}
+
+//  返回一个绑定了 ctx 的 DbClient 副本, 副本与原 client 共享同一个连接.
+//  通过副本执行的所有命令在 ctx 取消或超时后立即返回 ctx.Err(), 此时连接被标记为不可用, 需要关闭后重新创建.
+//  ctx 不能为 nil
+//  用法: db.WithContext(ctx).Get("a")
+func (c *DbClient) WithContext(ctx context.Context) *DbClient {
+	if ctx == nil {
+		panic("nil context")
+	}
+	c2 := *c
+	c2.ctx = ctx
+	return &c2
+}
+
+//  返回 client 绑定的 ctx, 未绑定时返回 context.Background()
+func (c *DbClient) Context() context.Context {
+	if c.ctx != nil {
+		return c.ctx
+	}
+	return context.Background()
+}
+
+//  执行一条命令, 所有的命令都应通过这里发出
+//  服务端返回的状态码不是 ok 时返回 *CommandError
+func (c *DbClient) do(args ...interface{}) ([]string, error) {
+	var resp []string
+	var err error
+	if c.ex != nil {
+		resp, err = c.ex.exec(c.ctx, args)
+	} else if c.reconnect != nil {
+		resp, err = c.doRetry(args)
+	} else {
+		resp, err = c.roundTrip(args)
+	}
+	if err != nil {
+		if _, ok := err.(*CommandError); ok {
+			return resp, err
 		}
-		return resp, fmt.Errorf("auth failed, password is wrong")
+		return nil, newCommandError(args, err)
+	}
+	if err = respError(args, resp); err != nil {
+		return resp, err
 	}
-	return nil, fmt.Errorf("auth failed")
-}
\ No newline at end of file
+	return resp, nil
+}
+
+//  执行一条任意的命令, 用于没有封装的命令. flushdb 等运维命令不会执行, 需要使用 Admin 的方法或 Admin.Do
+//  args 命令及参数, 例如 "zset", "rank", "a", 1
+//  返回 resp, 原始响应, 第一个元素为状态码
+//  返回 err，可能的错误，服务端返回的状态码不是 ok 时为 *CommandError, 此时 resp 仍然是服务端的响应;
+//  运维命令返回 ErrAdminCommand
+func (c *DbClient) Do(args ...interface{}) ([]string, error) {
+	if err := checkNotAdmin(args); err != nil {
+		return nil, err
+	}
+	return c.do(args...)
+}
+
+//  在当前连接上发出一条命令并读取响应
+func (c *DbClient) roundTrip(args []interface{}) ([]string, error) {
+	if c.ctx != nil {
+		return c.Client.DoContext(c.ctx, args...)
+	}
+	return c.Client.Do(args...)
+}
+
+//  一次发出多条命令, 按顺序返回每条命令的响应
+//  管道中可能包含写命令, 因此开启断线重连时也只在发出前重连, 不会重试
+func (c *DbClient) doPipeline(cmds [][]interface{}) ([][]string, error) {
+	if c.ex != nil {
+		return c.ex.execPipeline(c.ctx, cmds)
+	}
+	if c.reconnect != nil {
+		if err := c.redialIfBroken(); err != nil {
+			return nil, err
+		}
+	}
+	return c.Client.DoPipeline(c.ctx, cmds)
+}
+
+//  与 do 相同, 但响应以 []byte 返回, 直接引用连接的接收缓冲区, 不再逐个复制为 string.
+//  返回的切片只在下一条命令之前有效.
+//  使用执行器或开启断线重连时没有可以复用的缓冲区, 此时由 do 的结果转换而来
+func (c *DbClient) doBytes(args ...interface{}) ([][]byte, error) {
+	if c.ex != nil || c.reconnect != nil {
+		resp, err := c.do(args...)
+		if err != nil {
+			return nil, err
+		}
+		out := make([][]byte, len(resp))
+		for i, v := range resp {
+			out[i] = []byte(v)
+		}
+		return out, nil
+	}
+	resp, err := c.Client.DoBytesContext(c.ctx, args...)
+	if err != nil {
+		return nil, newCommandError(args, err)
+	}
+	if len(resp) > 0 && string(resp[0]) == "ok" {
+		return resp, nil
+	}
+	// 只有出错时才需要把状态码和错误信息转换为 string
+	status := make([]string, 0, 2)
+	for i := 0; i < len(resp) && i < 2; i++ {
+		status = append(status, string(resp[i]))
+	}
+	return nil, respError(args, status)
+}
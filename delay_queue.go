@@ -0,0 +1,296 @@
+package gossdb_client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+//  DelayQueue 的默认选项
+const (
+	DefaultDelayQueueBatchSize    = 100
+	DefaultDelayQueuePollInterval = time.Second
+)
+
+//  延迟队列的选项
+type DelayQueueOptions struct {
+	// 每次从 zset 移动到就绪队列的最大个数, 默认 DefaultDelayQueueBatchSize
+	BatchSize int64
+	// 检查到期任务和空的就绪队列的间隔, 默认 DefaultDelayQueuePollInterval
+	PollInterval time.Duration
+	// Run 中并发执行 handler 的 worker 数, 默认 1
+	Concurrency int
+	// handler 返回错误时, 大于 0 则在这段时间之后重新执行任务, 否则丢弃任务
+	RetryDelay time.Duration
+	// Run 中处理任务或者访问 ssdb 出错时调用, 访问 ssdb 出错时 payload 为空. 可以为 nil
+	OnError func(payload string, err error)
+}
+
+//  延迟队列, 任务在指定的时间之后执行.
+//
+//  使用三个容器: name:delayed 是 zset, key 为任务 id, 权重为执行时间(unix 毫秒); name:jobs 是 hashmap, 保存任务 id 对应的内容;
+//  name:ready 是队列, 保存已经到期的任务内容, 可以用 Pop 或者 QPopFront 直接消费.
+//
+//  MoveDue 把到期的任务从 zset 移到就绪队列, 用 zdel 的返回值认领任务: 多个进程同时移动时 zdel 只对一个进程返回 1,
+//  因此每个任务只被移动一次. 读取内容或写入就绪队列失败时, 认领的任务按原来的执行时间放回 zset;
+//  认领后写入就绪队列之前进程崩溃时, 任务留在 name:jobs 中, 不会被执行.
+//
+//  c 应当可以被多个 goroutine 同时使用, 例如 Pool.Client(). 用法:
+//
+//	q := db.NewDelayQueue("mail", &gossdb_client.DelayQueueOptions{Concurrency: 4})
+//	q.Enqueue(ctx, payload, time.Now().Add(time.Hour))
+//	go q.Run(ctx, func(ctx context.Context, payload string) error { ... })
+type DelayQueue struct {
+	c       *DbClient
+	opts    DelayQueueOptions
+	delayed string
+	jobs    string
+	ready   string
+}
+
+//  创建延迟队列, 不会访问服务端
+//  name 队列的名字, 用作三个容器名字的前缀
+//  opts 选项, 可以为 nil
+func (c *DbClient) NewDelayQueue(name string, opts *DelayQueueOptions) *DelayQueue {
+	var o DelayQueueOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = DefaultDelayQueueBatchSize
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = DefaultDelayQueuePollInterval
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = 1
+	}
+	return &DelayQueue{
+		c:       c,
+		opts:    o,
+		delayed: name + ":delayed",
+		jobs:    name + ":jobs",
+		ready:   name + ":ready",
+	}
+}
+
+//  返回就绪队列的名字
+func (q *DelayQueue) ReadyQueue() string {
+	return q.ready
+}
+
+//  加入一个任务, 在 runAt 之后执行; runAt 已经过去时在下一次 MoveDue 时就绪
+//  返回 id, 任务的 id, 用于 Cancel
+//  返回 err，可能的错误，操作成功返回 nil
+func (q *DelayQueue) Enqueue(ctx context.Context, payload string, runAt time.Time) (string, error) {
+	id := randomToken()
+	c := q.c.WithContext(ctx)
+	p := c.Pipeline()
+	// 先写入内容再加入 zset, 保证移动时能读到内容
+	h := p.HSet(q.jobs, id, payload)
+	z := p.ZSet(q.delayed, id, runAt.UnixMilli())
+	if _, err := p.Exec(); err != nil {
+		return "", err
+	}
+	err := h.Err()
+	if err == nil {
+		err = z.Err()
+	}
+	if err != nil {
+		// 撤销已经写入的部分, 避免 zset 中留下没有内容的任务
+		p.ZDel(q.delayed, id)
+		p.HDel(q.jobs, id)
+		p.Exec()
+		return "", err
+	}
+	return id, nil
+}
+
+//  取消一个还没有就绪的任务
+//  返回 ok, 任务被取消; 任务不存在或者已经就绪时返回 false
+//  返回 err，可能的错误，操作成功返回 nil
+func (q *DelayQueue) Cancel(ctx context.Context, id string) (bool, error) {
+	p := q.c.WithContext(ctx).Pipeline()
+	r := p.ZDel(q.delayed, id)
+	if _, err := p.Exec(); err != nil {
+		return false, err
+	}
+	// 与 MoveDue 相同, zdel 返回 1 才认领了任务
+	if ok, err := r.Bool(); err != nil || !ok {
+		return false, err
+	}
+	return true, q.c.WithContext(ctx).HDel(q.jobs, id)
+}
+
+//  把最多 BatchSize 个到期的任务移到就绪队列, 按执行时间的顺序
+//  返回 n, 移动的任务数, 等于 BatchSize 时可能还有到期的任务
+//  返回 err，可能的错误，操作成功返回 nil
+func (q *DelayQueue) MoveDue(ctx context.Context) (int, error) {
+	c := q.c.WithContext(ctx)
+	ids, scores, err := c.ZScan(q.delayed, "", "", time.Now().UnixMilli(), q.opts.BatchSize)
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	p := c.Pipeline()
+	claims := make([]*Reply, len(ids))
+	payloads := make([]*Reply, len(ids))
+	for i, id := range ids {
+		claims[i] = p.ZDel(q.delayed, id)
+		payloads[i] = p.HGet(q.jobs, id)
+	}
+	if _, err = p.Exec(); err != nil {
+		return 0, err
+	}
+
+	push := []interface{}{"qpush_back", q.ready}
+	del := []interface{}{"multi_hdel", q.jobs}
+	// 认领了但没有写入就绪队列的任务按原来的执行时间放回 zset
+	var restore []interface{}
+	var moved []int
+	var failed error
+	for i, id := range ids {
+		if ok, err := claims[i].Bool(); err != nil || !ok {
+			// 被其它进程移走或者取消
+			continue
+		}
+		payload, err := payloads[i].String()
+		if errors.Is(err, ErrNotFound) {
+			// 没有内容的任务无法执行
+			continue
+		}
+		if err != nil {
+			restore = append(restore, id, scores[i])
+			failed = err
+			continue
+		}
+		push = append(push, payload)
+		del = append(del, id)
+		moved = append(moved, i)
+	}
+	n := len(moved)
+	if n > 0 {
+		// 写入就绪队列成功之后才删除内容
+		if _, err = c.Do(push...); err != nil {
+			for _, i := range moved {
+				restore = append(restore, ids[i], scores[i])
+			}
+			n, failed = 0, err
+		} else if _, err = c.Do(del...); err != nil {
+			failed = err
+		}
+	}
+	if len(restore) > 0 {
+		if _, err = c.Do(append([]interface{}{"multi_zset", q.delayed}, restore...)...); err != nil {
+			return n, err
+		}
+	}
+	return n, failed
+}
+
+//  从就绪队列的首部取出一个任务
+//  返回 payload, 任务的内容; 就绪队列为空时返回 ErrNotFound
+//  返回 err，可能的错误，操作成功返回 nil
+func (q *DelayQueue) Pop(ctx context.Context) (string, error) {
+	return q.c.WithContext(ctx).QPopFront(q.ready)
+}
+
+//  返回还没有到期的任务数和就绪队列的长度
+//  返回 err，可能的错误，操作成功返回 nil
+func (q *DelayQueue) Size(ctx context.Context) (delayed, ready int64, err error) {
+	p := q.c.WithContext(ctx).Pipeline()
+	z := p.Do("zsize", q.delayed)
+	r := p.QSize(q.ready)
+	if _, err = p.Exec(); err != nil {
+		return 0, 0, err
+	}
+	if delayed, err = z.Int64(); err != nil {
+		return 0, 0, err
+	}
+	ready, err = r.Int64()
+	return delayed, ready, err
+}
+
+//  移动到期的任务并用 Concurrency 个 worker 执行 handler, 阻塞到 ctx 取消.
+//  ctx 取消后不再取出新的任务, 等待正在执行的 handler 返回后退出; handler 收到的 ctx 不会因此被取消.
+//  handler 返回错误时调用 OnError, 并按 RetryDelay 重新加入任务
+//  返回 ctx.Err()
+func (q *DelayQueue) Run(ctx context.Context, handler func(ctx context.Context, payload string) error) error {
+	// 已经开始的 ssdb 操作和 handler 不受 ctx 取消的影响, 避免认领或取出的任务丢失
+	opCtx := context.WithoutCancel(ctx)
+	wake := make(chan struct{}, q.opts.Concurrency)
+
+	var wg sync.WaitGroup
+	wg.Add(1 + q.opts.Concurrency)
+	go func() {
+		defer wg.Done()
+		q.moveLoop(ctx, opCtx, wake)
+	}()
+	for i := 0; i < q.opts.Concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			q.workLoop(ctx, opCtx, wake, handler)
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+func (q *DelayQueue) moveLoop(ctx, opCtx context.Context, wake chan struct{}) {
+	for ctx.Err() == nil {
+		n, err := q.MoveDue(opCtx)
+		if err != nil {
+			q.onError("", err)
+		}
+		// 唤醒等待的 worker
+		for i := 0; i < n && i < cap(wake); i++ {
+			select {
+			case wake <- struct{}{}:
+			default:
+			}
+		}
+		if int64(n) == q.opts.BatchSize {
+			// 可能还有到期的任务
+			continue
+		}
+		t := time.NewTimer(q.opts.PollInterval)
+		select {
+		case <-ctx.Done():
+		case <-t.C:
+		}
+		t.Stop()
+	}
+}
+
+func (q *DelayQueue) workLoop(ctx, opCtx context.Context, wake chan struct{}, handler func(context.Context, string) error) {
+	for ctx.Err() == nil {
+		payload, err := q.Pop(opCtx)
+		if err != nil {
+			if !errors.Is(err, ErrNotFound) {
+				q.onError("", err)
+			}
+			t := time.NewTimer(q.opts.PollInterval)
+			select {
+			case <-ctx.Done():
+			case <-wake:
+			case <-t.C:
+			}
+			t.Stop()
+			continue
+		}
+		if err = handler(opCtx, payload); err != nil {
+			q.onError(payload, err)
+			if q.opts.RetryDelay > 0 {
+				if _, err = q.Enqueue(opCtx, payload, time.Now().Add(q.opts.RetryDelay)); err != nil {
+					q.onError(payload, err)
+				}
+			}
+		}
+	}
+}
+
+func (q *DelayQueue) onError(payload string, err error) {
+	if q.opts.OnError != nil {
+		q.opts.OnError(payload, err)
+	}
+}
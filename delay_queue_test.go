@@ -0,0 +1,238 @@
+package gossdb_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDelayQueue(t *testing.T) {
+	db := newTestPoolClient(t)
+	ctx := context.Background()
+	q := db.NewDelayQueue("dq", nil)
+
+	now := time.Now()
+	q.Enqueue(ctx, "second", now.Add(-time.Second))
+	q.Enqueue(ctx, "first", now.Add(-time.Minute))
+	later, err := q.Enqueue(ctx, "later", now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Enqueue fail err: %v", err)
+	}
+	if delayed, ready, _ := q.Size(ctx); delayed != 3 || ready != 0 {
+		t.Errorf("Size = %d, %d", delayed, ready)
+	}
+
+	n, err := q.MoveDue(ctx)
+	if err != nil || n != 2 {
+		t.Fatalf("MoveDue = %d, %v", n, err)
+	}
+	if n, _ = q.MoveDue(ctx); n != 0 {
+		t.Errorf("second MoveDue = %d", n)
+	}
+	for _, want := range []string{"first", "second"} {
+		if got, err := q.Pop(ctx); err != nil || got != want {
+			t.Errorf("Pop = %q, %v, want %q", got, err, want)
+		}
+	}
+	if _, err = q.Pop(ctx); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Pop on empty queue err = %v", err)
+	}
+
+	if ok, err := q.Cancel(ctx, later); !ok || err != nil {
+		t.Errorf("Cancel = %v, %v", ok, err)
+	}
+	if ok, _ := q.Cancel(ctx, later); ok {
+		t.Error("second Cancel should return false")
+	}
+	if delayed, ready, _ := q.Size(ctx); delayed != 0 || ready != 0 {
+		t.Errorf("Size after Cancel = %d, %d", delayed, ready)
+	}
+	if size, _ := db.HSize("dq:jobs"); size != 0 {
+		t.Errorf("jobs hash size = %d, want 0", size)
+	}
+}
+
+func TestDelayQueueConcurrentMove(t *testing.T) {
+	db := newTestPoolClient(t)
+	ctx := context.Background()
+	q := db.NewDelayQueue("dq", &DelayQueueOptions{BatchSize: 7})
+	const total = 200
+	for i := 0; i < total; i++ {
+		q.Enqueue(ctx, fmt.Sprint(i), time.Now().Add(-time.Second))
+	}
+
+	var mu sync.Mutex
+	moved := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				n, err := q.MoveDue(ctx)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if n == 0 {
+					return
+				}
+				mu.Lock()
+				moved += n
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if moved != total {
+		t.Errorf("moved %d, want %d", moved, total)
+	}
+	items, _ := db.QRange(q.ReadyQueue(), 0, total*2)
+	seen := map[string]bool{}
+	for _, v := range items {
+		if seen[v] {
+			t.Fatalf("%s moved twice", v)
+		}
+		seen[v] = true
+	}
+	if len(seen) != total {
+		t.Errorf("ready queue has %d items, want %d", len(seen), total)
+	}
+}
+
+func TestDelayQueueRun(t *testing.T) {
+	db := newTestPoolClient(t)
+	q := db.NewDelayQueue("dq", &DelayQueueOptions{
+		Concurrency:  3,
+		PollInterval: 10 * time.Millisecond,
+		RetryDelay:   10 * time.Millisecond,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	for i := 0; i < 5; i++ {
+		q.Enqueue(ctx, fmt.Sprint(i), time.Now())
+	}
+	q.Enqueue(ctx, "flaky", time.Now().Add(20*time.Millisecond))
+	q.Enqueue(ctx, "slow", time.Now())
+
+	var mu sync.Mutex
+	var done []string
+	failed := false
+	flakyDone := make(chan struct{})
+	release := make(chan struct{})
+	started := make(chan struct{})
+	all := make(chan struct{})
+	handler := func(ctx context.Context, payload string) error {
+		switch payload {
+		case "flaky":
+			mu.Lock()
+			first := !failed
+			failed = true
+			mu.Unlock()
+			if first {
+				return errors.New("try again")
+			}
+			close(flakyDone)
+		case "slow":
+			// 等到重试的任务完成后再关闭
+			<-flakyDone
+			close(started)
+			<-release
+			if ctx.Err() != nil {
+				t.Error("handler ctx cancelled on shutdown")
+			}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		done = append(done, payload)
+		if len(done) == 7 {
+			close(all)
+		}
+		return nil
+	}
+
+	ret := make(chan error, 1)
+	go func() { ret <- q.Run(ctx, handler) }()
+
+	// slow 正在执行时关闭, Run 等待它完成
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("slow job not started")
+	}
+	cancel()
+	select {
+	case err := <-ret:
+		t.Fatalf("Run returned before the handler finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case err := <-ret:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Run err = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return")
+	}
+
+	select {
+	case <-all:
+	default:
+		t.Fatalf("handled %v", done)
+	}
+	sort.Strings(done)
+	want := []string{"0", "1", "2", "3", "4", "flaky", "slow"}
+	if fmt.Sprint(done) != fmt.Sprint(want) {
+		t.Errorf("handled %v, want %v", done, want)
+	}
+}
+
+func TestDelayQueueFailures(t *testing.T) {
+	db, s := newTestClient(t)
+	ctx := context.Background()
+	q := db.NewDelayQueue("dq", nil)
+	size := func() (delayed, jobs, ready int64) {
+		delayed, ready, _ = q.Size(ctx)
+		jobs, _ = db.HSize("dq:jobs")
+		return
+	}
+
+	// 写入失败时撤销已经写入的部分
+	for _, cmd := range []string{"hset", "zset"} {
+		s.FailCommand(cmd, "error")
+		if _, err := q.Enqueue(ctx, "x", time.Now()); err == nil {
+			t.Errorf("Enqueue with %s failing should fail", cmd)
+		}
+		s.FailCommand(cmd, "")
+		if d, j, _ := size(); d != 0 || j != 0 {
+			t.Errorf("%s failing: delayed %d, jobs %d", cmd, d, j)
+		}
+	}
+
+	// 读取内容或写入就绪队列失败时任务放回 zset, 内容保留
+	runAt := time.Now().Add(-time.Minute)
+	id, _ := q.Enqueue(ctx, "x", runAt)
+	for _, cmd := range []string{"hget", "qpush_back"} {
+		s.FailCommand(cmd, "error")
+		if n, err := q.MoveDue(ctx); err == nil || n != 0 {
+			t.Errorf("MoveDue with %s failing = %d, %v", cmd, n, err)
+		}
+		s.FailCommand(cmd, "")
+		if d, j, r := size(); d != 1 || j != 1 || r != 0 {
+			t.Errorf("%s failing: delayed %d, jobs %d, ready %d", cmd, d, j, r)
+		}
+		if score, _ := db.ZGet("dq:delayed", id); score != runAt.UnixMilli() {
+			t.Errorf("%s failing: score = %d, want %d", cmd, score, runAt.UnixMilli())
+		}
+	}
+	if n, err := q.MoveDue(ctx); err != nil || n != 1 {
+		t.Fatalf("MoveDue = %d, %v", n, err)
+	}
+	if d, j, r := size(); d != 0 || j != 0 || r != 1 {
+		t.Errorf("after MoveDue: delayed %d, jobs %d, ready %d", d, j, r)
+	}
+}
@@ -0,0 +1,568 @@
+package gossdb_client
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+//  备份文件格式:
+//
+//	头部: "SSDBDUMP" + 版本(1 字节) + 标志(1 字节, 第 0 位表示之后的内容经过 gzip 压缩)
+//	数据块: 长度(uvarint) + 若干条记录 + crc32c(4 字节, 大端), 恢复时先校验整个块再写入
+//	结尾: 长度 0 + 记录总数(uvarint)
+//
+//  每条记录是一个元素: 类型(1 字节) + 若干个长度(uvarint)前缀的字段.
+//  kv 为 key、value、过期的 unix 时间(varint, 0 表示不过期); hashmap 为名字、key、value;
+//  zset 为名字、key、权重(服务端返回的十进制字符串, 原样恢复); 队列为名字、value.
+const (
+	dumpMagic   = "SSDBDUMP"
+	DumpVersion = 1
+
+	dumpFlagGzip = 1
+
+	dumpKV    = 1
+	dumpHash  = 2
+	dumpZSet  = 3
+	dumpQueue = 4
+
+	// 数据块大小的上限
+	dumpChunkBytes = 1 << 20
+)
+
+var (
+	// 不是备份文件, 或者文件被截断
+	ErrDumpFormat = errors.New("gossdb_client: invalid dump file")
+	// 数据块的校验和不一致
+	ErrDumpChecksum = errors.New("gossdb_client: dump checksum mismatch")
+)
+
+var dumpCRC = crc32.MakeTable(crc32.Castagnoli)
+
+//  备份的选项
+type DumpOptions struct {
+	// 使用 gzip 压缩
+	Compress bool
+	// 每次请求的元素个数, 也是每个数据块最多的记录数, 0 表示 DefaultScanPageSize
+	PageSize int64
+	// 每写完一个数据块后调用, 可以为 nil
+	Progress func(DumpStats)
+}
+
+//  备份或恢复的统计, 每个元素是一条记录
+type DumpStats struct {
+	Records int64
+	KV      int64
+	Hash    int64
+	ZSet    int64
+	Queue   int64
+}
+
+func (s *DumpStats) add(kind byte) {
+	s.Records++
+	switch kind {
+	case dumpKV:
+		s.KV++
+	case dumpHash:
+		s.Hash++
+	case dumpZSet:
+		s.ZSet++
+	case dumpQueue:
+		s.Queue++
+	}
+}
+
+//  把所有的 kv、hashmap、zset、队列分页读出写入 w, kv 保留过期时间.
+//  使用 scan 等命令分页读取, 不是快照: 备份期间写入的数据可能不在备份中
+//  opts 可以为 nil
+//  返回 stats, 备份的记录数
+//  返回 err，可能的错误，操作成功返回 nil
+func (c *DbClient) Dump(ctx context.Context, w io.Writer, opts *DumpOptions) (*DumpStats, error) {
+	var o DumpOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.PageSize <= 0 {
+		o.PageSize = DefaultScanPageSize
+	}
+	c = c.WithContext(ctx)
+
+	header := []byte(dumpMagic + "\x00\x00")
+	header[len(dumpMagic)] = DumpVersion
+	if o.Compress {
+		header[len(dumpMagic)+1] = dumpFlagGzip
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	dw := &dumpWriter{w: w, max: o.PageSize, progress: o.Progress}
+	var gz *gzip.Writer
+	if o.Compress {
+		gz = gzip.NewWriter(w)
+		dw.w = gz
+	}
+
+	for _, dump := range []func(*dumpWriter, *ScanOptions) error{c.dumpKV, c.dumpHashes, c.dumpZSets, c.dumpQueues} {
+		if err := dump(dw, &ScanOptions{PageSize: o.PageSize}); err != nil {
+			return &dw.stats, err
+		}
+	}
+	if err := dw.close(); err != nil {
+		return &dw.stats, err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return &dw.stats, err
+		}
+	}
+	return &dw.stats, nil
+}
+
+func (c *DbClient) dumpKV(dw *dumpWriter, opts *ScanOptions) error {
+	var keys, values []string
+	// 每页的 ttl 用一个管道查询
+	flush := func() error {
+		p := c.Pipeline()
+		replies := make([]*Reply, len(keys))
+		for i, k := range keys {
+			replies[i] = p.Do("ttl", k)
+		}
+		if _, err := p.Exec(); err != nil {
+			return err
+		}
+		now := time.Now().Unix()
+		for i, k := range keys {
+			ttl, err := replies[i].Int64()
+			if err != nil {
+				return err
+			}
+			var expireAt int64
+			if ttl > 0 {
+				expireAt = now + ttl
+			}
+			if err = dw.record(dumpKV, k, values[i], expireAt); err != nil {
+				return err
+			}
+		}
+		keys, values = keys[:0], values[:0]
+		return nil
+	}
+	it := c.ScanIter(opts)
+	for it.Next() {
+		keys = append(keys, it.Key())
+		values = append(values, it.Value())
+		if int64(len(keys)) >= opts.PageSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+func (c *DbClient) dumpHashes(dw *dumpWriter, opts *ScanOptions) error {
+	names := c.HListIter(opts)
+	for names.Next() {
+		name := names.Key()
+		it := c.HScanIter(name, opts)
+		for it.Next() {
+			if err := dw.record(dumpHash, name, it.Key(), it.Value()); err != nil {
+				return err
+			}
+		}
+		if err := it.Err(); err != nil {
+			return err
+		}
+	}
+	return names.Err()
+}
+
+func (c *DbClient) dumpZSets(dw *dumpWriter, opts *ScanOptions) error {
+	names := c.ZListIter(opts)
+	for names.Next() {
+		name := names.Key()
+		it := c.ZScanIter(name, NegInf, PosInf, opts)
+		for it.Next() {
+			if err := dw.record(dumpZSet, name, it.Key(), it.Value()); err != nil {
+				return err
+			}
+		}
+		if err := it.Err(); err != nil {
+			return err
+		}
+	}
+	return names.Err()
+}
+
+func (c *DbClient) dumpQueues(dw *dumpWriter, opts *ScanOptions) error {
+	names := c.QListIter(opts)
+	n := int(opts.PageSize)
+	for names.Next() {
+		name := names.Key()
+		for offset := 0; ; offset += n {
+			items, err := c.QRange(name, offset, n)
+			if err != nil {
+				return err
+			}
+			for _, v := range items {
+				if err = dw.record(dumpQueue, name, v); err != nil {
+					return err
+				}
+			}
+			if len(items) < n {
+				break
+			}
+		}
+	}
+	return names.Err()
+}
+
+type dumpWriter struct {
+	w        io.Writer
+	chunk    bytes.Buffer
+	n        int64 // 当前数据块中的记录数
+	max      int64
+	stats    DumpStats
+	progress func(DumpStats)
+	tmp      [binary.MaxVarintLen64]byte
+}
+
+//  写入一条记录, 字段可以是 string 或 int64(varint)
+func (dw *dumpWriter) record(kind byte, fields ...interface{}) error {
+	dw.chunk.WriteByte(kind)
+	for _, f := range fields {
+		switch f := f.(type) {
+		case string:
+			dw.chunk.Write(dw.tmp[:binary.PutUvarint(dw.tmp[:], uint64(len(f)))])
+			dw.chunk.WriteString(f)
+		case int64:
+			dw.chunk.Write(dw.tmp[:binary.PutVarint(dw.tmp[:], f)])
+		}
+	}
+	dw.stats.add(kind)
+	if dw.n++; dw.n >= dw.max || dw.chunk.Len() >= dumpChunkBytes {
+		return dw.flush()
+	}
+	return nil
+}
+
+func (dw *dumpWriter) flush() error {
+	if dw.n == 0 {
+		return nil
+	}
+	data := dw.chunk.Bytes()
+	var buf bytes.Buffer
+	buf.Write(dw.tmp[:binary.PutUvarint(dw.tmp[:], uint64(len(data)))])
+	buf.Write(data)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.Checksum(data, dumpCRC))
+	buf.Write(sum[:])
+	if _, err := dw.w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	dw.chunk.Reset()
+	dw.n = 0
+	if dw.progress != nil {
+		dw.progress(dw.stats)
+	}
+	return nil
+}
+
+func (dw *dumpWriter) close() error {
+	if err := dw.flush(); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	buf.WriteByte(0)
+	buf.Write(dw.tmp[:binary.PutUvarint(dw.tmp[:], uint64(dw.stats.Records))])
+	_, err := dw.w.Write(buf.Bytes())
+	return err
+}
+
+//  恢复的选项
+type RestoreOptions struct {
+	// 跳过前面这么多条记录, 用于从中断的位置继续, 通常是上次 Progress 收到的 Records
+	Skip int64
+	// 每写完一个数据块后调用, 此时 Records 之前的记录都已经写入, 可以为 nil
+	Progress func(DumpStats)
+}
+
+//  一条记录
+type dumpRecord struct {
+	kind     byte
+	name     string // hashmap、zset、队列的名字
+	key      string
+	value    string // zset 为权重
+	expireAt int64
+}
+
+//  从 Dump 生成的备份中恢复数据, 与已有的数据合并. 每个数据块校验通过后用一个管道写入,
+//  kv 用 multi_set(有过期时间的用 setx, 已经过期的丢弃), hashmap 用 multi_hset, zset 用 multi_zset, 队列用 qpush_back.
+//  中断后把上次 Progress 收到的 Records 作为 opts.Skip 重新执行即可继续, 中断时写了一半的队列会被修正, 不会重复;
+//  修正时假定这些队列在恢复之前不存在
+//  opts 可以为 nil
+//  返回 stats, 已经写入的记录数, 包括跳过的记录, 出错时可以作为 opts.Skip 继续
+//  返回 err，可能的错误，操作成功返回 nil
+func (c *DbClient) Restore(ctx context.Context, r io.Reader, opts *RestoreOptions) (*DumpStats, error) {
+	var o RestoreOptions
+	if opts != nil {
+		o = *opts
+	}
+	c = c.WithContext(ctx)
+	dr, err := newDumpReader(r)
+	if err != nil {
+		return nil, err
+	}
+	var stats DumpStats
+	// 跳过的记录中最后一个队列, 以及它已经写入的元素个数
+	var lastQueue string
+	var lastQueueSize int64
+	fixQueues := o.Skip > 0
+	for {
+		if err = ctx.Err(); err != nil {
+			return &stats, err
+		}
+		records, err := dr.next()
+		if err == io.EOF {
+			return &stats, nil
+		}
+		if err != nil {
+			return &stats, err
+		}
+		var apply []dumpRecord
+		next := stats
+		for _, rec := range records {
+			if next.Records < o.Skip {
+				if rec.kind == dumpQueue {
+					if rec.name != lastQueue {
+						lastQueue, lastQueueSize = rec.name, 0
+					}
+					lastQueueSize++
+				}
+			} else {
+				apply = append(apply, rec)
+			}
+			next.add(rec.kind)
+		}
+		if len(apply) == 0 {
+			stats = next
+			continue
+		}
+		if fixQueues {
+			// 中断时正在写入的数据块可能只写了一部分, 队列多出的元素需要删除
+			if err = c.trimRestoredQueues(apply, lastQueue, lastQueueSize); err != nil {
+				return &stats, err
+			}
+			fixQueues = false
+		}
+		if err = c.restoreRecords(apply); err != nil {
+			return &stats, err
+		}
+		stats = next
+		if o.Progress != nil {
+			o.Progress(stats)
+		}
+	}
+}
+
+//  把数据块中每个队列的长度恢复为写入这个数据块之前的长度
+func (c *DbClient) trimRestoredQueues(records []dumpRecord, lastQueue string, lastQueueSize int64) error {
+	seen := make(map[string]bool)
+	for _, rec := range records {
+		if rec.kind != dumpQueue || seen[rec.name] {
+			continue
+		}
+		seen[rec.name] = true
+		var want int64
+		if rec.name == lastQueue {
+			want = lastQueueSize
+		}
+		size, err := c.Qsize(rec.name)
+		if err != nil {
+			return err
+		}
+		if size > want {
+			if _, err = c.QTrimBack(rec.name, int(size-want)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *DbClient) restoreRecords(records []dumpRecord) error {
+	p := c.Pipeline()
+	now := time.Now().Unix()
+	var kvs []interface{}
+	var last *dumpRecord
+	var args []interface{}
+	// 连续的同一个容器的元素合并为一条命令
+	flush := func() {
+		if len(args) > 0 {
+			p.Do(args...)
+		}
+		args = nil
+	}
+	for i := range records {
+		rec := &records[i]
+		if rec.kind == dumpKV {
+			switch {
+			case rec.expireAt == 0:
+				kvs = append(kvs, rec.key, rec.value)
+			case rec.expireAt > now:
+				p.Do("setx", rec.key, rec.value, rec.expireAt-now)
+			}
+			continue
+		}
+		if last == nil || last.kind != rec.kind || last.name != rec.name {
+			flush()
+			switch rec.kind {
+			case dumpHash:
+				args = []interface{}{"multi_hset", rec.name}
+			case dumpZSet:
+				args = []interface{}{"multi_zset", rec.name}
+			case dumpQueue:
+				args = []interface{}{"qpush_back", rec.name}
+			}
+		}
+		last = rec
+		switch rec.kind {
+		case dumpHash:
+			args = append(args, rec.key, rec.value)
+		case dumpZSet:
+			args = append(args, rec.key, rec.value)
+		case dumpQueue:
+			args = append(args, rec.value)
+		}
+	}
+	flush()
+	if len(kvs) > 0 {
+		p.Do(append([]interface{}{"multi_set"}, kvs...)...)
+	}
+	replies, err := p.Exec()
+	if err != nil {
+		return err
+	}
+	for _, r := range replies {
+		if err = r.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type dumpReader struct {
+	r       *bufio.Reader
+	records int64
+}
+
+func newDumpReader(r io.Reader) (*dumpReader, error) {
+	header := make([]byte, len(dumpMagic)+2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, ErrDumpFormat
+	}
+	if string(header[:len(dumpMagic)]) != dumpMagic {
+		return nil, ErrDumpFormat
+	}
+	if v := header[len(dumpMagic)]; v != DumpVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrDumpFormat, v)
+	}
+	if header[len(dumpMagic)+1]&dumpFlagGzip != 0 {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrDumpFormat, err)
+		}
+		r = gz
+	}
+	return &dumpReader{r: bufio.NewReader(r)}, nil
+}
+
+//  读取并校验一个数据块, 读到结尾时返回 io.EOF
+func (dr *dumpReader) next() ([]dumpRecord, error) {
+	size, err := binary.ReadUvarint(dr.r)
+	if err != nil {
+		return nil, ErrDumpFormat
+	}
+	if size == 0 {
+		total, err := binary.ReadUvarint(dr.r)
+		if err != nil || int64(total) != dr.records {
+			return nil, ErrDumpFormat
+		}
+		return nil, io.EOF
+	}
+	if size > 4*dumpChunkBytes {
+		return nil, ErrDumpFormat
+	}
+	data := make([]byte, size+4)
+	if _, err = io.ReadFull(dr.r, data); err != nil {
+		return nil, ErrDumpFormat
+	}
+	data, sum := data[:size], data[size:]
+	if crc32.Checksum(data, dumpCRC) != binary.BigEndian.Uint32(sum) {
+		return nil, ErrDumpChecksum
+	}
+
+	var records []dumpRecord
+	d := dumpDecoder{data: data}
+	for len(d.data) > 0 && d.err == nil {
+		rec := dumpRecord{kind: d.data[0]}
+		d.data = d.data[1:]
+		switch rec.kind {
+		case dumpKV:
+			rec.key, rec.value, rec.expireAt = d.string(), d.string(), d.varint()
+		case dumpHash:
+			rec.name, rec.key, rec.value = d.string(), d.string(), d.string()
+		case dumpZSet:
+			rec.name, rec.key, rec.value = d.string(), d.string(), d.string()
+		case dumpQueue:
+			rec.name, rec.value = d.string(), d.string()
+		default:
+			d.err = ErrDumpFormat
+		}
+		records = append(records, rec)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	dr.records += int64(len(records))
+	return records, nil
+}
+
+type dumpDecoder struct {
+	data []byte
+	err  error
+}
+
+func (d *dumpDecoder) string() string {
+	n, size := binary.Uvarint(d.data)
+	if size <= 0 || uint64(len(d.data)-size) < n {
+		d.err = ErrDumpFormat
+		d.data = nil
+		return ""
+	}
+	s := string(d.data[size : size+int(n)])
+	d.data = d.data[size+int(n):]
+	return s
+}
+
+func (d *dumpDecoder) varint() int64 {
+	v, size := binary.Varint(d.data)
+	if size <= 0 {
+		d.err = ErrDumpFormat
+		d.data = nil
+		return 0
+	}
+	d.data = d.data[size:]
+	return v
+}
+
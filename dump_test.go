@@ -0,0 +1,151 @@
+package gossdb_client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func fillDumpData(t *testing.T, db *DbClient) {
+	for i := 0; i < 25; i++ {
+		k := fmt.Sprintf("k%02d", i)
+		if i%5 == 0 {
+			db.Set(k, "\x00bin\n"+k, 1000)
+		} else {
+			db.Set(k, k)
+		}
+		db.HSet("h", k, i)
+		db.ZSetFloat("z", k, float64(i)*100-1000)
+	}
+	for i := 0; i < 12; i++ {
+		db.QPush("q1", fmt.Sprintf("a%d", i))
+		db.QPush("q2", fmt.Sprintf("b%d", i))
+	}
+}
+
+func checkDumpData(t *testing.T, db *DbClient) {
+	t.Helper()
+	for i := 0; i < 25; i++ {
+		k := fmt.Sprintf("k%02d", i)
+		v, err := db.Get(k)
+		ttl, _ := db.Ttl(k)
+		if i%5 == 0 {
+			if v != "\x00bin\n"+k || ttl <= 0 || ttl > 1000 {
+				t.Fatalf("%s = %q ttl %d, %v", k, v, ttl, err)
+			}
+		} else if v != k || ttl != -1 {
+			t.Fatalf("%s = %q ttl %d, %v", k, v, ttl, err)
+		}
+	}
+	if n, _ := db.HSize("h"); n != 25 {
+		t.Fatalf("HSize = %d", n)
+	}
+	if s, err := db.ZGetFloat("z", "k07"); err != nil || s != -300 {
+		t.Fatalf("ZGetFloat = %v, %v", s, err)
+	}
+	for _, q := range []string{"q1", "q2"} {
+		items, _ := db.QSlice(q, 0, -1)
+		if len(items) != 12 || items[11] != fmt.Sprintf("%c11", q[1]-'1'+'a') {
+			t.Fatalf("%s = %v", q, items)
+		}
+	}
+}
+
+func TestDumpRestore(t *testing.T) {
+	src, _ := newTestClient(t)
+	fillDumpData(t, src)
+	ctx := context.Background()
+
+	for _, compress := range []bool{false, true} {
+		var buf bytes.Buffer
+		var chunks int
+		stats, err := src.Dump(ctx, &buf, &DumpOptions{Compress: compress, PageSize: 10, Progress: func(DumpStats) { chunks++ }})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := DumpStats{Records: 99, KV: 25, Hash: 25, ZSet: 25, Queue: 24}
+		if *stats != want || chunks != 10 {
+			t.Fatalf("Dump stats = %+v, %d chunks", stats, chunks)
+		}
+
+		dst, _ := newTestClient(t)
+		stats, err = dst.Restore(ctx, bytes.NewReader(buf.Bytes()), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *stats != want {
+			t.Fatalf("Restore stats = %+v", stats)
+		}
+		checkDumpData(t, dst)
+	}
+}
+
+func TestRestoreErrors(t *testing.T) {
+	src, _ := newTestClient(t)
+	fillDumpData(t, src)
+	ctx := context.Background()
+	var buf bytes.Buffer
+	if _, err := src.Dump(ctx, &buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	dst, _ := newTestClient(t)
+	if _, err := dst.Restore(ctx, bytes.NewReader([]byte("not a dump")), nil); !errors.Is(err, ErrDumpFormat) {
+		t.Fatalf("bad header err = %v", err)
+	}
+	if _, err := dst.Restore(ctx, bytes.NewReader(data[:len(data)-3]), nil); !errors.Is(err, ErrDumpFormat) {
+		t.Fatalf("truncated err = %v", err)
+	}
+	bad := append([]byte(nil), data...)
+	bad[40] ^= 0xff
+	if _, err := dst.Restore(ctx, bytes.NewReader(bad), nil); !errors.Is(err, ErrDumpChecksum) {
+		t.Fatalf("corrupted err = %v", err)
+	}
+	// 校验失败的数据块不会被写入
+	if keys, _ := dst.Keys("", "", -1); len(keys) != 0 {
+		t.Fatalf("keys written from a corrupted chunk: %v", keys)
+	}
+}
+
+func TestRestoreResume(t *testing.T) {
+	src, _ := newTestClient(t)
+	fillDumpData(t, src)
+	ctx := context.Background()
+	var buf bytes.Buffer
+	if _, err := src.Dump(ctx, &buf, &DumpOptions{PageSize: 8}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 写到 q1 的中间时中断
+	dst, _ := newTestClient(t)
+	cctx, cancel := context.WithCancel(ctx)
+	var done int64
+	_, err := dst.Restore(cctx, bytes.NewReader(buf.Bytes()), &RestoreOptions{Progress: func(s DumpStats) {
+		done = s.Records
+		if s.Queue > 0 {
+			cancel()
+		}
+	}})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v", err)
+	}
+	// 模拟下一个数据块只写入了一部分
+	dst.QPush("q1", "partial", "partial")
+	dst.QPush("q2", "partial")
+
+	stats, err := dst.Restore(ctx, bytes.NewReader(buf.Bytes()), &RestoreOptions{Skip: done})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Records != 99 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	checkDumpData(t, dst)
+	if items, _ := dst.QSlice("q1", 0, -1); !reflect.DeepEqual(items[:2], []string{"a0", "a1"}) {
+		t.Fatalf("q1 = %v", items)
+	}
+}
@@ -0,0 +1,102 @@
+package gossdb_client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// 服务端返回 not_found, 要获取的 key 不存在
+	ErrNotFound = errors.New("ssdb: not found")
+	// 服务端返回 client_error, 通常是参数错误
+	ErrClientError = errors.New("ssdb: client error")
+	// 服务端返回 error 或 fail, 服务端执行命令出错
+	ErrServerError = errors.New("ssdb: server error")
+	// 没有认证或者密码错误
+	ErrAuth = errors.New("ssdb: authentication failed")
+	// 无法解析的响应
+	ErrProtocol = errors.New("ssdb: protocol error")
+)
+
+//  命令执行失败时返回的错误, 可以通过 errors.Is 判断错误类型, 例如 errors.Is(err, ErrNotFound).
+//  网络错误时 Code 为空, Err 为底层的网络错误.
+type CommandError struct {
+	Cmd  string        // 命令名
+	Args []interface{} // 命令参数, 不包括命令名
+	Code string        // 服务端返回的状态码, 如 not_found, client_error
+	Msg  string        // 服务端返回的错误信息, 可能为空
+	Err  error         // ErrNotFound 等错误类型, 或者底层的网络错误
+}
+
+func (e *CommandError) Error() string {
+	var b strings.Builder
+	b.WriteString("ssdb")
+	if e.Cmd != "" {
+		b.WriteString(" ")
+		b.WriteString(e.Cmd)
+	}
+	for i, arg := range e.Args {
+		if i == 3 {
+			fmt.Fprintf(&b, " ...(%d args)", len(e.Args))
+			break
+		}
+		fmt.Fprintf(&b, " %v", arg)
+	}
+	b.WriteString(": ")
+	if e.Code != "" {
+		b.WriteString(e.Code)
+		if e.Msg != "" {
+			b.WriteString(" ")
+			b.WriteString(e.Msg)
+		}
+	} else if e.Err != nil {
+		b.WriteString(e.Err.Error())
+	}
+	return b.String()
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+//  根据响应的状态码生成错误, 状态码为 ok 时返回 nil
+func respError(args []interface{}, resp []string) error {
+	if len(resp) > 0 && resp[0] == "ok" {
+		return nil
+	}
+	e := newCommandError(args, nil)
+	if len(resp) == 0 {
+		e.Err = ErrProtocol
+		return e
+	}
+	e.Code = resp[0]
+	if len(resp) > 1 {
+		e.Msg = resp[1]
+	}
+	switch resp[0] {
+	case "not_found":
+		e.Err = ErrNotFound
+	case "client_error":
+		e.Err = ErrClientError
+	case "noauth":
+		e.Err = ErrAuth
+	case "error", "fail", "server_error":
+		e.Err = ErrServerError
+		if e.Cmd == "auth" {
+			e.Err = ErrAuth
+		}
+	default:
+		e.Err = ErrProtocol
+	}
+	return e
+}
+
+func newCommandError(args []interface{}, err error) *CommandError {
+	e := &CommandError{Err: err}
+	if len(args) > 0 {
+		e.Cmd = fmt.Sprint(args[0])
+		e.Args = args[1:]
+	}
+	return e
+}
@@ -5,14 +5,33 @@ package ssdb
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"net"
 	"strconv"
+	"time"
 )
 
+// ErrBroken is returned by Do when a previous call was interrupted
+// (context cancelled, deadline exceeded or an I/O error) and the
+// connection may hold a partial request or reply.
+var ErrBroken = errors.New("ssdb: connection is broken")
+
+// ErrBadArguments is returned when an argument has a type that can not
+// be encoded. Nothing is written in that case, so the connection stays
+// usable.
+var ErrBadArguments = errors.New("ssdb: bad arguments")
+
+// aLongTimeAgo is a non-zero time in the past, used to interrupt
+// blocked reads and writes immediately.
+var aLongTimeAgo = time.Unix(1, 0)
+
 type Client struct {
+	addr     *net.TCPAddr
 	sock     *net.TCPConn
 	recv_buf bytes.Buffer
+	broken   bool
 }
 
 func Connect(ip string, port int) (*Client, error) {
@@ -25,19 +44,143 @@ func Connect(ip string, port int) (*Client, error) {
 		return nil, err
 	}
 	var c Client
+	c.addr = addr
 	c.sock = sock
 	return &c, nil
 }
 
+// Reconnect closes the current connection and dials the same address
+// again, in place, so every holder of c sees the new connection. The
+// new connection is not authenticated.
+func (c *Client) Reconnect() error {
+	c.sock.Close()
+	sock, err := net.DialTCP("tcp", nil, c.addr)
+	if err != nil {
+		c.broken = true
+		return err
+	}
+	c.sock = sock
+	c.recv_buf.Reset()
+	c.broken = false
+	return nil
+}
+
 func (c *Client) Do(args ...interface{}) ([]string, error) {
+	if c.broken {
+		return nil, ErrBroken
+	}
 	err := c.send(args)
 	if err != nil {
 		return nil, err
 	}
 	resp, err := c.recv()
+	if err != nil {
+		c.broken = true
+	}
+	return resp, err
+}
+
+// DoContext is like Do, but gives up when ctx is cancelled or its
+// deadline passes. An interrupted call leaves the connection broken,
+// since the request may have been partially written or the reply
+// partially read; the caller should Close it and dial again.
+func (c *Client) DoContext(ctx context.Context, args ...interface{}) ([]string, error) {
+	var resp []string
+	err := c.run(ctx, func() (err error) {
+		resp, err = c.Do(args...)
+		return err
+	})
 	return resp, err
 }
 
+// DoPipeline writes all cmds in a single write, then reads one reply
+// per command, in order. It fails as a whole on I/O errors; server side
+// errors are left in the individual replies.
+func (c *Client) DoPipeline(ctx context.Context, cmds [][]interface{}) ([][]string, error) {
+	if len(cmds) == 0 {
+		return nil, nil
+	}
+	var resps [][]string
+	err := c.run(ctx, func() error {
+		if c.broken {
+			return ErrBroken
+		}
+		var buf bytes.Buffer
+		for _, args := range cmds {
+			if err := encode(&buf, args); err != nil {
+				return err
+			}
+		}
+		if err := c.write(buf.Bytes()); err != nil {
+			return err
+		}
+		resps = make([][]string, 0, len(cmds))
+		for range cmds {
+			resp, err := c.recv()
+			if err != nil {
+				c.broken = true
+				return err
+			}
+			resps = append(resps, resp)
+		}
+		return nil
+	})
+	return resps, err
+}
+
+// run calls fn, interrupting its socket I/O when ctx is done.
+func (c *Client) run(ctx context.Context, fn func() error) error {
+	if ctx == nil || ctx.Done() == nil {
+		return fn()
+	}
+	if c.broken {
+		return ErrBroken
+	}
+	if err := ctx.Err(); err != nil {
+		// nothing has been sent yet, the connection is still usable
+		return err
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		c.sock.SetDeadline(dl)
+	}
+	// Deferred calls run after the watcher has exited, so clearing the
+	// deadline cannot race with it. Errors that leave the connection
+	// usable, such as ErrBadArguments, must not leave a deadline behind.
+	defer c.sock.SetDeadline(time.Time{})
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.sock.SetDeadline(aLongTimeAgo)
+		case <-stop:
+		}
+		close(done)
+	}()
+
+	err := fn()
+	close(stop)
+	<-done
+
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			return context.DeadlineExceeded
+		}
+		return err
+	}
+	return nil
+}
+
+// IsBroken reports whether the connection was left unusable by a failed
+// or interrupted call.
+func (c *Client) IsBroken() bool {
+	return c.broken
+}
+
 func (c *Client) Set(key string, val string) (interface{}, error) {
 	resp, err := c.Do("set", key, val)
 	if err != nil {
@@ -81,15 +224,67 @@ func (c *Client) Send(args ...interface{}) error {
 	return c.send(args)
 }
 
+// send encodes args before writing anything, so an encode error leaves
+// the connection usable. Only a failed write marks it broken.
 func (c *Client) send(args []interface{}) error {
 	var buf bytes.Buffer
+	if err := encode(&buf, args); err != nil {
+		return err
+	}
+	return c.write(buf.Bytes())
+}
+
+// write sends a complete request. A failed write may have sent part of
+// it, so the connection is marked broken.
+func (c *Client) write(b []byte) error {
+	if _, err := c.sock.Write(b); err != nil {
+		c.broken = true
+		return err
+	}
+	return nil
+}
+
+// encode appends one request packet for args to buf.
+func encode(buf *bytes.Buffer, args []interface{}) error {
+	if err := encodeArgs(buf, args); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	return nil
+}
+
+// CheckArgs reports whether every argument in args can be encoded. It
+// returns an error wrapping ErrBadArguments for the first one that can
+// not, and accepts exactly the types that encodeArgs does.
+func CheckArgs(args []interface{}) error {
+	for _, arg := range args {
+		switch arg := arg.(type) {
+		case string, []byte, []string, int, int64, float64, float32, bool, nil:
+		case []interface{}:
+			if err := CheckArgs(arg); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%w: unsupported type %T", ErrBadArguments, arg)
+		}
+	}
+	return nil
+}
+
+// encodeArgs appends the blocks for args, flattening nested slices.
+func encodeArgs(buf *bytes.Buffer, args []interface{}) error {
 	for _, arg := range args {
 		var s string
 		switch arg := arg.(type) {
 		case string:
 			s = arg
 		case []byte:
-			s = string(arg)
+			// written directly, large binary values are not copied into a string
+			buf.WriteString(strconv.Itoa(len(arg)))
+			buf.WriteByte('\n')
+			buf.Write(arg)
+			buf.WriteByte('\n')
+			continue
 		case []string:
 			for _, s := range arg {
 				buf.WriteString(fmt.Sprintf("%d", len(s)))
@@ -98,12 +293,20 @@ func (c *Client) send(args []interface{}) error {
 				buf.WriteByte('\n')
 			}
 			continue
+		case []interface{}:
+			if err := encodeArgs(buf, arg); err != nil {
+				return err
+			}
+			continue
 		case int:
 			s = fmt.Sprintf("%d", arg)
 		case int64:
 			s = fmt.Sprintf("%d", arg)
 		case float64:
-			s = fmt.Sprintf("%f", arg)
+			// 最短的精确表示, %f 只保留 6 位小数
+			s = strconv.FormatFloat(arg, 'f', -1, 64)
+		case float32:
+			s = strconv.FormatFloat(float64(arg), 'f', -1, 32)
 		case bool:
 			if arg {
 				s = "1"
@@ -113,22 +316,37 @@ func (c *Client) send(args []interface{}) error {
 		case nil:
 			s = ""
 		default:
-			return fmt.Errorf("bad arguments")
+			return fmt.Errorf("%w: unsupported type %T", ErrBadArguments, arg)
 		}
 		buf.WriteString(fmt.Sprintf("%d", len(s)))
 		buf.WriteByte('\n')
 		buf.WriteString(s)
 		buf.WriteByte('\n')
 	}
-	buf.WriteByte('\n')
-	_, err := c.sock.Write(buf.Bytes())
-	return err
+	return nil
 }
 
 func (c *Client) Recv() ([]string, error) {
 	return c.recv()
 }
 
+// RecvContext is like Recv, but gives up when ctx is cancelled or its
+// deadline passes, leaving the connection broken. It is meant for
+// commands such as sync140 that reply with a stream of packets.
+func (c *Client) RecvContext(ctx context.Context) ([]string, error) {
+	var resp []string
+	err := c.run(ctx, func() (err error) {
+		if c.broken {
+			return ErrBroken
+		}
+		if resp, err = c.recv(); err != nil {
+			c.broken = true
+		}
+		return err
+	})
+	return resp, err
+}
+
 func (c *Client) recv() ([]string, error) {
 	var tmp [8192]byte
 	for {
@@ -187,7 +405,88 @@ func (c *Client) parse() []string {
 	return []string{}
 }
 
-// Close The Client Connection
+// DoBytes is like Do, but returns the reply fields as byte slices that
+// point into the client's receive buffer instead of copying each one
+// into a string. The buffer is reused, so the slices are only valid
+// until the next call on c; copy them to keep them longer.
+func (c *Client) DoBytes(args ...interface{}) ([][]byte, error) {
+	return c.DoBytesContext(context.Background(), args...)
+}
+
+// DoBytesContext is DoBytes with the cancellation rules of DoContext.
+func (c *Client) DoBytesContext(ctx context.Context, args ...interface{}) ([][]byte, error) {
+	var resp [][]byte
+	err := c.run(ctx, func() error {
+		if c.broken {
+			return ErrBroken
+		}
+		if err := c.send(args); err != nil {
+			return err
+		}
+		var err error
+		resp, err = c.recvBytes()
+		if err != nil {
+			c.broken = true
+		}
+		return err
+	})
+	return resp, err
+}
+
+func (c *Client) recvBytes() ([][]byte, error) {
+	var tmp [8192]byte
+	for {
+		resp, err := c.parseBytes()
+		if err != nil || resp != nil {
+			return resp, err
+		}
+		n, err := c.sock.Read(tmp[0:])
+		if err != nil {
+			return nil, err
+		}
+		c.recv_buf.Write(tmp[0:n])
+	}
+}
+
+// parseBytes is parse without the string copies. It returns nil, nil
+// while the packet is incomplete. A complete packet is consumed from
+// recv_buf, but its memory is left in place until the next write into
+// the buffer, which keeps the returned slices valid until then.
+func (c *Client) parseBytes() ([][]byte, error) {
+	var resp [][]byte
+	buf := c.recv_buf.Bytes()
+	offset := 0
+
+	for {
+		idx := bytes.IndexByte(buf[offset:], '\n')
+		if idx == -1 {
+			return nil, nil
+		}
+		p := buf[offset : offset+idx]
+		offset += idx + 1
+		if len(p) == 0 || (len(p) == 1 && p[0] == '\r') {
+			if len(resp) == 0 {
+				continue
+			}
+			c.recv_buf.Next(offset)
+			return resp, nil
+		}
+
+		size, err := strconv.Atoi(string(p))
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("bad response")
+		}
+		if offset+size >= len(buf) {
+			return nil, nil
+		}
+		resp = append(resp, buf[offset:offset+size:offset+size])
+		offset += size + 1
+	}
+}
+
+// Close The Client Connection. The client is broken afterwards, so
+// IsBroken reports true until Reconnect succeeds.
 func (c *Client) Close() error {
+	c.broken = true
 	return c.sock.Close()
 }
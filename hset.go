@@ -1,7 +1,6 @@
 package gossdb_client
 
 import (
-	"fmt"
 	"github.com/houbin910902/to"
 )
 
@@ -12,9 +11,9 @@ import (
 //  value key 的值
 //  返回 err，执行的错误
 func (c *DbClient) HSet(setName, key string, value interface{}) (err error) {
-	resp, err := c.Client.Do("hset", setName, key, value)
+	resp, err := c.do("hset", setName, key, value)
 	if err != nil {
-		return fmt.Errorf("HSet %s/%s error", setName, key)
+		return err
 	}
 
 	if len(resp) > 0 && resp[0] == "ok" {
@@ -30,9 +29,9 @@ func (c *DbClient) HSet(setName, key string, value interface{}) (err error) {
 //  返回 value key 的值
 //  返回 err，执行的错误
 func (c *DbClient) HGet(setName, key string) (value string, err error) {
-	resp, err := c.Client.Do("hget", setName, key)
+	resp, err := c.do("hget", setName, key)
 	if err != nil {
-		return "", fmt.Errorf("HGet %s/%s error", setName, key)
+		return "", err
 	}
 	if len(resp) == 2 && resp[0] == "ok" {
 		return to.String(resp[1]), nil
@@ -40,15 +39,33 @@ func (c *DbClient) HGet(setName, key string) (value string, err error) {
 	return "", handError(resp, setName, key)
 }
 
+//获取 hashmap 中指定 key 的值内容, 以 []byte 返回, 不复制接收缓冲区.
+//
+//  返回的切片只在同一个 client 执行下一条命令之前有效, 需要保留时请自行复制
+//  setName hashmap 的名字
+//  key hashmap 的 key
+//  返回 value key 的值, key 不存在时返回 ErrNotFound
+//  返回 err，执行的错误
+func (c *DbClient) HGetBytes(setName, key string) (value []byte, err error) {
+	resp, err := c.doBytes("hget", setName, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) == 2 {
+		return resp[1], nil
+	}
+	return nil, handErrorBytes(resp, setName, key)
+}
+
 //删除 hashmap 中的指定 key，不能通过返回值来判断被删除的 key 是否存在.
 //
 //  setName hashmap 的名字
 //  key hashmap 的 key
 //  返回 err，执行的错误
 func (c *DbClient) HDel(setName, key string) (err error) {
-	resp, err := c.Client.Do("hdel", setName, key)
+	resp, err := c.do("hdel", setName, key)
 	if err != nil {
-		return fmt.Errorf("HDel %s/%s error", setName, key)
+		return err
 	}
 	if len(resp) > 0 && resp[0] == "ok" {
 		return nil
@@ -63,9 +80,9 @@ func (c *DbClient) HDel(setName, key string) (err error) {
 //  返回 re，如果当前 key 不存在返回 false
 //  返回 err，执行的错误，操作成功返回 nil
 func (c *DbClient) HExists(setName, key string) (re bool, err error) {
-	resp, err := c.Client.Do("hexists", setName, key)
+	resp, err := c.do("hexists", setName, key)
 	if err != nil {
-		return false, fmt.Errorf("HExists %s/%s error: %s", setName, key, err.Error())
+		return false, err
 	}
 
 	if len(resp) == 2 && resp[0] == "ok" {
@@ -79,9 +96,9 @@ func (c *DbClient) HExists(setName, key string) (re bool, err error) {
 //  setName hashmap 的名字
 //  返回 err，执行的错误，操作成功返回 nil
 func (c *DbClient) HClear(setName string) (err error) {
-	resp, err := c.Client.Do("hclear", setName)
+	resp, err := c.do("hclear", setName)
 	if err != nil {
-		return fmt.Errorf("HClear %s error", setName)
+		return err
 	}
 
 	if len(resp) > 0 && resp[0] == "ok" {
@@ -104,10 +121,10 @@ func (c *DbClient) HScan(setName string, keyStart, keyEnd string, limit int64, r
 		cmd = "hrscan"
 	}
 
-	resp, err := c.Client.Do(cmd, setName, keyStart, keyEnd, limit)
+	resp, err := c.do(cmd, setName, keyStart, keyEnd, limit)
 
 	if err != nil {
-		return nil, fmt.Errorf("%s %s %s %s %v error", cmd, setName, keyStart, keyEnd, limit)
+		return nil, err
 	}
 
 	if len(resp) > 0 && resp[0] == "ok" {
@@ -134,10 +151,10 @@ func (c *DbClient) HScanArray(setName string, keyStart, keyEnd string, limit int
 	if len(reverse) > 0 && reverse[0] == true {
 		cmd = "hrscan"
 	}
-	resp, err := c.Client.Do(cmd, setName, keyStart, keyEnd, limit)
+	resp, err := c.do(cmd, setName, keyStart, keyEnd, limit)
 
 	if err != nil {
-		return nil, nil, fmt.Errorf("%s %s %s %s %v error", cmd, setName, keyStart, keyEnd, limit)
+		return nil, nil, err
 	}
 
 	if len(resp) > 0 && resp[0] == "ok" {
@@ -189,10 +206,10 @@ func (c *DbClient) MultiHSet(setName string, kvs map[string]interface{}) (err er
 		args = append(args, k)
 		args = append(args, v)
 	}
-	resp, err := c.Client.Do(args...)
+	resp, err := c.do(args...)
 
 	if err != nil {
-		return fmt.Errorf("MultiHset %s %s error", setName, kvs)
+		return err
 	}
 
 	if len(resp) > 0 && resp[0] == "ok" {
@@ -218,9 +235,9 @@ func (c *DbClient) MultiHGet(setName string, key ...string) (val map[string]stri
 		args = append(args, v)
 	}
 
-	resp, err := c.Client.Do(args...)
+	resp, err := c.do(args...)
 	if err != nil {
-		return nil, fmt.Errorf("MultiHget %s %s error", setName, key)
+		return nil, err
 	}
 	size := len(resp)
 	if size > 0 && resp[0] == "ok" {
@@ -247,10 +264,10 @@ func (c *DbClient) MultiHGetSlice(setName string, key ...string) (keys []string,
 	for _, v := range key {
 		args = append(args, v)
 	}
-	resp, err := c.Client.Do(args...)
+	resp, err := c.do(args...)
 
 	if err != nil {
-		return nil, nil, fmt.Errorf("MultiHgetSlice %s %s error", setName, key)
+		return nil, nil, err
 	}
 	if len(resp) > 0 && resp[0] == "ok" {
 		size := len(resp)
@@ -266,6 +283,31 @@ func (c *DbClient) MultiHGetSlice(setName string, key ...string) (keys []string,
 	return nil, nil, handError(resp, key)
 }
 
+//批量获取 hashmap 中多个 key 对应的值, 以 []byte 返回, 不复制接收缓冲区.
+//
+//  返回的切片只在同一个 client 执行下一条命令之前有效, 需要保留时请自行复制
+//  setName - hashmap 的名字.
+//  key - 要获取的 key, 可以为多个
+//  返回 keys和values分片, 一一对应, 不存在的 key 不会出现在结果中
+//  返回 err，执行的错误，操作成功返回 nil
+func (c *DbClient) MultiHGetBytes(setName string, key ...string) (keys [][]byte, values [][]byte, err error) {
+	if len(key) == 0 {
+		return [][]byte{}, [][]byte{}, nil
+	}
+	resp, err := c.doBytes("multi_hget", setName, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	size := len(resp)
+	keys = make([][]byte, 0, (size-1)/2)
+	values = make([][]byte, 0, (size-1)/2)
+	for i := 1; i+1 < size; i += 2 {
+		keys = append(keys, resp[i])
+		values = append(values, resp[i+1])
+	}
+	return keys, values, nil
+}
+
 //批量获取 hashmap 中多个 key 对应的权重值.（输入分片）
 //
 //  setName - hashmap 的名字.
@@ -295,10 +337,10 @@ func (c *DbClient) MultiHGetSliceArray(setName string, key []string) (keys []str
 //  返回 err，执行的错误，操作成功返回 nil
 func (c *DbClient) MultiHGetAll(setName string) (val map[string]string, err error) {
 
-	resp, err := c.Client.Do("hgetall", setName)
+	resp, err := c.do("hgetall", setName)
 
 	if err != nil {
-		return nil, fmt.Errorf("MultiHgetAll %s error", setName)
+		return nil, err
 	}
 	size := len(resp)
 	if size > 0 && resp[0] == "ok" {
@@ -318,10 +360,10 @@ func (c *DbClient) MultiHGetAll(setName string) (val map[string]string, err erro
 //  返回 err，执行的错误，操作成功返回 nil
 func (c *DbClient) MultiHgetAllSlice(setName string) (keys []string, values []string, err error) {
 
-	resp, err := c.Client.Do("hgetall", setName)
+	resp, err := c.do("hgetall", setName)
 
 	if err != nil {
-		return nil, nil, fmt.Errorf("MultiHgetAllSlice %s error", setName)
+		return nil, nil, err
 	}
 	if len(resp) > 0 && resp[0] == "ok" {
 		size := len(resp)
@@ -350,9 +392,9 @@ func (c *DbClient) MultiHDel(setName string, key ...string) (err error) {
 	for _, v := range key {
 		args = append(args, v)
 	}
-	resp, err := c.Client.Do(args...)
+	resp, err := c.do(args...)
 	if err != nil {
-		return fmt.Errorf("MultiHDel %s %s error", setName, key)
+		return err
 	}
 
 	if len(resp) > 0 && resp[0] == "ok" {
@@ -378,9 +420,9 @@ func (c *DbClient) MultiHDelArray(setName string, key []string) (err error) {
 //  返回 包含名字的数组
 //  返回 err，执行的错误，操作成功返回 nil
 func (c *DbClient) HList(nameStart, nameEnd string, limit int64) ([]string, error) {
-	resp, err := c.Client.Do("hlist", nameStart, nameEnd, limit)
+	resp, err := c.do("hlist", nameStart, nameEnd, limit)
 	if err != nil {
-		return nil, fmt.Errorf("HList %s %s %v error", nameStart, nameEnd, limit)
+		return nil, err
 	}
 
 	if len(resp) > 0 && resp[0] == "ok" {
@@ -404,10 +446,10 @@ func (c *DbClient) HList(nameStart, nameEnd string, limit int64) ([]string, erro
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) HIncR(setName, key string, num int64) (val int64, err error) {
 
-	resp, err := c.Client.Do("hincr", setName, key, num)
+	resp, err := c.do("hincr", setName, key, num)
 
 	if err != nil {
-		return -1, fmt.Errorf("HIncR %s error", key)
+		return -1, err
 	}
 	if len(resp) == 2 && resp[0] == "ok" {
 		return to.Int64(resp[1]), nil
@@ -422,10 +464,10 @@ func (c *DbClient) HIncR(setName, key string, num int64) (val int64, err error)
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) HSize(setName string) (val int64, err error) {
 
-	resp, err := c.Client.Do("hsize", setName)
+	resp, err := c.do("hsize", setName)
 
 	if err != nil {
-		return -1, fmt.Errorf("HSize %s error", setName)
+		return -1, err
 	}
 	if len(resp) == 2 && resp[0] == "ok" {
 		return to.Int64(resp[1]), nil
@@ -442,9 +484,9 @@ func (c *DbClient) HSize(setName string) (val int64, err error) {
 //  返回 包含名字的数组
 //  返回 err，执行的错误，操作成功返回 nil
 func (c *DbClient) HKeys(setName, keyStart, keyEnd string, limit int64) ([]string, error) {
-	resp, err := c.Client.Do("hkeys", setName, keyStart, keyEnd, limit)
+	resp, err := c.do("hkeys", setName, keyStart, keyEnd, limit)
 	if err != nil {
-		return nil, fmt.Errorf("HKeys %s %s %s %v error", setName, keyStart, keyEnd, limit)
+		return nil, err
 	}
 
 	if len(resp) > 0 && resp[0] == "ok" {
@@ -0,0 +1,420 @@
+package gossdb_client
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/houbin910902/to"
+)
+
+//  HSetStruct、HGetStruct 中一个字段的转换错误
+type FieldError struct {
+	Field string // hashmap 中的 key
+	Value string // 读取时服务端返回的值, 写入时为空
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	if e.Value != "" {
+		return fmt.Sprintf("ssdb field %s = %q: %v", e.Field, e.Value, e.Err)
+	}
+	return fmt.Sprintf("ssdb field %s: %v", e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+//  多个字段的转换错误, 每个字段一个 *FieldError
+type FieldErrors []*FieldError
+
+func (e FieldErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+//  返回每个字段的错误, 用于 errors.Is 和 errors.As
+func (e FieldErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, fe := range e {
+		errs[i] = fe
+	}
+	return errs
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	bytesType    = reflect.TypeOf([]byte(nil))
+)
+
+//  结构体中映射到 hashmap key 的一个字段
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+var structFieldsCache sync.Map // reflect.Type => []structField
+
+//  返回结构体类型的字段映射, 规则与 encoding/json 相同:
+//  tag 为 ssdb:"name,omitempty", ssdb:"-" 表示忽略, 没有 tag 时使用字段名,
+//  匿名的结构体字段(包括指针)展开到外层, 重名时层级较浅的字段优先
+func cachedStructFields(t reflect.Type) []structField {
+	if f, ok := structFieldsCache.Load(t); ok {
+		return f.([]structField)
+	}
+	var all []structField
+	collectStructFields(t, nil, &all)
+
+	seen := make(map[string]int)
+	fields := make([]structField, 0, len(all))
+	for _, f := range all {
+		if i, ok := seen[f.name]; ok {
+			if len(f.index) < len(fields[i].index) {
+				fields[i] = f
+			}
+			continue
+		}
+		seen[f.name] = len(fields)
+		fields = append(fields, f)
+	}
+	structFieldsCache.Store(t, fields)
+	return fields
+}
+
+func collectStructFields(t reflect.Type, index []int, out *[]structField) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("ssdb")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.IndexByte(tag, ','); i >= 0 {
+			name, opts = tag[:i], tag[i+1:]
+		}
+		idx := append(append([]int(nil), index...), i)
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct && ft != timeType {
+			collectStructFields(ft, idx, out)
+			continue
+		}
+		if sf.PkgPath != "" {
+			// 未导出的字段
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		*out = append(*out, structField{name: name, index: idx, omitEmpty: opts == "omitempty"})
+	}
+}
+
+//  返回结构体的反射值, v 必须是结构体或者结构体指针
+func structValue(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("gossdb_client: need a struct or struct pointer, got %T", v)
+	}
+	return rv, nil
+}
+
+//  按 index 取字段. alloc 为 true 时为 nil 的匿名结构体指针分配内存, 否则遇到 nil 时返回 false
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc || !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+//  把结构体转换为 hashmap 的 key-value. names 不为空时只转换这些字段.
+//  empty 中是没有值的字段: 设置了 omitempty 且值为空的字段, 以及值为 nil 指针或位于 nil 匿名结构体指针中的字段
+func (c *DbClient) structToHash(v interface{}, names []string) (kvs map[string]interface{}, empty []string, err error) {
+	rv, err := structValue(v)
+	if err != nil {
+		return nil, nil, err
+	}
+	var only map[string]bool
+	if len(names) > 0 {
+		only = make(map[string]bool, len(names))
+		for _, n := range names {
+			only[n] = true
+		}
+	}
+
+	var errs FieldErrors
+	kvs = make(map[string]interface{})
+	for _, f := range cachedStructFields(rv.Type()) {
+		if only != nil && !only[f.name] {
+			continue
+		}
+		fv, ok := fieldByIndex(rv, f.index, false)
+		if !ok || (fv.Kind() == reflect.Ptr && fv.IsNil()) || (f.omitEmpty && isEmptyValue(fv)) {
+			empty = append(empty, f.name)
+			continue
+		}
+		if fv.Kind() == reflect.Ptr {
+			fv = fv.Elem()
+		}
+		val, err := c.encodeField(fv)
+		if err != nil {
+			errs = append(errs, &FieldError{Field: f.name, Err: err})
+			continue
+		}
+		kvs[f.name] = val
+	}
+	if len(errs) > 0 {
+		return nil, nil, errs
+	}
+	return kvs, empty, nil
+}
+
+func (c *DbClient) encodeField(v reflect.Value) (interface{}, error) {
+	switch v.Type() {
+	case timeType:
+		return v.Interface().(time.Time).Format(time.RFC3339Nano), nil
+	case durationType:
+		return time.Duration(v.Int()).String(), nil
+	case bytesType:
+		return v.Bytes(), nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		if v.Bool() {
+			return "1", nil
+		}
+		return "0", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), nil
+	}
+	// 其余的类型(结构体、map、slice 等)使用 client 的 Codec
+	return c.marshal(v.Interface())
+}
+
+func (c *DbClient) decodeField(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	switch v.Type() {
+	case timeType:
+		t := to.Time(s)
+		if t.IsZero() && s != "" && s != (time.Time{}).Format(time.RFC3339Nano) {
+			return fmt.Errorf("invalid time")
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case durationType:
+		d := to.Duration(s)
+		if d == 0 && s != "" && strings.Trim(s, "0s.") != "" {
+			return fmt.Errorf("invalid duration")
+		}
+		v.SetInt(int64(d))
+		return nil
+	case bytesType:
+		v.SetBytes([]byte(s))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return c.unmarshal([]byte(s), v.Addr().Interface())
+	}
+	return nil
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).IsZero()
+	}
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+//  把结构体的字段写入 hashmap, 字段与 key 的对应关系由 ssdb tag 指定.
+//
+//  tag 的格式为 `ssdb:"name,omitempty"`, `ssdb:"-"` 表示忽略该字段, 没有 tag 时使用字段名.
+//  匿名的结构体字段展开到外层. time.Time 以 RFC3339 格式保存, time.Duration 以 "1h2m3s" 格式保存,
+//  数值、bool、string、[]byte 直接保存, 其余的类型使用 client 的 Codec 编码.
+//  设置了 omitempty 且值为空的字段和值为 nil 指针的字段不会写入, 也不会删除 hashmap 中已有的值.
+//  setName hashmap 的名字
+//  v 结构体或者结构体指针
+//  fields 可选, 只写入这些 key, 用于部分更新
+//  返回 err，执行的错误，字段转换失败时返回 FieldErrors 并且不写入任何字段
+func (c *DbClient) HSetStruct(setName string, v interface{}, fields ...string) (err error) {
+	kvs, _, err := c.structToHash(v, fields)
+	if err != nil {
+		return err
+	}
+	if len(kvs) == 0 {
+		return nil
+	}
+	return c.MultiHSet(setName, kvs)
+}
+
+//  只把 old 和 new 之间发生变化的字段写入 hashmap, 参见 HSetStruct.
+//
+//  设置了 omitempty 的字段变为空值, 或者指针字段变为 nil 时, 从 hashmap 中删除该字段.
+//  setName hashmap 的名字
+//  old 修改前的结构体
+//  new 修改后的结构体, 类型必须与 old 相同
+//  返回 changed, 写入或删除的 key
+//  返回 err，执行的错误，操作成功返回 nil
+func (c *DbClient) HUpdateStruct(setName string, old, new interface{}) (changed []string, err error) {
+	ov, err := structValue(old)
+	if err != nil {
+		return nil, err
+	}
+	nv, err := structValue(new)
+	if err != nil {
+		return nil, err
+	}
+	if ov.Type() != nv.Type() {
+		return nil, fmt.Errorf("gossdb_client: HUpdateStruct type mismatch %s and %s", ov.Type(), nv.Type())
+	}
+	before, _, err := c.structToHash(old, nil)
+	if err != nil {
+		return nil, err
+	}
+	after, empty, err := c.structToHash(new, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range after {
+		if b, ok := before[k]; ok && to.String(b) == to.String(v) {
+			delete(after, k)
+			continue
+		}
+		changed = append(changed, k)
+	}
+	var del []string
+	for _, k := range empty {
+		if _, ok := before[k]; ok {
+			del = append(del, k)
+		}
+	}
+	if len(after) > 0 {
+		if err = c.MultiHSet(setName, after); err != nil {
+			return nil, err
+		}
+	}
+	if len(del) > 0 {
+		if err = c.MultiHDel(setName, del...); err != nil {
+			return nil, err
+		}
+	}
+	return append(changed, del...), nil
+}
+
+//  读取整个 hashmap 并按 ssdb tag 填充到结构体中, 参见 HSetStruct.
+//
+//  hashmap 中不存在的字段保持原值, 没有对应字段的 key 被忽略.
+//  setName hashmap 的名字
+//  dst 结构体指针
+//  返回 err，执行的错误，hashmap 为空时返回 ErrNotFound.
+//  部分字段转换失败时其它字段仍会被填充, 并返回包含所有失败字段的 FieldErrors
+func (c *DbClient) HGetStruct(setName string, dst interface{}) (err error) {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("gossdb_client: HGetStruct needs a non-nil struct pointer, got %T", dst)
+	}
+	val, err := c.MultiHGetAll(setName)
+	if err != nil {
+		return err
+	}
+	if len(val) == 0 {
+		return &CommandError{Cmd: "hgetall", Args: []interface{}{setName}, Err: ErrNotFound}
+	}
+
+	rv = rv.Elem()
+	var errs FieldErrors
+	for _, f := range cachedStructFields(rv.Type()) {
+		s, ok := val[f.name]
+		if !ok {
+			continue
+		}
+		fv, ok := fieldByIndex(rv, f.index, true)
+		if !ok {
+			errs = append(errs, &FieldError{Field: f.name, Value: s, Err: fmt.Errorf("cannot set field of nil embedded pointer")})
+			continue
+		}
+		if err := c.decodeField(fv, s); err != nil {
+			errs = append(errs, &FieldError{Field: f.name, Value: s, Err: err})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package gossdb_client
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+type testBase struct {
+	ID      int64     `ssdb:"id"`
+	Created time.Time `ssdb:"created"`
+}
+
+type testProfile struct {
+	testBase
+	Name    string            `ssdb:"name"`
+	Nick    string            `ssdb:"nick,omitempty"`
+	Age     uint8             `ssdb:"age"`
+	Score   float64           `ssdb:"score"`
+	VIP     bool              `ssdb:"vip"`
+	TTL     time.Duration     `ssdb:"ttl"`
+	Tags    map[string]string `ssdb:"tags,omitempty"`
+	Ignored string            `ssdb:"-"`
+	Plain   *int
+}
+
+func TestHStruct(t *testing.T) {
+	db, _ := newTestClient(t)
+
+	n := 7
+	in := testProfile{
+		testBase: testBase{ID: 1, Created: time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)},
+		Name:     "bin", Nick: "b", Age: 30, Score: 1.5, VIP: true, TTL: 90 * time.Second,
+		Tags: map[string]string{"a": "1"}, Ignored: "x", Plain: &n,
+	}
+	if err := db.HSetStruct("p", &in); err != nil {
+		t.Fatal(err)
+	}
+	var out testProfile
+	if err := db.HGetStruct("p", &out); err != nil {
+		t.Fatal(err)
+	}
+	if !out.Created.Equal(in.Created) {
+		t.Fatalf("Created = %v, want %v", out.Created, in.Created)
+	}
+	out.Created, in.Created = time.Time{}, time.Time{}
+	in.Ignored = ""
+	if !reflect.DeepEqual(out, in) {
+		t.Fatalf("HGetStruct = %+v, want %+v", out, in)
+	}
+
+	// 只更新变化的字段, 变为空的 omitempty 字段和变为 nil 的指针字段被删除
+	in2 := out
+	in2.Score = 2
+	in2.Nick = ""
+	in2.Plain = nil
+	changed, err := db.HUpdateStruct("p", out, in2)
+	sort.Strings(changed)
+	if err != nil || !reflect.DeepEqual(changed, []string{"Plain", "nick", "score"}) {
+		t.Fatalf("HUpdateStruct = %v, %v", changed, err)
+	}
+	for _, k := range []string{"nick", "Plain"} {
+		if ok, _ := db.HExists("p", k); ok {
+			t.Fatalf("%s should be deleted", k)
+		}
+	}
+	if err = db.HSetStruct("p", testProfile{Name: "new", Age: 99}, "name"); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := db.HGet("p", "age"); v != "30" {
+		t.Fatalf("age = %q, only name should be written", v)
+	}
+
+	// 转换错误按字段返回, 其它字段仍然填充
+	if err = db.MultiHSet("bad", map[string]interface{}{"age": "300", "score": "x", "name": "ok"}); err != nil {
+		t.Fatal(err)
+	}
+	var bad testProfile
+	err = db.HGetStruct("bad", &bad)
+	var fe FieldErrors
+	if !errors.As(err, &fe) || len(fe) != 2 || bad.Name != "ok" {
+		t.Fatalf("HGetStruct err = %v, name = %q", err, bad.Name)
+	}
+	var one *FieldError
+	if !errors.As(err, &one) || (one.Field != "age" && one.Field != "score") {
+		t.Fatalf("errors.As FieldError = %v", one)
+	}
+	if err = db.HGetStruct("missing", &bad); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("HGetStruct err = %v, want ErrNotFound", err)
+	}
+}
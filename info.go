@@ -0,0 +1,301 @@
+package gossdb_client
+
+import (
+	"strconv"
+	"strings"
+)
+
+//  检查连接是否可用
+//  返回 err，可能的错误，操作成功返回 nil
+func (c *DbClient) Ping() error {
+	resp, err := c.do("ping")
+	if err != nil {
+		return err
+	}
+	if len(resp) > 0 && resp[0] == "ok" {
+		return nil
+	}
+	return handError(resp)
+}
+
+//  返回服务端的版本号, 如 1.9.7
+//  返回 err，可能的错误，操作成功返回 nil
+func (c *DbClient) Version() (string, error) {
+	resp, err := c.do("version")
+	if err != nil {
+		return "", err
+	}
+	if len(resp) == 2 && resp[0] == "ok" {
+		return resp[1], nil
+	}
+	return "", handError(resp)
+}
+
+//  返回数据库的估算大小, 单位为字节, 是 leveldb 文件占用的空间而不是 key 的个数. 压缩过的数据库可能比实际的数据小
+//  返回 err，可能的错误，操作成功返回 nil
+func (c *DbClient) DBSize() (int64, error) {
+	resp, err := c.do("dbsize")
+	if err != nil {
+		return -1, err
+	}
+	if len(resp) == 2 && resp[0] == "ok" {
+		return strconv.ParseInt(resp[1], 10, 64)
+	}
+	return -1, handError(resp)
+}
+
+//  服务端的状态, 由 info 命令的响应解析而来
+type ServerInfo struct {
+	Version    string
+	Links      int64 // 当前的连接数
+	TotalCalls int64 // 启动以来执行的命令数
+	DBSize     int64 // 同 DBSize
+
+	Binlog      BinlogInfo
+	Replication []ReplicationInfo // 每个同步连接一项, 主库上是从库的连接, 从库上是到主库的连接
+
+	ServKeyRange KeyRanges // 本实例负责的 key 区间, 用于集群
+	DataKeyRange KeyRanges // 实际存储的 key 区间
+
+	LevelDB      []LevelDBLevel // leveldb 各层的统计
+	LevelDBStats string         // leveldb.stats 的原始文本
+
+	Commands map[string]CommandStats // 每个命令的统计, 只在 Info("cmd") 时返回
+
+	Extra map[string]string // 无法识别的字段
+}
+
+//  binlog 的状态
+type BinlogInfo struct {
+	Capacity int64
+	MinSeq   int64
+	MaxSeq   int64
+}
+
+//  一个同步连接的状态
+type ReplicationInfo struct {
+	// 主库上为 client(连接过来的从库), 从库上为 slaveof(连接到的主库)
+	Role   string
+	Addr   string
+	Id     string // 从库配置的 id, 只在从库上返回
+	Type   string // sync 或 mirror
+	Status string // DISCONNECTED、INIT、OUT_OF_SYNC、COPY、SYNC
+	// 已同步的 binlog 序号
+	LastSeq   int64
+	CopyCount int64
+	SyncCount int64
+	// 无法识别的字段
+	Extra map[string]string
+}
+
+//  各种数据类型的 key 区间
+type KeyRanges struct {
+	KV, Hash, ZSet, List KeyRange
+}
+
+//  key 区间, 空字符串表示不限制
+type KeyRange struct {
+	Start, End string
+}
+
+//  leveldb 一层的统计
+type LevelDBLevel struct {
+	Level   int
+	Files   int64
+	SizeMB  float64
+	TimeSec float64
+	ReadMB  float64
+	WriteMB float64
+}
+
+//  一个命令的统计
+type CommandStats struct {
+	Calls    int64
+	TimeWait float64 // 在队列中等待的总时间, 毫秒
+	TimeProc float64 // 执行的总时间, 毫秒
+}
+
+//  返回服务端的状态
+//  opt 可选, 为 cmd 时返回每个命令的统计(不再返回 leveldb 的统计), 为 leveldb 时只返回 leveldb 的统计
+//  返回 err，可能的错误，操作成功返回 nil
+func (c *DbClient) Info(opt ...string) (*ServerInfo, error) {
+	args := []interface{}{"info"}
+	if len(opt) > 0 && opt[0] != "" {
+		args = append(args, opt[0])
+	}
+	resp, err := c.do(args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) == 0 || resp[0] != "ok" {
+		return nil, handError(resp)
+	}
+	return parseInfo(resp[1:]), nil
+}
+
+//  响应为 ssdb-server 之后的 key-value 对, value 可能是多行缩进的文本
+func parseInfo(resp []string) *ServerInfo {
+	info := &ServerInfo{}
+	if len(resp) > 0 && resp[0] == "ssdb-server" {
+		resp = resp[1:]
+	}
+	for i := 0; i+1 < len(resp); i += 2 {
+		key, val := resp[i], resp[i+1]
+		switch {
+		case key == "version":
+			info.Version = val
+		case key == "links":
+			info.Links, _ = strconv.ParseInt(val, 10, 64)
+		case key == "total_calls":
+			info.TotalCalls, _ = strconv.ParseInt(val, 10, 64)
+		case key == "dbsize":
+			info.DBSize, _ = strconv.ParseInt(val, 10, 64)
+		case key == "binlogs":
+			fields := parseInfoBlock(val)
+			info.Binlog.Capacity, _ = strconv.ParseInt(fields["capacity"], 10, 64)
+			info.Binlog.MinSeq, _ = strconv.ParseInt(fields["min_seq"], 10, 64)
+			info.Binlog.MaxSeq, _ = strconv.ParseInt(fields["max_seq"], 10, 64)
+		case key == "replication":
+			info.Replication = append(info.Replication, parseReplication(val))
+		case key == "serv_key_range":
+			info.ServKeyRange = parseKeyRanges(val)
+		case key == "data_key_range":
+			info.DataKeyRange = parseKeyRanges(val)
+		case key == "leveldb.stats":
+			info.LevelDBStats = val
+			info.LevelDB = parseLevelDBStats(val)
+		case strings.HasPrefix(key, "cmd."):
+			if info.Commands == nil {
+				info.Commands = make(map[string]CommandStats)
+			}
+			info.Commands[key[len("cmd."):]] = parseCommandStats(val)
+		default:
+			if info.Extra == nil {
+				info.Extra = make(map[string]string)
+			}
+			info.Extra[key] = val
+		}
+	}
+	return info
+}
+
+//  解析形如 "    name : value" 的多行文本
+func parseInfoBlock(s string) map[string]string {
+	fields := make(map[string]string)
+	for _, line := range strings.Split(s, "\n") {
+		if i := strings.IndexByte(line, ':'); i > 0 {
+			fields[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+1:])
+		}
+	}
+	return fields
+}
+
+//  第一行为 "client ip:port" 或 "slaveof ip:port", 之后为缩进的字段
+func parseReplication(s string) ReplicationInfo {
+	var r ReplicationInfo
+	first := s
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		first, s = s[:i], s[i+1:]
+	} else {
+		s = ""
+	}
+	if parts := strings.Fields(first); len(parts) == 2 {
+		r.Role, r.Addr = parts[0], parts[1]
+	}
+	for k, v := range parseInfoBlock(s) {
+		switch k {
+		case "id":
+			r.Id = v
+		case "type":
+			r.Type = v
+		case "status":
+			r.Status = v
+		case "last_seq":
+			r.LastSeq, _ = strconv.ParseInt(v, 10, 64)
+		case "copy_count":
+			r.CopyCount, _ = strconv.ParseInt(v, 10, 64)
+		case "sync_count":
+			r.SyncCount, _ = strconv.ParseInt(v, 10, 64)
+		default:
+			if r.Extra == nil {
+				r.Extra = make(map[string]string)
+			}
+			r.Extra[k] = v
+		}
+	}
+	return r
+}
+
+//  每行形如 `kv  : "start" - "end"`, 不可打印的字符被转义为 \xNN
+func parseKeyRanges(s string) KeyRanges {
+	var kr KeyRanges
+	for k, v := range parseInfoBlock(s) {
+		var r KeyRange
+		if i := strings.Index(v, `" - "`); i >= 0 {
+			r.Start = unquoteInfo(v[:i+1])
+			r.End = unquoteInfo(v[i+4:])
+		}
+		switch k {
+		case "kv":
+			kr.KV = r
+		case "hash":
+			kr.Hash = r
+		case "zset":
+			kr.ZSet = r
+		case "list":
+			kr.List = r
+		}
+	}
+	return kr
+}
+
+func unquoteInfo(s string) string {
+	if u, err := strconv.Unquote(s); err == nil {
+		return u
+	}
+	return strings.Trim(s, `"`)
+}
+
+//  解析 leveldb 的统计表格, 只取以层号开头的行:
+//
+//	Level  Files Size(MB) Time(sec) Read(MB) Write(MB)
+//	--------------------------------------------------
+//	  0        1        0         0        0         0
+func parseLevelDBStats(s string) []LevelDBLevel {
+	var levels []LevelDBLevel
+	for _, line := range strings.Split(s, "\n") {
+		f := strings.Fields(line)
+		if len(f) != 6 {
+			continue
+		}
+		level, err := strconv.Atoi(f[0])
+		if err != nil {
+			continue
+		}
+		l := LevelDBLevel{Level: level}
+		l.Files, _ = strconv.ParseInt(f[1], 10, 64)
+		l.SizeMB, _ = strconv.ParseFloat(f[2], 64)
+		l.TimeSec, _ = strconv.ParseFloat(f[3], 64)
+		l.ReadMB, _ = strconv.ParseFloat(f[4], 64)
+		l.WriteMB, _ = strconv.ParseFloat(f[5], 64)
+		levels = append(levels, l)
+	}
+	return levels
+}
+
+//  形如 "calls: 1\ttime_wait: 0\ttime_proc: 0"
+func parseCommandStats(s string) CommandStats {
+	var cs CommandStats
+	f := strings.Fields(strings.Replace(s, ":", " ", -1))
+	for i := 0; i+1 < len(f); i += 2 {
+		switch f[i] {
+		case "calls":
+			cs.Calls, _ = strconv.ParseInt(f[i+1], 10, 64)
+		case "time_wait":
+			cs.TimeWait, _ = strconv.ParseFloat(f[i+1], 64)
+		case "time_proc":
+			cs.TimeProc, _ = strconv.ParseFloat(f[i+1], 64)
+		}
+	}
+	return cs
+}
@@ -0,0 +1,89 @@
+package gossdb_client
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/houbin910902/gossdb_client/ssdbtest"
+)
+
+func TestServerCommands(t *testing.T) {
+	db, _ := newTestClient(t)
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := db.Version(); err != nil || v != ssdbtest.Version {
+		t.Fatalf("Version = %q, %v", v, err)
+	}
+	db.Set("b", "1")
+	db.Set("y", "2")
+	db.HSet("h", "k", "v")
+	size, err := db.DBSize()
+	if err != nil || size <= 0 {
+		t.Fatalf("DBSize = %d, %v", size, err)
+	}
+
+	info, err := db.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != ssdbtest.Version || info.DBSize != size || info.Links != 1 || info.TotalCalls == 0 {
+		t.Fatalf("info = %+v", info)
+	}
+	if info.DataKeyRange.KV != (KeyRange{"b", "y"}) || info.DataKeyRange.Hash != (KeyRange{"h", "h"}) {
+		t.Fatalf("DataKeyRange = %+v", info.DataKeyRange)
+	}
+	if len(info.LevelDB) != 1 || info.Commands != nil {
+		t.Fatalf("LevelDB = %+v, Commands = %v", info.LevelDB, info.Commands)
+	}
+
+	info, err = db.Info("cmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Commands["set"].Calls != 2 || info.LevelDB != nil {
+		t.Fatalf("Commands = %+v", info.Commands)
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+	// ssdb-server 1.9 从库的 info 响应
+	resp := []string{
+		"ssdb-server",
+		"version", "1.9.4",
+		"links", "3",
+		"total_calls", "1024",
+		"dbsize", "4096",
+		"binlogs", "    capacity : 20000000\n    min_seq  : 7\n    max_seq  : 1030",
+		"replication", "client 127.0.0.1:55479\n    type     : sync\n    status   : SYNC\n    last_seq : 1030",
+		"replication", "slaveof 10.0.0.1:8888\n    id         : svc_2\n    type       : mirror\n    status     : COPY\n    last_seq   : 12\n    copy_count : 5\n    sync_count : 0",
+		"serv_key_range", "    kv  : \"\" - \"\"\n    hash: \"\" - \"\"\n    zset: \"\" - \"\"\n    list: \"\" - \"\"",
+		"data_key_range", "    kv  : \"a\" - \"z\\x00\"\n    hash: \"h1\" - \"h9\"\n    zset: \"\" - \"\"\n    list: \"q\" - \"q\"",
+		"leveldb.stats", "                               Compactions\nLevel  Files Size(MB) Time(sec) Read(MB) Write(MB)\n--------------------------------------------------\n  0        2        0         0        0         0\n  1        5       12         1       24        12\n",
+		"cmd.get", "calls: 42\ttime_wait: 3\ttime_proc: 7",
+		"unknown", "x",
+	}
+	info := parseInfo(resp)
+	want := &ServerInfo{
+		Version:    "1.9.4",
+		Links:      3,
+		TotalCalls: 1024,
+		DBSize:     4096,
+		Binlog:     BinlogInfo{Capacity: 20000000, MinSeq: 7, MaxSeq: 1030},
+		Replication: []ReplicationInfo{
+			{Role: "client", Addr: "127.0.0.1:55479", Type: "sync", Status: "SYNC", LastSeq: 1030},
+			{Role: "slaveof", Addr: "10.0.0.1:8888", Id: "svc_2", Type: "mirror", Status: "COPY", LastSeq: 12, CopyCount: 5},
+		},
+		DataKeyRange: KeyRanges{KV: KeyRange{"a", "z\x00"}, Hash: KeyRange{"h1", "h9"}, List: KeyRange{"q", "q"}},
+		LevelDB: []LevelDBLevel{
+			{Level: 0, Files: 2},
+			{Level: 1, Files: 5, SizeMB: 12, TimeSec: 1, ReadMB: 24, WriteMB: 12},
+		},
+		LevelDBStats: resp[20],
+		Commands:     map[string]CommandStats{"get": {Calls: 42, TimeWait: 3, TimeProc: 7}},
+		Extra:        map[string]string{"unknown": "x"},
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Fatalf("parseInfo =\n%+v\nwant\n%+v", info, want)
+	}
+}
@@ -0,0 +1,378 @@
+package gossdb_client
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+//  提交分数的方式
+type SubmitMode int
+
+const (
+	// 总是用新的分数覆盖
+	SubmitAlways SubmitMode = iota
+	// 只在新的分数更好时更新
+	SubmitBest
+	// 在原来的分数上增加
+	SubmitIncrement
+)
+
+//  开启并列排序时分数超出 int32 的范围
+var ErrScoreOutOfRange = errors.New("gossdb_client: leaderboard score out of range")
+
+//  并列排序时权重的低 32 位保存时间(从 leaderboardEpoch 开始的秒数), 高位保存分数
+const leaderboardTieBits = 32
+
+var leaderboardEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+
+//  排行榜的选项
+type LeaderboardOptions struct {
+	// 分数越小排名越靠前, 例如用时. 默认分数越大排名越靠前
+	LowerIsBetter bool
+	// 关闭并列排序. 默认分数相同时先达到这个分数的成员排名靠前(精确到秒), 此时分数必须在 int32 的范围内
+	DisableTieBreak bool
+	// BulkLoad 每条 multi_zset 命令的成员数, 默认 1000
+	BatchSize int
+}
+
+//  排行榜中的一个成员
+type LeaderboardEntry struct {
+	Member string
+	Score  int64
+	// 名次, 从 1 开始
+	Rank int64
+	// 达到这个分数的时间, 关闭并列排序时为零值
+	Time time.Time
+}
+
+//  基于 zset 的排行榜, 支持并列时按时间排序、分页、查询附近的名次和限制大小.
+//  开启并列排序(默认)时 zset 中的权重由分数和时间组合而成, 不能直接用 ZSet 等方法修改.
+//  此时还使用 hashmap name:seen 记录权重中已经带有时间的成员: SubmitIncrement 用 hincr 认领成员,
+//  只有返回 1 的一次提交在增量中加上时间, 并发的第一次提交也只会加一次.
+//  用法:
+//
+//	lb := db.NewLeaderboard("rank:weekly", nil)
+//	lb.Submit(ctx, "player:1", 1200, gossdb_client.SubmitBest)
+//	top, err := lb.Top(ctx, 0, 10)
+//	around, err := lb.Around(ctx, "player:1", 5)
+type Leaderboard struct {
+	c    *DbClient
+	name string
+	opts LeaderboardOptions
+}
+
+//  创建排行榜, 不会访问服务端
+//  name zset 的名字
+//  opts 选项, 可以为 nil
+func (c *DbClient) NewLeaderboard(name string, opts *LeaderboardOptions) *Leaderboard {
+	var o LeaderboardOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 1000
+	}
+	return &Leaderboard{c: c, name: name, opts: o}
+}
+
+//  把分数和时间组合为权重. 分数越大越好时, 越早的时间对应越大的低位, 分数越小越好时相反,
+//  这样按权重排序后分数相同的成员中先达到的排在前面
+func (lb *Leaderboard) encode(score int64, t time.Time) (int64, error) {
+	if lb.opts.DisableTieBreak {
+		return score, nil
+	}
+	if score < math.MinInt32 || score > math.MaxInt32 {
+		return 0, ErrScoreOutOfRange
+	}
+	return score<<leaderboardTieBits | lb.tie(t), nil
+}
+
+func (lb *Leaderboard) tie(t time.Time) int64 {
+	const max = 1<<leaderboardTieBits - 1
+	s := t.Unix() - leaderboardEpoch
+	if s < 0 {
+		s = 0
+	} else if s > max {
+		s = max
+	}
+	if lb.opts.LowerIsBetter {
+		return s
+	}
+	return max - s
+}
+
+func (lb *Leaderboard) decode(v int64) (score int64, t time.Time) {
+	if lb.opts.DisableTieBreak {
+		return v, time.Time{}
+	}
+	const max = 1<<leaderboardTieBits - 1
+	s := v & max
+	if !lb.opts.LowerIsBetter {
+		s = max - s
+	}
+	return v >> leaderboardTieBits, time.Unix(leaderboardEpoch+s, 0)
+}
+
+//  提交成员的分数
+//  mode 提交的方式, SubmitIncrement 时 score 为增量. 增量方式下并列时按第一次提交的时间排序
+//  返回 current, 提交后成员的分数
+//  返回 updated, 分数是否被修改, SubmitBest 时新的分数不比原来的好则为 false
+//  返回 err，可能的错误，操作成功返回 nil
+func (lb *Leaderboard) Submit(ctx context.Context, member string, score int64, mode SubmitMode) (current int64, updated bool, err error) {
+	c := lb.c.WithContext(ctx)
+	now := time.Now()
+	switch mode {
+	case SubmitIncrement:
+		if lb.opts.DisableTieBreak {
+			v, err := c.ZIncR(lb.name, member, score)
+			return v, err == nil, err
+		}
+		n, err := c.HIncR(lb.seen(), member, 1)
+		if err != nil {
+			return 0, false, err
+		}
+		delta := score << leaderboardTieBits
+		if n == 1 {
+			// 第一次提交, 时间与分数在同一条 zincr 中加上
+			delta += lb.tie(now)
+		}
+		v, err := c.ZIncR(lb.name, member, delta)
+		if err != nil {
+			var ce *CommandError
+			if n == 1 && errors.As(err, &ce) && ce.Code != "" {
+				// 服务端拒绝了 zincr, 时间没有加上, 撤销认领
+				c.HDel(lb.seen(), member)
+			}
+			return 0, false, err
+		}
+		current, _ = lb.decode(v)
+		if current < math.MinInt32 || current > math.MaxInt32 {
+			return current, true, ErrScoreOutOfRange
+		}
+		return current, true, nil
+	case SubmitBest:
+		// 读取和写入之间同一个成员的另一次提交可能被覆盖, 不同成员之间没有影响
+		old, err := c.ZGet(lb.name, member)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return 0, false, err
+		}
+		if err == nil {
+			oldScore, _ := lb.decode(old)
+			if !lb.better(score, oldScore) {
+				return oldScore, false, nil
+			}
+		}
+	}
+	v, err := lb.encode(score, now)
+	if err != nil {
+		return 0, false, err
+	}
+	if lb.opts.DisableTieBreak {
+		if err = c.ZSet(lb.name, member, v); err != nil {
+			return 0, false, err
+		}
+		return score, true, nil
+	}
+	// 先标记成员, 之后的 SubmitIncrement 不会再加上时间
+	p := c.Pipeline()
+	h := p.HSet(lb.seen(), member, 1)
+	z := p.ZSet(lb.name, member, v)
+	if _, err = p.Exec(); err != nil {
+		return 0, false, err
+	}
+	if err = h.Err(); err != nil {
+		return 0, false, err
+	}
+	if err = z.Err(); err != nil {
+		return 0, false, err
+	}
+	return score, true, nil
+}
+
+//  记录权重中已经带有时间的成员的 hashmap
+func (lb *Leaderboard) seen() string {
+	return lb.name + ":seen"
+}
+
+func (lb *Leaderboard) better(a, b int64) bool {
+	if lb.opts.LowerIsBetter {
+		return a < b
+	}
+	return a > b
+}
+
+//  返回成员的分数和名次
+//  返回 err，成员不存在时返回 ErrNotFound，操作成功返回 nil
+func (lb *Leaderboard) Get(ctx context.Context, member string) (*LeaderboardEntry, error) {
+	p := lb.c.WithContext(ctx).Pipeline()
+	score := p.ZGet(lb.name, member)
+	rank := p.Do(lb.rankCmd(), lb.name, member)
+	if _, err := p.Exec(); err != nil {
+		return nil, err
+	}
+	v, err := score.Int64()
+	if err != nil {
+		return nil, err
+	}
+	r, err := rank.Int64()
+	if err != nil {
+		return nil, err
+	}
+	e := &LeaderboardEntry{Member: member, Rank: r + 1}
+	e.Score, e.Time = lb.decode(v)
+	return e, nil
+}
+
+func (lb *Leaderboard) rankCmd() string {
+	if lb.opts.LowerIsBetter {
+		return "zrank"
+	}
+	return "zrrank"
+}
+
+//  按名次返回成员, 用于分页显示
+//  offset 从这个位置开始, 0 为第一名
+//  limit 最多返回的成员数
+//  返回 err，可能的错误，操作成功返回 nil
+func (lb *Leaderboard) Top(ctx context.Context, offset, limit int64) ([]LeaderboardEntry, error) {
+	c := lb.c.WithContext(ctx)
+	var keys []string
+	var scores []int64
+	var err error
+	if lb.opts.LowerIsBetter {
+		keys, scores, err = c.ZRangeSlice(lb.name, offset, limit)
+	} else {
+		keys, scores, err = c.ZRRangeSlice(lb.name, offset, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]LeaderboardEntry, len(keys))
+	for i, k := range keys {
+		entries[i] = LeaderboardEntry{Member: k, Rank: offset + int64(i) + 1}
+		entries[i].Score, entries[i].Time = lb.decode(scores[i])
+	}
+	return entries, nil
+}
+
+//  返回成员附近的名次, 包括成员自己和前后各最多 n 个成员
+//  返回 err，成员不存在时返回 ErrNotFound，操作成功返回 nil
+func (lb *Leaderboard) Around(ctx context.Context, member string, n int64) ([]LeaderboardEntry, error) {
+	e, err := lb.Get(ctx, member)
+	if err != nil {
+		return nil, err
+	}
+	start := e.Rank - 1 - n
+	if start < 0 {
+		start = 0
+	}
+	return lb.Top(ctx, start, e.Rank-start+n)
+}
+
+//  返回成员数
+//  返回 err，可能的错误，操作成功返回 nil
+func (lb *Leaderboard) Size(ctx context.Context) (int64, error) {
+	return lb.c.WithContext(ctx).ZSize(lb.name)
+}
+
+//  删除成员
+//  返回 err，可能的错误，操作成功返回 nil
+func (lb *Leaderboard) Remove(ctx context.Context, member ...string) error {
+	if len(member) == 0 {
+		return nil
+	}
+	c := lb.c.WithContext(ctx)
+	if !lb.opts.DisableTieBreak {
+		// 先删除标记: 两步之间的 SubmitIncrement 最多多加一次时间, 随后成员被删除
+		if err := c.MultiHDel(lb.seen(), member...); err != nil {
+			return err
+		}
+	}
+	return c.MultiZDel(lb.name, member...)
+}
+
+//  只保留排名前 max 的成员, 删除其余的成员
+//  返回 removed, 删除的成员数
+//  返回 err，可能的错误，操作成功返回 nil
+func (lb *Leaderboard) Trim(ctx context.Context, max int64) (removed int64, err error) {
+	c := lb.c.WithContext(ctx)
+	size, err := c.ZSize(lb.name)
+	if err != nil || size <= max {
+		return 0, err
+	}
+	removed = size - max
+	if lb.opts.DisableTieBreak {
+		// zset 按权重从小到大排列, 最差的成员在分数越大越好时位于开头, 否则位于末尾
+		if lb.opts.LowerIsBetter {
+			err = c.ZRemRangeByRank(lb.name, max, size-1)
+		} else {
+			err = c.ZRemRangeByRank(lb.name, 0, removed-1)
+		}
+		if err != nil {
+			return 0, err
+		}
+		return removed, nil
+	}
+	// 需要同时删除 name:seen 中的标记, 每次读出 BatchSize 个最差的成员再用 Remove 删除
+	for left := removed; left > 0; {
+		n := min(left, int64(lb.opts.BatchSize))
+		var members []string
+		if lb.opts.LowerIsBetter {
+			members, _, err = c.ZRRangeSlice(lb.name, 0, n)
+		} else {
+			members, _, err = c.ZRangeSlice(lb.name, 0, n)
+		}
+		if err != nil {
+			return removed - left, err
+		}
+		if len(members) == 0 {
+			break
+		}
+		if err = lb.Remove(ctx, members...); err != nil {
+			return removed - left, err
+		}
+		left -= int64(len(members))
+	}
+	return removed, nil
+}
+
+//  批量写入成员的分数, 覆盖原来的分数, 每 BatchSize 个成员用一条 multi_zset 命令写入.
+//  开启并列排序时所有成员的时间为 at
+//  返回 err，可能的错误，操作成功返回 nil
+func (lb *Leaderboard) BulkLoad(ctx context.Context, scores map[string]int64, at time.Time) error {
+	c := lb.c.WithContext(ctx)
+	batch := make(map[string]int64, lb.opts.BatchSize)
+	for member, score := range scores {
+		v, err := lb.encode(score, at)
+		if err != nil {
+			return err
+		}
+		batch[member] = v
+		if len(batch) == lb.opts.BatchSize {
+			if err = lb.load(c, batch); err != nil {
+				return err
+			}
+			batch = make(map[string]int64, lb.opts.BatchSize)
+		}
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	return lb.load(c, batch)
+}
+
+//  写入一批权重, 与 Submit 相同先标记成员
+func (lb *Leaderboard) load(c *DbClient, batch map[string]int64) error {
+	if !lb.opts.DisableTieBreak {
+		seen := make(map[string]interface{}, len(batch))
+		for member := range batch {
+			seen[member] = 1
+		}
+		if err := c.MultiHSet(lb.seen(), seen); err != nil {
+			return err
+		}
+	}
+	return c.MultiZSet(lb.name, batch)
+}
@@ -0,0 +1,198 @@
+package gossdb_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLeaderboard(t *testing.T) {
+	db, _ := newTestClient(t)
+	ctx := context.Background()
+	lb := db.NewLeaderboard("lb", nil)
+
+	// 同分时先提交的排在前面
+	at := time.Now().Add(-time.Hour)
+	if err := lb.BulkLoad(ctx, map[string]int64{"a": 30, "b": 10, "c": 20}, at); err != nil {
+		t.Fatalf("BulkLoad fail err: %v", err)
+	}
+	if _, _, err := lb.Submit(ctx, "d", 20, SubmitAlways); err != nil {
+		t.Fatalf("Submit fail err: %v", err)
+	}
+	top, err := lb.Top(ctx, 0, 10)
+	if err != nil {
+		t.Fatalf("Top fail err: %v", err)
+	}
+	if got := entriesString(top); got != "1:a=30 2:c=20 3:d=20 4:b=10" {
+		t.Errorf("Top = %s", got)
+	}
+	if !top[1].Time.Equal(at.Truncate(time.Second)) {
+		t.Errorf("Time = %v, want %v", top[1].Time, at)
+	}
+	if page, _ := lb.Top(ctx, 2, 2); entriesString(page) != "3:d=20 4:b=10" {
+		t.Errorf("second page = %s", entriesString(page))
+	}
+
+	// SubmitBest 只接受更好的分数
+	if cur, ok, _ := lb.Submit(ctx, "a", 25, SubmitBest); ok || cur != 30 {
+		t.Errorf("Submit worse = %d, %v", cur, ok)
+	}
+	if cur, ok, _ := lb.Submit(ctx, "b", 40, SubmitBest); !ok || cur != 40 {
+		t.Errorf("Submit better = %d, %v", cur, ok)
+	}
+	if cur, ok, _ := lb.Submit(ctx, "e", 5, SubmitBest); !ok || cur != 5 {
+		t.Errorf("Submit new = %d, %v", cur, ok)
+	}
+	// SubmitIncrement 保留原来的时间
+	if cur, _, err := lb.Submit(ctx, "c", 15, SubmitIncrement); err != nil || cur != 35 {
+		t.Errorf("Submit increment = %d, %v", cur, err)
+	}
+	if cur, _, err := lb.Submit(ctx, "f", -3, SubmitIncrement); err != nil || cur != -3 {
+		t.Errorf("Submit increment new = %d, %v", cur, err)
+	}
+	e, err := lb.Get(ctx, "c")
+	if err != nil || e.Score != 35 || e.Rank != 2 || !e.Time.Equal(at.Truncate(time.Second)) {
+		t.Errorf("Get = %+v, %v", e, err)
+	}
+	if _, err = lb.Get(ctx, "none"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get missing err = %v", err)
+	}
+
+	around, err := lb.Around(ctx, "a", 1)
+	if err != nil {
+		t.Fatalf("Around fail err: %v", err)
+	}
+	if got := entriesString(around); got != "2:c=35 3:a=30 4:d=20" {
+		t.Errorf("Around = %s", got)
+	}
+	if around, _ = lb.Around(ctx, "b", 2); entriesString(around) != "1:b=40 2:c=35 3:a=30" {
+		t.Errorf("Around first = %s", entriesString(around))
+	}
+
+	if _, _, err = lb.Submit(ctx, "g", 1<<40, SubmitAlways); !errors.Is(err, ErrScoreOutOfRange) {
+		t.Errorf("Submit out of range err = %v", err)
+	}
+
+	if n, err := lb.Trim(ctx, 3); err != nil || n != 3 {
+		t.Errorf("Trim = %d, %v", n, err)
+	}
+	if top, _ = lb.Top(ctx, 0, 10); entriesString(top) != "1:b=40 2:c=35 3:a=30" {
+		t.Errorf("Top after Trim = %s", entriesString(top))
+	}
+	lb.Remove(ctx, "c")
+	if size, _ := lb.Size(ctx); size != 2 {
+		t.Errorf("Size = %d", size)
+	}
+}
+
+func TestLeaderboardLowerIsBetter(t *testing.T) {
+	db, _ := newTestClient(t)
+	ctx := context.Background()
+	lb := db.NewLeaderboard("lb", &LeaderboardOptions{LowerIsBetter: true, BatchSize: 2})
+
+	at := time.Now().Add(-time.Hour)
+	scores := map[string]int64{}
+	for i := 0; i < 5; i++ {
+		scores[fmt.Sprint("p", i)] = int64(100 + i*10)
+	}
+	lb.BulkLoad(ctx, scores, at)
+	lb.Submit(ctx, "late", 110, SubmitAlways)
+	if cur, ok, _ := lb.Submit(ctx, "p0", 90, SubmitBest); !ok || cur != 90 {
+		t.Errorf("Submit better = %d, %v", cur, ok)
+	}
+	if cur, ok, _ := lb.Submit(ctx, "p1", 120, SubmitBest); ok || cur != 110 {
+		t.Errorf("Submit worse = %d, %v", cur, ok)
+	}
+	top, _ := lb.Top(ctx, 0, 3)
+	if got := entriesString(top); got != "1:p0=90 2:p1=110 3:late=110" {
+		t.Errorf("Top = %s", got)
+	}
+	if n, err := lb.Trim(ctx, 2); err != nil || n != 4 {
+		t.Errorf("Trim = %d, %v", n, err)
+	}
+	if top, _ = lb.Top(ctx, 0, 10); entriesString(top) != "1:p0=90 2:p1=110" {
+		t.Errorf("Top after Trim = %s", entriesString(top))
+	}
+}
+
+func TestLeaderboardNoTieBreak(t *testing.T) {
+	db, _ := newTestClient(t)
+	ctx := context.Background()
+	lb := db.NewLeaderboard("lb", &LeaderboardOptions{DisableTieBreak: true})
+
+	lb.Submit(ctx, "a", 1<<40, SubmitAlways)
+	if cur, _, err := lb.Submit(ctx, "a", 2, SubmitIncrement); err != nil || cur != 1<<40+2 {
+		t.Errorf("Submit increment = %d, %v", cur, err)
+	}
+	if v, _ := db.ZGet("lb", "a"); v != 1<<40+2 {
+		t.Errorf("stored score = %d", v)
+	}
+	e, err := lb.Get(ctx, "a")
+	if err != nil || e.Rank != 1 || !e.Time.IsZero() {
+		t.Errorf("Get = %+v, %v", e, err)
+	}
+}
+
+func TestLeaderboardIncrementTie(t *testing.T) {
+	db := newTestPoolClient(t)
+	ctx := context.Background()
+	lb := db.NewLeaderboard("lb", nil)
+
+	// 并发的第一次提交只加一次时间
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := lb.Submit(ctx, "x", 1, SubmitIncrement); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	e, err := lb.Get(ctx, "x")
+	if err != nil || e.Score != 20 || time.Since(e.Time) > time.Minute || time.Since(e.Time) < -time.Minute {
+		t.Errorf("Get = %+v, %v", e, err)
+	}
+
+	// 低位恰好为 0 的时间不会被再次加上
+	low := db.NewLeaderboard("low", &LeaderboardOptions{LowerIsBetter: true})
+	epoch := time.Unix(leaderboardEpoch, 0)
+	low.BulkLoad(ctx, map[string]int64{"a": 10}, epoch.Add(-time.Hour))
+	if cur, _, err := low.Submit(ctx, "a", 5, SubmitIncrement); err != nil || cur != 15 {
+		t.Errorf("Submit increment = %d, %v", cur, err)
+	}
+	if e, err = low.Get(ctx, "a"); err != nil || e.Score != 15 || !e.Time.Equal(epoch) {
+		t.Errorf("Get = %+v, %v", e, err)
+	}
+
+	// 删除的成员再次提交时重新加上时间
+	low.Remove(ctx, "a")
+	low.Submit(ctx, "a", 5, SubmitIncrement)
+	if e, err = low.Get(ctx, "a"); err != nil || e.Score != 5 || time.Since(e.Time) > time.Minute {
+		t.Errorf("Get after Remove = %+v, %v", e, err)
+	}
+	for i := 0; i < 5; i++ {
+		low.Submit(ctx, fmt.Sprint("m", i), int64(i), SubmitAlways)
+	}
+	if n, err := low.Trim(ctx, 2); err != nil || n != 4 {
+		t.Errorf("Trim = %d, %v", n, err)
+	}
+	if size, _ := db.HSize("low:seen"); size != 2 {
+		t.Errorf("seen size after Trim = %d", size)
+	}
+}
+
+func entriesString(entries []LeaderboardEntry) string {
+	s := ""
+	for i, e := range entries {
+		if i > 0 {
+			s += " "
+		}
+		s += fmt.Sprintf("%d:%s=%d", e.Rank, e.Member, e.Score)
+	}
+	return s
+}
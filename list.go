@@ -1,7 +1,6 @@
 package gossdb_client
 
 import (
-	"fmt"
 	"github.com/houbin910902/to"
 )
 
@@ -18,9 +17,9 @@ var (
 //  返回 size，队列的长度；
 //  返回 err，执行的错误，操作成功返回 nil
 func (c *DbClient) Qsize(name string) (size int64, err error) {
-	resp, err := c.Client.Do("qsize", name)
+	resp, err := c.do("qsize", name)
 	if err != nil {
-		return -1, fmt.Errorf("QSize %s error: %s", name, err.Error())
+		return -1, err
 	}
 
 	if len(resp) == 2 && resp[0] == "ok" {
@@ -34,9 +33,9 @@ func (c *DbClient) Qsize(name string) (size int64, err error) {
 //  name  队列的名字
 //  返回 err，执行的错误，操作成功返回 nil
 func (c *DbClient) QClear(name string) (err error) {
-	resp, err := c.Client.Do("qclear", name)
+	resp, err := c.do("qclear", name)
 	if err != nil {
-		return fmt.Errorf("QClear %s error: %s", name, err.Error())
+		return err
 	}
 
 	if len(resp) > 0 && resp[0] == "ok" {
@@ -74,9 +73,9 @@ func (c *DbClient) qPush(name string, reverse bool, value ...interface{}) (size
 
 	args = append(args, value...)
 
-	resp, err := c.Client.Do(args...)
+	resp, err := c.do(args...)
 	if err != nil {
-		return -1, fmt.Errorf("%s %s error: %s", qPushCmd[index], name, err.Error())
+		return -1, err
 	}
 	if len(resp) == 2 && resp[0] == "ok" {
 		return to.Int64(resp[1]), nil
@@ -107,7 +106,7 @@ func (c *DbClient) QPushBack(name string, value ...interface{}) (size int64, err
 //从队列首部弹出最后一个元素.
 //
 //  name 队列的名字
-//  返回 v，返回一个元素，并在队列中删除 v；队列为空时返回空值
+//  返回 v，返回一个元素，并在队列中删除 v；队列为空时返回 ErrNotFound
 //  返回 err，执行的错误，操作成功返回 nil
 func (c *DbClient) QPopFront(name string) (v string, err error) {
 	return c.QPop(name)
@@ -116,7 +115,7 @@ func (c *DbClient) QPopFront(name string) (v string, err error) {
 //从队列尾部弹出最后一个元素.
 //
 //  name 队列的名字
-//  返回 v，返回一个元素，并在队列中删除 v；队列为空时返回空值
+//  返回 v，返回一个元素，并在队列中删除 v；队列为空时返回 ErrNotFound
 //  返回 err，执行的错误，操作成功返回 nil
 func (c *DbClient) QPopBack(name string) (v string, err error) {
 	return c.QPop(name, true)
@@ -125,16 +124,16 @@ func (c *DbClient) QPopBack(name string) (v string, err error) {
 //从队列首部弹出最后一个元素.
 //
 //  name 队列的名字
-//  返回 v，返回一个元素，并在队列中删除 v；队列为空时返回空值
+//  返回 v，返回一个元素，并在队列中删除 v；队列为空时返回 ErrNotFound
 //  返回 err，执行的错误，操作成功返回 nil
 func (c *DbClient) QPop(name string, reverse ...bool) (v string, err error) {
 	index := 0
 	if len(reverse) > 0 && !reverse[0] {
 		index = 1
 	}
-	resp, err := c.Client.Do(qPopCmd[index], name)
+	resp, err := c.do(qPopCmd[index], name)
 	if err != nil {
-		return "", fmt.Errorf("%s %s error: %s", qPopCmd[index], name, err.Error())
+		return "", err
 	}
 	if len(resp) == 2 && resp[0] == "ok" {
 		return to.String(resp[1]), nil
@@ -172,9 +171,9 @@ func (c *DbClient) QPopArray(name string, size int64, reverse ...bool) (v []stri
 	if len(reverse) > 0 && !reverse[0] {
 		index = 0
 	}
-	resp, err := c.Client.Do(qPopCmd[index], name, size)
+	resp, err := c.do(qPopCmd[index], name, size)
 	if err != nil {
-		return nil, fmt.Errorf("%s %s error: %s", qPopCmd[index], name, err.Error())
+		return nil, err
 	}
 
 	respsize := len(resp)
@@ -231,9 +230,9 @@ func (c *DbClient) slice(name string, args ...int) (v []string, err error) {
 	if len(args) > 2 {
 		index = args[2]
 	}
-	resp, err := c.Client.Do(qSliceCmd[index], name, begin, end)
+	resp, err := c.do(qSliceCmd[index], name, begin, end)
 	if err != nil {
-		return nil, fmt.Errorf("%s %s error: %s", qSliceCmd[index], name, err.Error())
+		return nil, err
 	}
 	size := len(resp)
 	if size >= 1 && resp[0] == "ok" {
@@ -257,9 +256,9 @@ func (c *DbClient) QTrim(name string, size int, reverse ...bool) (delSize int64,
 	if len(reverse) > 0 && reverse[0] {
 		index = 1
 	}
-	resp, err := c.Client.Do(qTrimCmd[index], name, size)
+	resp, err := c.do(qTrimCmd[index], name, size)
 	if err != nil {
-		return -1, fmt.Errorf("%s %s error: %s", qTrimCmd[index], name, err.Error())
+		return -1, err
 	}
 	if len(resp) == 2 && resp[0] == "ok" {
 		return to.Int64(resp[1]), nil
@@ -295,9 +294,9 @@ func (c *DbClient) QTrimBack(name string, size int) (delSize int64, err error) {
 //  返回 v，返回元素的数组，为空时返回 nil
 //  返回 err，执行的错误，操作成功返回 nil
 func (c *DbClient) QList(nameStart, nameEnd string, limit int64) ([]string, error) {
-	resp, err := c.Client.Do("qlist", nameStart, nameEnd, limit)
+	resp, err := c.do("qlist", nameStart, nameEnd, limit)
 	if err != nil {
-		return nil, fmt.Errorf("QList %s %s %v error: %s", nameStart, nameEnd, limit, err.Error())
+		return nil, err
 	}
 
 	if len(resp) > 0 && resp[0] == "ok" {
@@ -320,9 +319,9 @@ func (c *DbClient) QList(nameStart, nameEnd string, limit int64) ([]string, erro
 //  返回 v，返回元素的数组，为空时返回 nil
 //  返回 err，执行的错误，操作成功返回 nil
 func (c *DbClient) QRList(nameStart, nameEnd string, limit int64) ([]string, error) {
-	resp, err := c.Client.Do("qrlist", nameStart, nameEnd, limit)
+	resp, err := c.do("qrlist", nameStart, nameEnd, limit)
 	if err != nil {
-		return nil, fmt.Errorf("QRList %s %s %v error: %s", nameStart, nameEnd, limit, err.Error())
+		return nil, err
 	}
 
 	if len(resp) > 0 && resp[0] == "ok" {
@@ -346,10 +345,10 @@ func (c *DbClient) QRList(nameStart, nameEnd string, limit int64) ([]string, err
 func (c *DbClient) QSet(key string, index int64, val interface{}) (err error) {
 	var resp []string
 
-	resp, err = c.Client.Do("qset", key, index, val)
+	resp, err = c.do("qset", key, index, val)
 
 	if err != nil {
-		return fmt.Errorf("QSet %s error: %s", key, err.Error())
+		return err
 	}
 	if len(resp) > 0 && resp[0] == "ok" {
 		return nil
@@ -364,9 +363,9 @@ func (c *DbClient) QSet(key string, index int64, val interface{}) (err error) {
 //  返回 val，返回的值.
 //  返回 err，执行的错误，操作成功返回 nil
 func (c *DbClient) QGet(key string, index int64) (string, error) {
-	resp, err := c.Client.Do("qget", key, index)
+	resp, err := c.do("qget", key, index)
 	if err != nil {
-		return "", fmt.Errorf("QGet %s error: %s", key, err.Error())
+		return "", err
 	}
 	if len(resp) == 2 && resp[0] == "ok" {
 		return to.String(resp[1]), nil
@@ -380,9 +379,9 @@ func (c *DbClient) QGet(key string, index int64) (string, error) {
 //  返回 val，返回的值.
 //  返回 err，执行的错误，操作成功返回 nil
 func (c *DbClient) QFront(key string) (string, error) {
-	resp, err := c.Client.Do("qfront", key)
+	resp, err := c.do("qfront", key)
 	if err != nil {
-		return "", fmt.Errorf("QFront %s error: %s", key, err.Error())
+		return "", err
 	}
 	if len(resp) == 2 && resp[0] == "ok" {
 		return to.String(resp[1]), nil
@@ -396,9 +395,9 @@ func (c *DbClient) QFront(key string) (string, error) {
 //  返回 val，返回的值.
 //  返回 err，执行的错误，操作成功返回 nil
 func (c *DbClient) QBack(key string) (string, error) {
-	resp, err := c.Client.Do("qback", key)
+	resp, err := c.do("qback", key)
 	if err != nil {
-		return "", fmt.Errorf("QBack %s error: %s", key, err.Error())
+		return "", err
 	}
 	if len(resp) == 2 && resp[0] == "ok" {
 		return to.String(resp[1]), nil
@@ -423,9 +422,9 @@ func (c *DbClient) qPushArray(name string, reverse bool, value []interface{}) (s
 	}
 	args := []interface{}{qPushCmd[index], name}
 	args = append(args, value...)
-	resp, err := c.Client.Do(args...)
+	resp, err := c.do(args...)
 	if err != nil {
-		return -1, fmt.Errorf("%s %s error: %s", qPushCmd[index], name, err.Error())
+		return -1, err
 	}
 	if len(resp) == 2 && resp[0] == "ok" {
 		return to.Int64(resp[1]), nil
@@ -0,0 +1,454 @@
+package gossdb_client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	mrand "math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/houbin910902/gossdb_client/gossdb/ssdb"
+)
+
+//  锁的默认租期
+const DefaultLockTTL = 10 * time.Second
+
+var (
+	// 锁被其它持有者持有, TryLock 在超时之前没有获得锁
+	ErrLockHeld = errors.New("gossdb_client: lock is held by another owner")
+	// 当前持有者没有持有锁: 没有获得锁, 已经释放, 或者租期已过被其它持有者获得
+	ErrLockNotHeld = errors.New("gossdb_client: lock not held")
+)
+
+//  锁的选项
+type LockOptions struct {
+	// 租期, 向上取整到秒, 默认 DefaultLockTTL
+	TTL time.Duration
+	// 自动续租的间隔, 默认 TTL/3, 必须小于 TTL - TTL/5; 小于 0 时不自动续租, 需要在租期内调用 Renew
+	RenewInterval time.Duration
+	// Lock 和 TryLock 第一次重试前的等待时间, 之后每次翻倍. 默认 20ms
+	MinBackoff time.Duration
+	// 最长的等待时间. 默认 1s
+	MaxBackoff time.Duration
+	// 持有者的标识, 默认随机生成. 释放和续租时只有标识相同才会修改 key
+	Token string
+}
+
+//  基于 ssdb 的分布式锁.
+//
+//  key 的值为持有者的标识和租期的截止时间, 同时设置了相同的过期时间, 持有者崩溃后锁在租期结束时自动释放.
+//  获得锁后在后台定期续租, 续租失败(锁被删除或者被其它持有者获得)时 Context 返回的 ctx 被取消,
+//  持有锁期间执行的操作应当使用这个 ctx.
+//
+//  获得、续租和释放锁都不是原子操作: ssdb 没有带过期时间的 setnx, 也没有比较后修改的命令或脚本, 无法用一条命令完成.
+//   - 获得锁时先 setnx 再 expire. 如果两条命令之间持有者崩溃或者 expire 失败, key 没有过期时间,
+//     其它持有者在值中的截止时间之后会用 getset 清除这个遗留的锁; 两个持有者同时清除时双方都可能失去锁.
+//   - 续租和释放时先 get 比较标识, 再 setx 或 del. 如果两步之间 key 过期并被其它持有者获得, 会覆盖或删除对方的锁.
+//     为此剩余的租期不足 TTL/5 时不再修改 key, 视为已经失去锁; 这要求两条命令的间隔远小于 TTL/5,
+//     并且客户端和服务端的时钟速率相近.
+//  因此这个锁适合用来避免重复的工作, 不能单独保证严格的互斥; 需要严格互斥时应当在受保护的资源上另外校验.
+//
+//  c 应当可以被多个 goroutine 同时使用, 例如 Pool.Client() 或者开启了断线重连的 client; 后台续租与业务代码共用 c.
+//  同一个 Lock 不能被多个 goroutine 同时获得, 每个持有者使用自己的 Lock. 用法:
+//
+//	l, err := db.NewLock("lock:job", nil)
+//	if err != nil {
+//		return err
+//	}
+//	if err := l.TryLock(ctx, 5*time.Second); err != nil {
+//		return err
+//	}
+//	defer l.Unlock(context.Background())
+//	doJob(l.Context())
+type Lock struct {
+	c     *DbClient
+	key   string
+	token string
+	opts  LockOptions
+	// 向上取整的租期(秒), 用于 expire 和 setx
+	ttlSec int64
+
+	// 串行化续租和释放的网络操作. 只有持有 opMu 时才会失去锁, 因此网络操作期间不需要持有 mu
+	opMu sync.Mutex
+	// 保护以下的状态, 不会在网络操作期间持有, Held 和 Context 不会被续租阻塞
+	mu       sync.Mutex
+	held     bool
+	deadline time.Time
+	ctx      context.Context
+	cancel   context.CancelCauseFunc
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+//  创建一个锁, 不会访问服务端
+//  key 锁的 key
+//  opts 选项, 可以为 nil
+//  返回 err，RenewInterval 不小于 TTL - TTL/5 时返回 ssdb.ErrBadArguments, 此时续租总会在剩余的租期不足 TTL/5 之后才开始
+func (c *DbClient) NewLock(key string, opts *LockOptions) (*Lock, error) {
+	var o LockOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.TTL <= 0 {
+		o.TTL = DefaultLockTTL
+	}
+	ttlSec := int64(math.Ceil(o.TTL.Seconds()))
+	if ttlSec < 1 {
+		ttlSec = 1
+	}
+	if o.RenewInterval == 0 {
+		o.RenewInterval = o.TTL / 3
+	}
+	if limit := o.TTL - o.TTL/5; o.RenewInterval >= limit {
+		return nil, fmt.Errorf("%w: lock RenewInterval %v must be less than %v", ssdb.ErrBadArguments, o.RenewInterval, limit)
+	}
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = 20 * time.Millisecond
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = time.Second
+	}
+	if o.MaxBackoff < o.MinBackoff {
+		o.MaxBackoff = o.MinBackoff
+	}
+	if o.Token == "" {
+		o.Token = randomToken()
+	}
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(ErrLockNotHeld)
+	return &Lock{c: c, key: key, token: o.Token, opts: o, ttlSec: ttlSec, ctx: ctx, cancel: cancel}, nil
+}
+
+func randomToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+//  key 的值: 标识 + ":" + 租期截止时间(unix 毫秒)
+func lockValue(token string, deadline time.Time) string {
+	return token + ":" + strconv.FormatInt(deadline.UnixMilli(), 10)
+}
+
+func parseLockValue(v string) (token string, deadline time.Time, ok bool) {
+	i := strings.LastIndexByte(v, ':')
+	if i < 0 {
+		return "", time.Time{}, false
+	}
+	ms, err := strconv.ParseInt(v[i+1:], 10, 64)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return v[:i], time.UnixMilli(ms), true
+}
+
+//  返回锁的 key
+func (l *Lock) Key() string {
+	return l.key
+}
+
+//  返回持有者的标识
+func (l *Lock) Token() string {
+	return l.token
+}
+
+//  返回是否持有锁. 只反映本地的状态, 失去锁要到下一次续租时才能发现
+func (l *Lock) Held() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.held
+}
+
+//  返回持有锁期间有效的 ctx, 失去锁时被取消, context.Cause 返回 ErrLockNotHeld;
+//  Unlock 之后被取消, context.Cause 返回 context.Canceled. 没有持有锁时返回已经取消的 ctx
+func (l *Lock) Context() context.Context {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ctx
+}
+
+//  获得锁, 锁被其它持有者持有时按指数退避重试, 直到获得锁或者 ctx 取消
+//  返回 err，可能的错误，操作成功返回 nil
+func (l *Lock) Lock(ctx context.Context) error {
+	return l.acquire(ctx, time.Time{})
+}
+
+//  尝试获得锁, 最多等待 timeout, timeout 为 0 时只尝试一次
+//  返回 err，超时返回 ErrLockHeld，操作成功返回 nil
+func (l *Lock) TryLock(ctx context.Context, timeout time.Duration) error {
+	return l.acquire(ctx, time.Now().Add(timeout))
+}
+
+//  until 为零值时不限制等待时间
+func (l *Lock) acquire(ctx context.Context, until time.Time) error {
+	l.mu.Lock()
+	held := l.held
+	l.mu.Unlock()
+	if held {
+		return nil
+	}
+	backoff := l.opts.MinBackoff
+	for {
+		ok, err := l.tryAcquire(ctx)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		// 随机等待 backoff 的 50%~100%, 避免多个持有者同时重试
+		wait := backoff/2 + time.Duration(mrand.Int63n(int64(backoff/2)+1))
+		if !until.IsZero() {
+			left := time.Until(until)
+			if left <= 0 {
+				return ErrLockHeld
+			}
+			if wait > left {
+				wait = left
+			}
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		if backoff *= 2; backoff > l.opts.MaxBackoff {
+			backoff = l.opts.MaxBackoff
+		}
+	}
+}
+
+//  尝试一次, 返回是否获得了锁. setnx 和 expire 是两条命令, 不是原子的, 参见 Lock
+func (l *Lock) tryAcquire(ctx context.Context) (bool, error) {
+	c := l.c.WithContext(ctx)
+	deadline := time.Now().Add(l.opts.TTL)
+	r, err := c.SetNx(l.key, lockValue(l.token, deadline))
+	if err != nil {
+		return false, err
+	}
+	if r != "1" {
+		return l.breakOrphan(c)
+	}
+	if err = l.expire(c); err != nil {
+		return false, err
+	}
+	l.acquired(deadline)
+	return true, nil
+}
+
+//  为刚刚写入的锁设置过期时间. 失败时 key 没有过期时间, 删除它并返回错误, 不会获得锁;
+//  删除同样失败时由其它持有者在租期之后清除, 参见 breakOrphan
+func (l *Lock) expire(c *DbClient) error {
+	_, err := c.Expire(l.key, l.ttlSec)
+	if err != nil {
+		// c 的 ctx 可能已经取消, 删除不使用它
+		l.c.Del(l.key)
+	}
+	return err
+}
+
+//  锁已被持有时检查是否是没有过期时间并且租期已过的遗留的锁, 是则用 getset 取而代之.
+//  两个持有者同时清除时后执行 getset 的一方覆盖了先获得锁的一方的值, 双方都会失去锁:
+//  后者发现 getset 的返回值不是遗留的值, 前者在下一次续租时发现标识不同
+func (l *Lock) breakOrphan(c *DbClient) (bool, error) {
+	old, err := c.Get(l.key)
+	if errors.Is(err, ErrNotFound) {
+		// 锁刚刚被释放, 下次重试
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	_, oldDeadline, ok := parseLockValue(old)
+	if !ok || time.Now().Before(oldDeadline) {
+		return false, nil
+	}
+	ttl, err := c.Ttl(l.key)
+	if err != nil || ttl != -1 {
+		// 有过期时间的锁由服务端释放
+		return false, err
+	}
+	deadline := time.Now().Add(l.opts.TTL)
+	prev, err := c.GetSet(l.key, lockValue(l.token, deadline))
+	switch {
+	case errors.Is(err, ErrNotFound):
+		// key 在 getset 之前被删除, getset 设置了新的值, 同样获得了锁
+	case err != nil:
+		return false, err
+	case prev != old:
+		return false, nil
+	}
+	if err = l.expire(c); err != nil {
+		return false, err
+	}
+	l.acquired(deadline)
+	return true, nil
+}
+
+func (l *Lock) acquired(deadline time.Time) {
+	// 手动 Renew 失去锁时上一次的续租可能还没有退出
+	l.stopRenew()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.held = true
+	l.deadline = deadline
+	l.ctx, l.cancel = context.WithCancelCause(context.Background())
+	if l.opts.RenewInterval > 0 {
+		l.stop, l.done = make(chan struct{}), make(chan struct{})
+		go l.renewLoop(l.stop, l.done)
+	}
+}
+
+//  剩余的租期不足 margin 时不再修改 key, 避免在读取和修改之间 key 过期并被其它持有者获得
+func (l *Lock) margin() time.Duration {
+	return l.opts.TTL / 5
+}
+
+//  调用时持有 l.mu
+func (l *Lock) lose(cause error) {
+	l.held = false
+	l.cancel(cause)
+}
+
+//  调用时持有 l.opMu, 不持有 l.mu
+func (l *Lock) drop(cause error) {
+	l.mu.Lock()
+	l.lose(cause)
+	l.mu.Unlock()
+}
+
+//  返回是否持有锁和租期的截止时间, 剩余的租期不足 margin 时失去锁
+func (l *Lock) snapshot() (bool, time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.held && time.Until(l.deadline) <= l.margin() {
+		l.lose(ErrLockNotHeld)
+	}
+	return l.held, l.deadline
+}
+
+//  续租, 把租期延长为从现在开始的 TTL. 自动续租时不需要调用.
+//  先 get 比较标识再 setx, 不是原子的, 剩余的租期不足 TTL/5 时视为已经失去锁, 参见 Lock
+//  返回 err，失去锁时返回 ErrLockNotHeld，操作成功返回 nil
+func (l *Lock) Renew(ctx context.Context) error {
+	l.opMu.Lock()
+	defer l.opMu.Unlock()
+	return l.renew(ctx)
+}
+
+//  调用时持有 l.opMu
+func (l *Lock) renew(ctx context.Context) error {
+	held, old := l.snapshot()
+	if !held {
+		return ErrLockNotHeld
+	}
+	c := l.c.WithContext(ctx)
+	v, err := c.Get(l.key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if token, _, _ := parseLockValue(v); err != nil || token != l.token || time.Until(old) <= l.margin() {
+		l.drop(ErrLockNotHeld)
+		return ErrLockNotHeld
+	}
+	deadline := time.Now().Add(l.opts.TTL)
+	if err = c.Set(l.key, lockValue(l.token, deadline), l.ttlSec); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.deadline = deadline
+	l.mu.Unlock()
+	return nil
+}
+
+//  后台续租, 失败时按指数退避重试, 直到失去锁或者 stop 被关闭
+func (l *Lock) renewLoop(stop, done chan struct{}) {
+	defer close(done)
+	backoff := l.opts.MinBackoff
+	t := time.NewTimer(l.opts.RenewInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+		l.opMu.Lock()
+		_, deadline := l.snapshot()
+		// 续租必须在 key 可能过期之前完成
+		ctx, cancel := context.WithDeadline(context.Background(), deadline.Add(-l.margin()))
+		err := l.renew(ctx)
+		cancel()
+		held, deadline := l.snapshot()
+		l.opMu.Unlock()
+		if !held {
+			return
+		}
+		left := time.Until(deadline) - l.margin()
+		next := l.opts.RenewInterval
+		if err != nil {
+			next = backoff
+			if backoff *= 2; backoff > l.opts.MaxBackoff {
+				backoff = l.opts.MaxBackoff
+			}
+		} else {
+			backoff = l.opts.MinBackoff
+		}
+		if next > left {
+			next = left
+		}
+		t.Reset(next)
+	}
+}
+
+//  停止后台续租并等待续租的 goroutine 退出
+func (l *Lock) stopRenew() {
+	l.mu.Lock()
+	stop, done := l.stop, l.done
+	l.stop, l.done = nil, nil
+	l.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+//  释放锁. 只删除自己持有的锁; 剩余的租期很短时不删除 key, 由服务端在过期时释放.
+//  先 get 比较标识再 del, 不是原子的, 参见 Lock
+//  返回 err，没有持有锁或者已经失去锁时返回 ErrLockNotHeld，操作成功返回 nil
+func (l *Lock) Unlock(ctx context.Context) error {
+	l.stopRenew()
+	l.opMu.Lock()
+	defer l.opMu.Unlock()
+	l.mu.Lock()
+	held, deadline := l.held, l.deadline
+	l.mu.Unlock()
+	if !held {
+		return ErrLockNotHeld
+	}
+	c := l.c.WithContext(ctx)
+	v, err := c.Get(l.key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		l.drop(context.Canceled)
+		return err
+	}
+	if token, _, _ := parseLockValue(v); err != nil || token != l.token {
+		l.drop(ErrLockNotHeld)
+		return ErrLockNotHeld
+	}
+	if time.Until(deadline) <= l.margin() {
+		l.drop(context.Canceled)
+		return nil
+	}
+	err = c.Del(l.key)
+	l.drop(context.Canceled)
+	return err
+}
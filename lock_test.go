@@ -0,0 +1,294 @@
+package gossdb_client
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/houbin910902/gossdb_client/gossdb/ssdb"
+)
+
+func newTestPoolClient(t *testing.T) *PooledClient {
+	_, conf := newTestNode(t)
+	p, err := NewPool(&conf)
+	if err != nil {
+		t.Fatalf("NewPool fail err: %s", err.Error())
+	}
+	t.Cleanup(func() { p.Close() })
+	return p.Client()
+}
+
+func newTestLock(t *testing.T, db *PooledClient, key string, opts *LockOptions) *Lock {
+	l, err := db.NewLock(key, opts)
+	if err != nil {
+		t.Fatalf("NewLock fail err: %v", err)
+	}
+	return l
+}
+
+func TestLock(t *testing.T) {
+	db := newTestPoolClient(t)
+	ctx := context.Background()
+
+	l1 := newTestLock(t, db, "lock", &LockOptions{TTL: 2 * time.Second})
+	l2 := newTestLock(t, db, "lock", nil)
+	if l1.Token() == l2.Token() {
+		t.Fatal("tokens should be unique")
+	}
+	if err := l1.Context().Err(); err == nil {
+		t.Fatal("Context should be done before Lock")
+	}
+	if err := l1.TryLock(ctx, 0); err != nil {
+		t.Fatalf("TryLock fail err: %v", err)
+	}
+	if !l1.Held() || l1.Context().Err() != nil {
+		t.Fatal("l1 should hold the lock")
+	}
+	if ttl, _ := db.Ttl("lock"); ttl != 2 {
+		t.Errorf("ttl = %d, want 2", ttl)
+	}
+	if err := l2.TryLock(ctx, 0); !errors.Is(err, ErrLockHeld) {
+		t.Fatalf("TryLock err = %v, want ErrLockHeld", err)
+	}
+	start := time.Now()
+	if err := l2.TryLock(ctx, 100*time.Millisecond); !errors.Is(err, ErrLockHeld) {
+		t.Fatalf("TryLock with timeout err = %v, want ErrLockHeld", err)
+	}
+	if d := time.Since(start); d < 100*time.Millisecond {
+		t.Errorf("TryLock returned after %v", d)
+	}
+	if err := l2.Unlock(ctx); !errors.Is(err, ErrLockNotHeld) {
+		t.Errorf("Unlock without lock err = %v", err)
+	}
+
+	lockCtx := l1.Context()
+	if err := l1.Unlock(ctx); err != nil {
+		t.Fatalf("Unlock fail err: %v", err)
+	}
+	if context.Cause(lockCtx) != context.Canceled {
+		t.Errorf("cause = %v, want context.Canceled", context.Cause(lockCtx))
+	}
+	if ok, _ := db.Exists("lock"); ok {
+		t.Error("lock key should be deleted")
+	}
+	if err := l2.TryLock(ctx, 0); err != nil {
+		t.Fatalf("TryLock after Unlock fail err: %v", err)
+	}
+	if err := l1.Unlock(ctx); !errors.Is(err, ErrLockNotHeld) {
+		t.Errorf("second Unlock err = %v", err)
+	}
+	l2.Unlock(ctx)
+}
+
+func TestLockWait(t *testing.T) {
+	db := newTestPoolClient(t)
+	ctx := context.Background()
+	l1 := newTestLock(t, db, "lock", nil)
+	l2 := newTestLock(t, db, "lock", &LockOptions{MaxBackoff: 50 * time.Millisecond})
+	if err := l1.Lock(ctx); err != nil {
+		t.Fatalf("Lock fail err: %v", err)
+	}
+
+	got := make(chan error, 1)
+	go func() { got <- l2.Lock(ctx) }()
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case err := <-got:
+		t.Fatalf("Lock returned while held: %v", err)
+	default:
+	}
+	l1.Unlock(ctx)
+	select {
+	case err := <-got:
+		if err != nil {
+			t.Fatalf("Lock fail err: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Lock did not acquire after Unlock")
+	}
+	l2.Unlock(ctx)
+
+	l1.Lock(ctx)
+	defer l1.Unlock(ctx)
+	cctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := l2.Lock(cctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Lock err = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestLockRenew(t *testing.T) {
+	db := newTestPoolClient(t)
+	ctx := context.Background()
+	l := newTestLock(t, db, "lock", &LockOptions{TTL: time.Second, RenewInterval: 20 * time.Millisecond})
+	if err := l.Lock(ctx); err != nil {
+		t.Fatalf("Lock fail err: %v", err)
+	}
+	deadline := func() time.Time {
+		v, _ := db.Get("lock")
+		_, d, _ := parseLockValue(v)
+		return d
+	}
+	d1 := deadline()
+	time.Sleep(100 * time.Millisecond)
+	if d2 := deadline(); !d2.After(d1) {
+		t.Errorf("deadline not renewed: %v -> %v", d1, d2)
+	}
+
+	// 其它客户端改写了 key, 下一次续租时失去锁
+	db.Set("lock", "other:0")
+	select {
+	case <-l.Context().Done():
+	case <-time.After(time.Second):
+		t.Fatal("Context not cancelled after the lock was lost")
+	}
+	if cause := context.Cause(l.Context()); cause != ErrLockNotHeld {
+		t.Errorf("cause = %v, want ErrLockNotHeld", cause)
+	}
+	if err := l.Unlock(ctx); !errors.Is(err, ErrLockNotHeld) {
+		t.Errorf("Unlock err = %v, want ErrLockNotHeld", err)
+	}
+	if v, _ := db.Get("lock"); v != "other:0" {
+		t.Errorf("Unlock deleted the lock of another owner: %q", v)
+	}
+
+	m := newTestLock(t, db, "manual", &LockOptions{RenewInterval: -1})
+	m.Lock(ctx)
+	if err := m.Renew(ctx); err != nil {
+		t.Errorf("Renew fail err: %v", err)
+	}
+	db.Del("manual")
+	if err := m.Renew(ctx); !errors.Is(err, ErrLockNotHeld) {
+		t.Errorf("Renew err = %v, want ErrLockNotHeld", err)
+	}
+}
+
+func TestLockOrphan(t *testing.T) {
+	db := newTestPoolClient(t)
+	ctx := context.Background()
+	past := strconv.FormatInt(time.Now().Add(-time.Minute).UnixMilli(), 10)
+
+	// 持有者在 setnx 和 expire 之间崩溃, 租期已过
+	db.Set("orphan", "dead:"+past)
+	l := newTestLock(t, db, "orphan", nil)
+	if err := l.TryLock(ctx, 0); err != nil {
+		t.Fatalf("TryLock on orphan fail err: %v", err)
+	}
+	if v, _ := db.Get("orphan"); !strings.HasPrefix(v, l.Token()+":") {
+		t.Errorf("value = %q", v)
+	}
+	if ttl, _ := db.Ttl("orphan"); ttl <= 0 {
+		t.Errorf("ttl = %d", ttl)
+	}
+	l.Unlock(ctx)
+
+	// 有过期时间的锁由服务端释放, 不会被清除
+	db.Set("expiring", "dead:"+past, 10)
+	if err := newTestLock(t, db, "expiring", nil).TryLock(ctx, 0); !errors.Is(err, ErrLockHeld) {
+		t.Errorf("TryLock err = %v, want ErrLockHeld", err)
+	}
+	// 不是锁的值不会被清除
+	db.Set("plain", "value")
+	if err := newTestLock(t, db, "plain", nil).TryLock(ctx, 0); !errors.Is(err, ErrLockHeld) {
+		t.Errorf("TryLock err = %v, want ErrLockHeld", err)
+	}
+}
+
+func TestNewLockInvalid(t *testing.T) {
+	db := newTestPoolClient(t)
+	// 续租的间隔不小于 TTL - TTL/5 时, 续租开始前剩余的租期已经不足 TTL/5
+	for _, opts := range []*LockOptions{
+		{TTL: time.Second, RenewInterval: 800 * time.Millisecond},
+		{TTL: time.Second, RenewInterval: 2 * time.Second},
+	} {
+		if _, err := db.NewLock("lock", opts); !errors.Is(err, ssdb.ErrBadArguments) {
+			t.Errorf("NewLock(%+v) err = %v, want ErrBadArguments", opts, err)
+		}
+	}
+}
+
+func TestLockReacquire(t *testing.T) {
+	db := newTestPoolClient(t)
+	ctx := context.Background()
+	l := newTestLock(t, db, "lock", &LockOptions{TTL: time.Second, RenewInterval: 20 * time.Millisecond})
+	l.Lock(ctx)
+	defer l.Unlock(ctx)
+
+	// 手动 Renew 发现失去锁后立即重新获得, 上一次的后台续租被停止, 不会同时有两个
+	db.Del("lock")
+	if err := l.Renew(ctx); !errors.Is(err, ErrLockNotHeld) {
+		t.Fatalf("Renew err = %v, want ErrLockNotHeld", err)
+	}
+	l.mu.Lock()
+	done := l.done
+	l.mu.Unlock()
+	if err := l.TryLock(ctx, 0); err != nil {
+		t.Fatalf("TryLock fail err: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("the previous renewLoop is still running")
+	}
+}
+
+func TestLockRenewNotBlocking(t *testing.T) {
+	s, conf := newTestNode(t)
+	p, err := NewPool(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	db := p.Client()
+	ctx := context.Background()
+	l := newTestLock(t, db, "lock", &LockOptions{TTL: 2 * time.Second, RenewInterval: 20 * time.Millisecond})
+	if err = l.Lock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Unlock(ctx)
+
+	// 续租的网络操作期间 Held 和 Context 不会被阻塞
+	s.SetLatency(300 * time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	if !l.Held() || l.Context().Err() != nil {
+		t.Fatal("l should hold the lock")
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("Held blocked for %v during renew", d)
+	}
+	s.SetLatency(0)
+}
+
+func TestLockExpireFailure(t *testing.T) {
+	s, conf := newTestNode(t)
+	p, err := NewPool(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	db := p.Client()
+	ctx := context.Background()
+
+	// expire 失败时不会获得没有过期时间的锁, key 被删除
+	s.FailCommand("expire", "error")
+	l := newTestLock(t, db, "lock", nil)
+	var ce *CommandError
+	if err = l.TryLock(ctx, 0); !errors.As(err, &ce) || ce.Cmd != "expire" {
+		t.Fatalf("TryLock err = %v, want the expire error", err)
+	}
+	if l.Held() {
+		t.Error("l should not hold the lock")
+	}
+	if ok, _ := db.Exists("lock"); ok {
+		t.Error("lock key without ttl should be deleted")
+	}
+	s.FailCommand("expire", "")
+	if err = l.TryLock(ctx, 0); err != nil {
+		t.Fatalf("TryLock fail err: %v", err)
+	}
+	l.Unlock(ctx)
+}
@@ -0,0 +1,87 @@
+package gossdb_client
+
+//  用 client 的 Codec 编码 val 后设置到 key
+//  key 键值
+//  val 存贮的值, 可以是任意 Codec 支持的类型
+//  ttl 可选, 设置的过期时间, 单位为秒
+//  返回 err, 可能的错误, 操作成功返回nil
+func (c *DbClient) SetObject(key string, val interface{}, ttl ...int64) error {
+	data, err := c.marshal(val)
+	if err != nil {
+		return err
+	}
+	return c.Set(key, data, ttl...)
+}
+
+//  获取 key 的值并用 client 的 Codec 解码到 dst
+//  key 键值
+//  dst 解码的目标, 必须是指针
+//  返回 err, 可能的错误, key 不存在时返回 ErrNotFound, 操作成功返回 nil
+func (c *DbClient) GetObject(key string, dst interface{}) error {
+	data, err := c.GetBytes(key)
+	if err != nil {
+		return err
+	}
+	return c.unmarshalBorrowed(data, dst)
+}
+
+//  用 client 的 Codec 编码 val 后设置到 hashmap 中的 key
+//  setName hashmap 的名字
+//  key hashmap 的 key
+//  val 存贮的值, 可以是任意 Codec 支持的类型
+//  返回 err，执行的错误，操作成功返回 nil
+func (c *DbClient) HSetObject(setName, key string, val interface{}) error {
+	data, err := c.marshal(val)
+	if err != nil {
+		return err
+	}
+	return c.HSet(setName, key, data)
+}
+
+//  获取 hashmap 中 key 的值并用 client 的 Codec 解码到 dst
+//  setName hashmap 的名字
+//  key hashmap 的 key
+//  dst 解码的目标, 必须是指针
+//  返回 err，执行的错误，key 不存在时返回 ErrNotFound, 操作成功返回 nil
+func (c *DbClient) HGetObject(setName, key string, dst interface{}) error {
+	data, err := c.HGetBytes(setName, key)
+	if err != nil {
+		return err
+	}
+	return c.unmarshalBorrowed(data, dst)
+}
+
+//  用 client 的 Codec 编码后往队列的尾部添加一个或者多个元素
+//  name  队列的名字
+//  value  存贮的值，可以为多值.
+//  返回 size，添加元素之后, 队列的长度
+//  返回 err，执行的错误，操作成功返回 nil
+func (c *DbClient) QPushObject(name string, value ...interface{}) (size int64, err error) {
+	args := make([]interface{}, len(value))
+	for i, v := range value {
+		if args[i], err = c.marshal(v); err != nil {
+			return -1, err
+		}
+	}
+	return c.qPush(name, true, args...)
+}
+
+//  从队列首部弹出一个元素并用 client 的 Codec 解码到 dst
+//  name 队列的名字
+//  dst 解码的目标, 必须是指针
+//  reverse 可选, 为 true 时从队列尾部弹出
+//  返回 err，执行的错误，队列为空时返回 ErrNotFound, 操作成功返回 nil
+func (c *DbClient) QPopObject(name string, dst interface{}, reverse ...bool) error {
+	index := 0
+	if len(reverse) > 0 && reverse[0] {
+		index = 1
+	}
+	resp, err := c.doBytes(qPopCmd[index], name)
+	if err != nil {
+		return err
+	}
+	if len(resp) != 2 {
+		return handErrorBytes(resp, name)
+	}
+	return c.unmarshalBorrowed(resp[1], dst)
+}
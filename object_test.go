@@ -0,0 +1,119 @@
+package gossdb_client
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testUser struct {
+	ID    int64
+	Name  string
+	Score float64
+}
+
+type testPoint struct {
+	X, Y int32
+}
+
+func TestObject(t *testing.T) {
+	db, _ := newTestClient(t)
+	u := testUser{ID: 1, Name: "bin", Score: 9.5}
+
+	for _, codec := range []Codec{JSONCodec{}, GobCodec{}} {
+		db.SetCodec(codec)
+		var got testUser
+		if err := db.SetObject("u", u); err != nil {
+			t.Fatalf("%T SetObject: %v", codec, err)
+		}
+		if err := db.GetObject("u", &got); err != nil || got != u {
+			t.Fatalf("%T GetObject = %+v, %v", codec, got, err)
+		}
+		got = testUser{}
+		if err := db.HSetObject("h", "u", u); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.HGetObject("h", "u", &got); err != nil || got != u {
+			t.Fatalf("%T HGetObject = %+v, %v", codec, got, err)
+		}
+	}
+
+	db.SetCodec(BinaryCodec{})
+	if err := db.SetObject("p", testPoint{1, -2}); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := db.Get("p"); len(v) != 8 {
+		t.Fatalf("BinaryCodec stored %d bytes, want 8", len(v))
+	}
+	var p testPoint
+	if err := db.GetObject("p", &p); err != nil || p != (testPoint{1, -2}) {
+		t.Fatalf("GetObject = %+v, %v", p, err)
+	}
+	if err := db.SetObject("u", u); err == nil {
+		t.Fatal("BinaryCodec should reject variable-size struct")
+	}
+
+	db.SetCodec(nil)
+	if err := db.GetObject("missing", &p); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetObject err = %v, want ErrNotFound", err)
+	}
+}
+
+//  Unmarshal 直接保留 data, 不复制
+type retainCodec struct{}
+
+func (retainCodec) Marshal(v interface{}) ([]byte, error) {
+	return v.([]byte), nil
+}
+
+func (retainCodec) Unmarshal(data []byte, v interface{}) error {
+	*v.(*[]byte) = data
+	return nil
+}
+
+func TestObjectRetainedData(t *testing.T) {
+	db, _ := newTestClient(t)
+	db.SetCodec(retainCodec{})
+	db.Set("a", "aaaa")
+	db.Set("b", "bbbb")
+	db.HSet("h", "a", "aaaa")
+	db.QPush("q", "aaaa")
+
+	// 后面的命令复用接收缓冲区, 不会改写 Codec 保留的 data
+	var get, hget, qpop []byte
+	db.GetObject("a", &get)
+	db.HGetObject("h", "a", &hget)
+	db.QPopObject("q", &qpop)
+	for i := 0; i < 10; i++ {
+		db.GetBytes("b")
+	}
+	for _, got := range [][]byte{get, hget, qpop} {
+		if string(got) != "aaaa" {
+			t.Errorf("decoded data = %q, want aaaa", got)
+		}
+	}
+}
+
+func TestQueueObject(t *testing.T) {
+	db, _ := newTestClient(t)
+
+	in := []testUser{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}
+	if n, err := db.QPushObject("q", in[0], in[1]); err != nil || n != 2 {
+		t.Fatalf("QPushObject = %d, %v", n, err)
+	}
+	var out []testUser
+	for {
+		var u testUser
+		err := db.QPopObject("q", &u)
+		if errors.Is(err, ErrNotFound) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, u)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Fatalf("QPopObject = %+v, want %+v", out, in)
+	}
+}
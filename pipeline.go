@@ -0,0 +1,336 @@
+package gossdb_client
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/houbin910902/gossdb_client/gossdb/ssdb"
+)
+
+var errNotExecuted = errors.New("gossdb_client: pipeline has not been executed")
+
+//  管道, 缓存任意多条命令, 在 Exec 时通过一次写操作发出, 再按顺序读取每条命令的响应.
+//  管道不是 goroutine 安全的.
+//
+//  用法:
+//    p := db.Pipeline()
+//    p.HSet("user:1", "name", "bin")
+//    p.ZSet("rank", "user:1", 100)
+//    n := p.QPush("events", "login")
+//    if _, err := p.Exec(); err != nil { ... }
+//    size, err := n.Int64()
+type Pipeline struct {
+	c       *DbClient
+	cmds    [][]interface{}
+	replies []*Reply
+}
+
+//  管道中一条命令的响应, 在 Exec 之后可用
+type Reply struct {
+	c    *DbClient
+	args []interface{}
+	resp []string
+	err  error
+	done bool
+}
+
+//  创建一个管道, 管道中的命令使用 c 的连接和 ctx 执行
+func (c *DbClient) Pipeline() *Pipeline {
+	return &Pipeline{c: c}
+}
+
+//  在管道中加入一条原始命令. 与 DbClient.Do 相同, 运维命令不会发出, Reply.Err 返回 ErrAdminCommand.
+//  参数的类型不支持时命令同样不会发出, Reply.Err 返回的错误包含 ssdb.ErrBadArguments, 不影响管道中的其它命令
+//  args 命令及参数, 例如 "zset", "rank", "a", 1
+//  返回 命令的响应
+func (p *Pipeline) Do(args ...interface{}) *Reply {
+	err := checkNotAdmin(args)
+	if err == nil {
+		if e := ssdb.CheckArgs(args); e != nil {
+			err = newCommandError(args, e)
+		}
+	}
+	r := &Reply{c: p.c, args: args, err: err}
+	p.cmds = append(p.cmds, args)
+	p.replies = append(p.replies, r)
+	return r
+}
+
+//  返回管道中尚未执行的命令数量
+func (p *Pipeline) Len() int {
+	return len(p.cmds)
+}
+
+//  丢弃管道中尚未执行的命令
+func (p *Pipeline) Discard() {
+	p.cmds = nil
+	p.replies = nil
+}
+
+//  发出管道中的全部命令并读取响应, 执行后管道被清空, 可以继续使用.
+//  返回 replies 每条命令的响应, 顺序与加入管道的顺序一致
+//  返回 err, 网络等导致整个管道失败的错误, 此时每条命令的 Err() 也返回该错误; 单条命令的错误通过 Reply.Err() 获取
+func (p *Pipeline) Exec() (replies []*Reply, err error) {
+	cmds, replies := p.cmds, p.replies
+	p.Discard()
+	// 参数或对象编码失败的命令和运维命令不发出, 它们的 Reply 已经带有错误
+	var send [][]interface{}
+	var sent []*Reply
+	for i, r := range replies {
+		r.done = true
+		if r.err == nil {
+			send = append(send, cmds[i])
+			sent = append(sent, r)
+		}
+	}
+	if len(send) == 0 {
+		return replies, nil
+	}
+
+	resps, err := p.c.doPipeline(send)
+	for i, r := range sent {
+		if err != nil {
+			r.err = err
+			continue
+		}
+		if i < len(resps) {
+			r.resp = resps[i]
+		}
+	}
+	return replies, err
+}
+
+//  加入一条需要用 client 的 Codec 编码 val 的命令, 编码失败时命令不会发出, Reply.Err 返回编码的错误
+func (p *Pipeline) doObject(val interface{}, build func(data []byte) []interface{}) *Reply {
+	data, err := p.c.marshal(val)
+	if err != nil {
+		r := p.Do()
+		r.err = err
+		return r
+	}
+	return p.Do(build(data)...)
+}
+
+//  返回命令的错误, 操作成功返回 nil
+func (r *Reply) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	if !r.done {
+		return errNotExecuted
+	}
+	return respError(r.args, r.resp)
+}
+
+//  用 client 的 Codec 把结果解码到 dst, 适用于 get, hget, qpop 等命令, 参见 DbClient.GetObject
+func (r *Reply) Object(dst interface{}) error {
+	s, err := r.String()
+	if err != nil {
+		return err
+	}
+	return r.c.unmarshal([]byte(s), dst)
+}
+
+//  返回原始响应, 第一个元素为状态码
+func (r *Reply) Raw() []string {
+	return r.resp
+}
+
+//  返回字符串类型的结果, 适用于 get, hget, qpop 等命令
+func (r *Reply) String() (string, error) {
+	if err := r.Err(); err != nil {
+		return "", err
+	}
+	if len(r.resp) < 2 {
+		return "", nil
+	}
+	return r.resp[1], nil
+}
+
+//  返回整数类型的结果, 适用于 incr, hincr, zget, qpush 等命令
+func (r *Reply) Int64() (int64, error) {
+	s, err := r.String()
+	if err != nil || s == "" {
+		return 0, err
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+//  返回布尔类型的结果, 适用于 exists, hexists, expire 等命令
+func (r *Reply) Bool() (bool, error) {
+	s, err := r.String()
+	return s == "1", err
+}
+
+//  返回列表类型的结果, 适用于 keys, hkeys, qrange 等命令
+func (r *Reply) Strings() ([]string, error) {
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	if len(r.resp) < 1 {
+		return []string{}, nil
+	}
+	return r.resp[1:], nil
+}
+
+//  返回 key-value 类型的结果, 适用于 multi_get, multi_hget, hgetall 等命令
+func (r *Reply) Map() (map[string]string, error) {
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	val := make(map[string]string)
+	size := len(r.resp)
+	for i := 1; i < size && i+1 < size; i += 2 {
+		val[r.resp[i]] = r.resp[i+1]
+	}
+	return val, nil
+}
+
+//  设置指定 key 的值内容, 参见 DbClient.Set. val 只支持基本的类型, 复杂的类型请使用 SetObject
+func (p *Pipeline) Set(key string, val interface{}, ttl ...int64) *Reply {
+	if len(ttl) > 0 {
+		return p.Do("setx", key, val, ttl[0])
+	}
+	return p.Do("set", key, val)
+}
+
+//  用 client 的 Codec 编码 val 后设置到 key, 参见 DbClient.SetObject
+func (p *Pipeline) SetObject(key string, val interface{}, ttl ...int64) *Reply {
+	return p.doObject(val, func(data []byte) []interface{} {
+		if len(ttl) > 0 {
+			return []interface{}{"setx", key, data, ttl[0]}
+		}
+		return []interface{}{"set", key, data}
+	})
+}
+
+//  获取指定key的值内容, 使用 Reply.String 获取结果
+func (p *Pipeline) Get(key string) *Reply {
+	return p.Do("get", key)
+}
+
+//  删除指定 key
+func (p *Pipeline) Del(key string) *Reply {
+	return p.Do("del", key)
+}
+
+//  查询指定 key 是否存在, 使用 Reply.Bool 获取结果
+func (p *Pipeline) Exists(key string) *Reply {
+	return p.Do("exists", key)
+}
+
+//  设置过期, 使用 Reply.Bool 获取结果
+func (p *Pipeline) Expire(key string, ttl int64) *Reply {
+	return p.Do("expire", key, ttl)
+}
+
+//  使key对应的值增加num, 使用 Reply.Int64 获取新值
+func (p *Pipeline) IncR(key string, num int64) *Reply {
+	return p.Do("incr", key, num)
+}
+
+//  设置字符串内指定位置的位值, 使用 Reply.Int64 获取原来的位值
+func (p *Pipeline) SetBit(key string, offset int64, bit byte) *Reply {
+	return p.Do("setbit", key, offset, int(bit))
+}
+
+//  获取字符串内指定位置的位值, 使用 Reply.Int64 获取结果
+func (p *Pipeline) GetBit(key string, offset int64) *Reply {
+	return p.Do("getbit", key, offset)
+}
+
+//  设置 hashmap 中指定 key 对应的值内容. value 只支持基本的类型, 复杂的类型请使用 HSetObject
+func (p *Pipeline) HSet(setName, key string, value interface{}) *Reply {
+	return p.Do("hset", setName, key, value)
+}
+
+//  用 client 的 Codec 编码 val 后设置到 hashmap 中, 参见 DbClient.HSetObject
+func (p *Pipeline) HSetObject(setName, key string, val interface{}) *Reply {
+	return p.doObject(val, func(data []byte) []interface{} {
+		return []interface{}{"hset", setName, key, data}
+	})
+}
+
+//  获取 hashmap 中指定 key 的值内容, 使用 Reply.String 获取结果
+func (p *Pipeline) HGet(setName, key string) *Reply {
+	return p.Do("hget", setName, key)
+}
+
+//  删除 hashmap 中的指定 key
+func (p *Pipeline) HDel(setName, key string) *Reply {
+	return p.Do("hdel", setName, key)
+}
+
+//  设置 hashmap 中指定 key 对应的值增加 num, 使用 Reply.Int64 获取新值
+func (p *Pipeline) HIncR(setName, key string, num int64) *Reply {
+	return p.Do("hincr", setName, key, num)
+}
+
+//  批量设置 hashmap 中的 key-value
+func (p *Pipeline) MultiHSet(setName string, kvs map[string]interface{}) *Reply {
+	args := []interface{}{"multi_hset", setName}
+	for k, v := range kvs {
+		args = append(args, k, v)
+	}
+	return p.Do(args...)
+}
+
+//  批量获取 hashmap 中多个 key 对应的值, 使用 Reply.Map 获取结果
+func (p *Pipeline) MultiHGet(setName string, key ...string) *Reply {
+	return p.Do("multi_hget", setName, key)
+}
+
+//  设置 zset 中指定 key 对应的权重值
+func (p *Pipeline) ZSet(setName, key string, score int64) *Reply {
+	return p.Do("zset", setName, key, score)
+}
+
+//  获取 zset 中指定 key 对应的权重值, 使用 Reply.Int64 获取结果
+func (p *Pipeline) ZGet(setName, key string) *Reply {
+	return p.Do("zget", setName, key)
+}
+
+//  删除 zset 中指定 key
+func (p *Pipeline) ZDel(setName, key string) *Reply {
+	return p.Do("zdel", setName, key)
+}
+
+//  使 zset 中的 key 对应的值增加 num, 使用 Reply.Int64 获取新值
+func (p *Pipeline) ZIncR(setName, key string, num int64) *Reply {
+	return p.Do("zincr", setName, key, num)
+}
+
+//  往队列的尾部添加一个或者多个元素, 使用 Reply.Int64 获取队列长度
+func (p *Pipeline) QPush(name string, value ...interface{}) *Reply {
+	return p.Do(append([]interface{}{"qpush_back", name}, value...)...)
+}
+
+//  用 client 的 Codec 编码后往队列的尾部添加一个或者多个元素, 参见 DbClient.QPushObject
+func (p *Pipeline) QPushObject(name string, value ...interface{}) *Reply {
+	args := []interface{}{"qpush_back", name}
+	for _, v := range value {
+		data, err := p.c.marshal(v)
+		if err != nil {
+			r := p.Do()
+			r.err = err
+			return r
+		}
+		args = append(args, data)
+	}
+	return p.Do(args...)
+}
+
+//  往队列的首部添加一个或者多个元素, 使用 Reply.Int64 获取队列长度
+func (p *Pipeline) QPushFront(name string, value ...interface{}) *Reply {
+	return p.Do(append([]interface{}{"qpush_front", name}, value...)...)
+}
+
+//  从队列首部弹出一个元素, 使用 Reply.String 获取结果
+func (p *Pipeline) QPop(name string) *Reply {
+	return p.Do("qpop_front", name)
+}
+
+//  返回队列的长度, 使用 Reply.Int64 获取结果
+func (p *Pipeline) QSize(name string) *Reply {
+	return p.Do("qsize", name)
+}
@@ -0,0 +1,131 @@
+package gossdb_client
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/houbin910902/gossdb_client/gossdb/ssdb"
+)
+
+func TestPipeline(t *testing.T) {
+	db, _ := newTestClient(t)
+	p := db.Pipeline()
+	set := p.Set("a", "1")
+	incr := p.IncR("a", 2)
+	get := p.Get("a")
+	missing := p.Get("none")
+	bad := p.Do("no_such_command", "a")
+	// 参数编码失败只影响这一条命令
+	badArg := p.Do("set", "b", struct{}{})
+	size := p.QPush("q", "x", "y")
+	if _, err := get.String(); err != errNotExecuted {
+		t.Errorf("String before Exec err = %v", err)
+	}
+	if p.Len() != 7 {
+		t.Errorf("Len = %d", p.Len())
+	}
+
+	replies, err := p.Exec()
+	if err != nil {
+		t.Fatalf("Exec fail err: %v", err)
+	}
+	if len(replies) != 7 || replies[2] != get || p.Len() != 0 {
+		t.Fatalf("replies = %d, Len = %d", len(replies), p.Len())
+	}
+	// 响应的顺序与命令的顺序一致, 单条命令的错误不影响其它命令
+	if err = set.Err(); err != nil {
+		t.Errorf("Set err = %v", err)
+	}
+	if n, err := incr.Int64(); err != nil || n != 3 {
+		t.Errorf("IncR = %d, %v", n, err)
+	}
+	if v, err := get.String(); err != nil || v != "3" {
+		t.Errorf("Get = %q, %v", v, err)
+	}
+	if _, err = missing.String(); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get missing err = %v", err)
+	}
+	if err = bad.Err(); err == nil {
+		t.Error("unknown command should fail")
+	}
+	if err = badArg.Err(); !errors.Is(err, ssdb.ErrBadArguments) {
+		t.Errorf("set with a bad argument err = %v", err)
+	}
+	if ok, _ := db.Exists("b"); ok {
+		t.Error("b should not be set")
+	}
+	if n, err := size.Int64(); err != nil || n != 2 {
+		t.Errorf("QPush = %d, %v", n, err)
+	}
+
+	if replies, err = p.Exec(); err != nil || len(replies) != 0 {
+		t.Errorf("empty Exec = %v, %v", replies, err)
+	}
+}
+
+func TestPipelineBrokenConnection(t *testing.T) {
+	db, s := newTestClient(t)
+	p := db.Pipeline()
+	p.Set("a", "1")
+	get := p.Get("a")
+	s.CloseClientConnections()
+
+	replies, err := p.Exec()
+	if err == nil {
+		t.Fatal("Exec on a closed connection should fail")
+	}
+	for i, r := range replies {
+		if r.Err() != err {
+			t.Errorf("reply %d err = %v, want %v", i, r.Err(), err)
+		}
+	}
+	if _, e := get.String(); e != err {
+		t.Errorf("Get err = %v", e)
+	}
+	if !db.Client.IsBroken() {
+		t.Error("client should be broken")
+	}
+	p.Get("a")
+	if _, err = p.Exec(); !errors.Is(err, ssdb.ErrBroken) {
+		t.Errorf("Exec on a broken client err = %v", err)
+	}
+}
+
+func TestPipelineObject(t *testing.T) {
+	db, _ := newTestClient(t)
+	u := testUser{ID: 1, Name: "bin", Score: 9.5}
+
+	for _, codec := range []Codec{JSONCodec{}, GobCodec{}} {
+		db.SetCodec(codec)
+		p := db.Pipeline()
+		p.SetObject("u", u)
+		p.HSetObject("h", "u", u)
+		p.QPushObject("q", u, u)
+		// 编码失败的命令不发出, 不影响其它命令
+		bad := p.SetObject("bad", make(chan int))
+		get := p.Get("u")
+		hget := p.HGet("h", "u")
+		qpop := p.QPop("q")
+		if _, err := p.Exec(); err != nil {
+			t.Fatalf("%T Exec fail err: %v", codec, err)
+		}
+		if bad.Err() == nil {
+			t.Errorf("%T SetObject(chan) should fail", codec)
+		}
+		for _, r := range []*Reply{get, hget, qpop} {
+			var got testUser
+			if err := r.Object(&got); err != nil || got != u {
+				t.Errorf("%T Object %v = %+v, %v", codec, r.args, got, err)
+			}
+		}
+		// 与 DbClient 的编码方式一致
+		var got testUser
+		if err := db.GetObject("u", &got); err != nil || got != u {
+			t.Errorf("%T GetObject = %+v, %v", codec, got, err)
+		}
+		if ok, _ := db.Exists("bad"); ok {
+			t.Errorf("%T bad key should not be set", codec)
+		}
+		db.QClear("q")
+	}
+}
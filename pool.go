@@ -0,0 +1,425 @@
+package gossdb_client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// 连接池已关闭
+	ErrPoolClosed = errors.New("gossdb_client: pool is closed")
+	// 连接数达到 MaxActive, 并且在 WaitTimeout 内没有等到空闲连接
+	ErrPoolExhausted = errors.New("gossdb_client: connection pool exhausted")
+)
+
+//  连接池配置
+type PoolConfig struct {
+	Ip       string
+	Port     int
+	Password string
+
+	// 创建连接的方法, 为空时使用 NewDbClient(Ip, Port, Password)
+	Dial func() (*DbClient, error)
+
+	// 最少保持的空闲连接数, 创建连接池时预先建立; 借出、放回或丢弃连接后空闲连接不足时在后台补充
+	MinIdle int
+	// 最多保持的空闲连接数, 超出的连接放回时直接关闭. 0 表示不限制
+	MaxIdle int
+	// 同时借出的连接数上限. 0 表示不限制
+	MaxActive int
+	// 连接数达到 MaxActive 时等待空闲连接的最长时间, 0 表示一直等待
+	WaitTimeout time.Duration
+	// 连接空闲超过这个时间将被关闭, 可避免服务端断开空闲连接后返回 EOF. 0 表示不淘汰
+	IdleTimeout time.Duration
+
+	// 借出连接前的检查, 返回错误时关闭该连接并重新获取. idle 为连接已经空闲的时间.
+	// 已经被标记为不可用(Client.IsBroken)的连接总是会被丢弃, 无需在这里检查.
+	TestOnBorrow func(c *DbClient, idle time.Duration) error
+
+	// 连接和 Client() 使用的编解码方式, 参见 DbClient.SetCodec. 为空时使用 DefaultCodec
+	Codec Codec
+}
+
+type idleConn struct {
+	c *DbClient
+	t time.Time
+}
+
+//  goroutine 安全的连接池
+type Pool struct {
+	conf PoolConfig
+
+	mu     sync.Mutex
+	idle   []idleConn // 按放回时间排序, 最近放回的在尾部
+	active int        // 借出和空闲的连接总数
+	// 已经借出、尚未放回的连接, 重复的 Put 不会再次归还令牌
+	lent   map[*DbClient]struct{}
+	closed bool
+	// 正在补充空闲连接, 避免同时补充超过 MinIdle
+	filling bool
+	// MaxActive > 0 时, 每个借出的连接占用一个令牌
+	sem  chan struct{}
+	stop chan struct{}
+}
+
+//  连接池的状态
+type PoolStats struct {
+	Active int // 借出和空闲的连接总数
+	Idle   int // 空闲的连接数
+}
+
+//  创建一个连接池, 并预先建立 MinIdle 个连接
+//  conf 连接池配置
+//  返回 连接池
+//  返回 err，可能的错误，操作成功返回 nil
+func NewPool(conf *PoolConfig) (*Pool, error) {
+	p := &Pool{conf: *conf, lent: make(map[*DbClient]struct{}), stop: make(chan struct{})}
+	if p.conf.Dial == nil {
+		ip, port, password := conf.Ip, conf.Port, conf.Password
+		p.conf.Dial = func() (*DbClient, error) { return NewDbClient(ip, port, password) }
+	}
+	if codec := p.conf.Codec; codec != nil {
+		dial := p.conf.Dial
+		p.conf.Dial = func() (*DbClient, error) {
+			c, err := dial()
+			if err == nil {
+				c.SetCodec(codec)
+			}
+			return c, err
+		}
+	}
+	if p.conf.MaxActive > 0 {
+		p.sem = make(chan struct{}, p.conf.MaxActive)
+	}
+	if p.conf.MaxIdle > 0 && p.conf.MinIdle > p.conf.MaxIdle {
+		p.conf.MinIdle = p.conf.MaxIdle
+	}
+
+	if err := p.fill(); err != nil {
+		p.Close()
+		return nil, err
+	}
+	if p.conf.IdleTimeout > 0 {
+		go p.evictLoop()
+	}
+	return p, nil
+}
+
+//  从连接池获取一个连接, 使用完后必须调用 Put 放回
+//  返回 一个 DbClient
+//  返回 err，可能的错误，操作成功返回 nil
+func (p *Pool) Get() (*DbClient, error) {
+	return p.GetContext(context.Background())
+}
+
+//  从连接池获取一个连接, 等待空闲连接时 ctx 取消或超时将返回 ctx.Err()
+//  返回 一个 DbClient
+//  返回 err，可能的错误，操作成功返回 nil
+func (p *Pool) GetContext(ctx context.Context) (*DbClient, error) {
+	if err := p.acquire(ctx); err != nil {
+		return nil, err
+	}
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			p.release()
+			return nil, ErrPoolClosed
+		}
+		n := len(p.idle)
+		if n == 0 {
+			p.active++
+			p.mu.Unlock()
+			break
+		}
+		ic := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+
+		if p.usable(ic) {
+			p.lend(ic.c)
+			p.fillAsync()
+			return ic.c, nil
+		}
+		p.closeConn(ic.c)
+	}
+
+	c, err := p.conf.Dial()
+	if err != nil {
+		p.mu.Lock()
+		p.active--
+		p.mu.Unlock()
+		p.release()
+		return nil, err
+	}
+	p.lend(c)
+	return c, nil
+}
+
+//  把连接放回连接池. 已经不可用的连接会被直接关闭.
+//  已经放回的连接和不是从这个连接池借出的连接不做任何处理
+//  c 通过 Get 获取的连接
+//  返回 err，关闭连接时可能的错误
+func (p *Pool) Put(c *DbClient) error {
+	if c == nil {
+		return nil
+	}
+	p.mu.Lock()
+	if _, ok := p.lent[c]; !ok {
+		p.mu.Unlock()
+		return nil
+	}
+	delete(p.lent, c)
+	p.mu.Unlock()
+	if c.Client == nil || c.Client.IsBroken() {
+		return p.discard(c)
+	}
+	c.ctx = nil
+
+	p.mu.Lock()
+	if p.closed || (p.conf.MaxIdle > 0 && len(p.idle) >= p.conf.MaxIdle) {
+		p.mu.Unlock()
+		return p.discard(c)
+	}
+	p.idle = append(p.idle, idleConn{c: c, t: time.Now()})
+	p.mu.Unlock()
+	p.release()
+	p.fillAsync()
+	return nil
+}
+
+//  关闭连接池和所有空闲连接. 已经借出的连接在放回时关闭
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.active -= len(idle)
+	p.mu.Unlock()
+	close(p.stop)
+
+	var err error
+	for _, ic := range idle {
+		if e := ic.c.CloseDbClient(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+//  返回连接池当前的状态
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return PoolStats{Active: p.active, Idle: len(p.idle)}
+}
+
+//  返回一个使用连接池的 client, 每条命令执行前从连接池借出连接, 执行后放回.
+//  可以被多个 goroutine 同时使用.
+func (p *Pool) Client() *PooledClient {
+	return &PooledClient{DbClient{ex: p, codec: p.conf.Codec}}
+}
+
+//  使用连接池执行命令的 client, 拥有 DbClient 的全部方法.
+//  每条命令单独借出和放回连接, 因此连续的多条命令可能使用不同的连接.
+type PooledClient struct {
+	DbClient
+}
+
+//  返回一个绑定了 ctx 的 PooledClient 副本, 参见 DbClient.WithContext
+func (c *PooledClient) WithContext(ctx context.Context) *PooledClient {
+	return &PooledClient{*c.DbClient.WithContext(ctx)}
+}
+
+func (p *Pool) exec(ctx context.Context, args []interface{}) ([]string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	c, err := p.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.WithContext(ctx).do(args...)
+	p.Put(c)
+	return resp, err
+}
+
+func (p *Pool) execPipeline(ctx context.Context, cmds [][]interface{}) ([][]string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	c, err := p.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resps, err := c.WithContext(ctx).doPipeline(cmds)
+	p.Put(c)
+	return resps, err
+}
+
+//  获取一个令牌, 没有设置 MaxActive 时直接返回
+func (p *Pool) acquire(ctx context.Context) error {
+	if p.sem == nil {
+		return nil
+	}
+	select {
+	case p.sem <- struct{}{}:
+		return nil
+	default:
+	}
+
+	var timeout <-chan time.Time
+	if p.conf.WaitTimeout > 0 {
+		t := time.NewTimer(p.conf.WaitTimeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+	select {
+	case p.sem <- struct{}{}:
+		return nil
+	case <-timeout:
+		return ErrPoolExhausted
+	case <-p.stop:
+		return ErrPoolClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//  记录一个借出的连接, 由 Put 放回时删除
+func (p *Pool) lend(c *DbClient) {
+	p.mu.Lock()
+	p.lent[c] = struct{}{}
+	p.mu.Unlock()
+}
+
+func (p *Pool) release() {
+	if p.sem != nil {
+		<-p.sem
+	}
+}
+
+func (p *Pool) usable(ic idleConn) bool {
+	if ic.c.Client == nil || ic.c.Client.IsBroken() {
+		return false
+	}
+	idle := time.Since(ic.t)
+	if p.conf.IdleTimeout > 0 && idle > p.conf.IdleTimeout {
+		return false
+	}
+	if p.conf.TestOnBorrow != nil && p.conf.TestOnBorrow(ic.c, idle) != nil {
+		return false
+	}
+	return true
+}
+
+//  关闭一个借出的连接, 并归还它的令牌
+func (p *Pool) discard(c *DbClient) error {
+	p.release()
+	err := p.closeConn(c)
+	p.fillAsync()
+	return err
+}
+
+func (p *Pool) closeConn(c *DbClient) error {
+	p.mu.Lock()
+	p.active--
+	p.mu.Unlock()
+	return c.CloseDbClient()
+}
+
+//  补充空闲连接到 MinIdle 个. 同一时间只有一个 fill 在建立连接, 其余的直接返回
+func (p *Pool) fill() error {
+	p.mu.Lock()
+	if p.filling {
+		p.mu.Unlock()
+		return nil
+	}
+	p.filling = true
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.filling = false
+		p.mu.Unlock()
+	}()
+
+	for {
+		p.mu.Lock()
+		need := !p.closed && len(p.idle) < p.conf.MinIdle
+		p.mu.Unlock()
+		if !need {
+			return nil
+		}
+
+		c, err := p.conf.Dial()
+		if err != nil {
+			return err
+		}
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return c.CloseDbClient()
+		}
+		p.active++
+		p.idle = append(p.idle, idleConn{c: c, t: time.Now()})
+		p.mu.Unlock()
+	}
+}
+
+//  空闲连接不足 MinIdle 时在后台补充, 不阻塞调用方
+func (p *Pool) fillAsync() {
+	if p.conf.MinIdle <= 0 {
+		return
+	}
+	p.mu.Lock()
+	need := !p.closed && !p.filling && len(p.idle) < p.conf.MinIdle
+	p.mu.Unlock()
+	if need {
+		go p.fill()
+	}
+}
+
+func (p *Pool) evictLoop() {
+	interval := p.conf.IdleTimeout / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-t.C:
+			p.evict()
+			p.fill()
+		}
+	}
+}
+
+//  关闭空闲超过 IdleTimeout 的连接, 不足 MinIdle 的部分由 fill 重新建立
+func (p *Pool) evict() {
+	deadline := time.Now().Add(-p.conf.IdleTimeout)
+	var stale []*DbClient
+
+	p.mu.Lock()
+	// idle 按放回时间排序, 最旧的在头部
+	n := 0
+	for n < len(p.idle) && p.idle[n].t.Before(deadline) {
+		stale = append(stale, p.idle[n].c)
+		n++
+	}
+	p.idle = append(p.idle[:0], p.idle[n:]...)
+	p.active -= n
+	p.mu.Unlock()
+
+	for _, c := range stale {
+		c.CloseDbClient()
+	}
+}
@@ -0,0 +1,155 @@
+package gossdb_client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestPool(t *testing.T, conf PoolConfig) *Pool {
+	p, err := NewPool(&conf)
+	if err != nil {
+		t.Fatalf("NewPool fail err: %s", err.Error())
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+//  等待连接池达到期望的状态, 后台补充连接是异步的
+func waitStats(t *testing.T, p *Pool, want PoolStats) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for p.Stats() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("Stats = %+v, want %+v", p.Stats(), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPoolMaxActive(t *testing.T) {
+	_, conf := newTestNode(t)
+	conf.MaxActive = 1
+	conf.WaitTimeout = 50 * time.Millisecond
+	p := newTestPool(t, conf)
+
+	c, err := p.Get()
+	if err != nil {
+		t.Fatalf("Get fail err: %v", err)
+	}
+	start := time.Now()
+	if _, err = p.Get(); !errors.Is(err, ErrPoolExhausted) {
+		t.Fatalf("Get err = %v, want ErrPoolExhausted", err)
+	}
+	if d := time.Since(start); d < 40*time.Millisecond {
+		t.Errorf("Get returned after %v, want to wait WaitTimeout", d)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = p.GetContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetContext err = %v, want context.Canceled", err)
+	}
+
+	// 放回后等待的 Get 拿到同一个连接
+	time.AfterFunc(20*time.Millisecond, func() { p.Put(c) })
+	c2, err := p.GetContext(context.Background())
+	if err != nil || c2 != c {
+		t.Fatalf("Get after Put = %p, %v, want %p", c2, err, c)
+	}
+	p.Put(c2)
+	if s := p.Stats(); s != (PoolStats{Active: 1, Idle: 1}) {
+		t.Errorf("Stats = %+v", s)
+	}
+}
+
+func TestPoolDoublePut(t *testing.T) {
+	_, conf := newTestNode(t)
+	conf.MaxActive = 2
+	conf.WaitTimeout = 50 * time.Millisecond
+	p := newTestPool(t, conf)
+
+	c1, _ := p.Get()
+	c2, _ := p.Get()
+	p.Put(c1)
+	// 重复放回不做任何处理, 不会多归还令牌, 也不会重复加入空闲连接
+	p.Put(c1)
+	if s := p.Stats(); s != (PoolStats{Active: 2, Idle: 1}) {
+		t.Fatalf("Stats after double Put = %+v", s)
+	}
+	c3, err := p.Get()
+	if err != nil || c3 != c1 {
+		t.Fatalf("Get = %p, %v, want %p", c3, err, c1)
+	}
+	if _, err = p.Get(); !errors.Is(err, ErrPoolExhausted) {
+		t.Fatalf("Get err = %v, want ErrPoolExhausted", err)
+	}
+	p.Put(c2)
+	p.Put(c3)
+	p.Put(c2)
+	if s := p.Stats(); s != (PoolStats{Active: 2, Idle: 2}) {
+		t.Errorf("Stats = %+v", s)
+	}
+}
+
+func TestPoolIdleTimeout(t *testing.T) {
+	_, conf := newTestNode(t)
+	conf.IdleTimeout = 50 * time.Millisecond
+	p := newTestPool(t, conf)
+
+	c, _ := p.Get()
+	p.Put(c)
+	time.Sleep(80 * time.Millisecond)
+	// 借出时发现已经超时, 关闭后重新建立
+	c2, err := p.Get()
+	if err != nil || c2 == c {
+		t.Fatalf("Get = %p, %v, want a new connection", c2, err)
+	}
+	p.Put(c2)
+	time.Sleep(80 * time.Millisecond)
+	p.evict()
+	if s := p.Stats(); s != (PoolStats{}) {
+		t.Errorf("Stats after evict = %+v", s)
+	}
+}
+
+func TestPoolMinIdle(t *testing.T) {
+	_, conf := newTestNode(t)
+	conf.MinIdle = 2
+	p := newTestPool(t, conf)
+	if s := p.Stats(); s != (PoolStats{Active: 2, Idle: 2}) {
+		t.Fatalf("Stats after NewPool = %+v", s)
+	}
+
+	// 没有设置 IdleTimeout 时借出后同样补充
+	c1, _ := p.Get()
+	c2, _ := p.Get()
+	waitStats(t, p, PoolStats{Active: 4, Idle: 2})
+	p.Put(c1)
+	p.Put(c2)
+	waitStats(t, p, PoolStats{Active: 4, Idle: 4})
+}
+
+func TestPoolDiscardBroken(t *testing.T) {
+	s, conf := newTestNode(t)
+	conf.MinIdle = 1
+	p := newTestPool(t, conf)
+
+	c, _ := p.Get()
+	waitStats(t, p, PoolStats{Active: 2, Idle: 1})
+	s.CloseClientConnections()
+	if _, err := c.Get("a"); err == nil || !c.Client.IsBroken() {
+		t.Fatalf("Get on a closed connection err = %v, broken = %v", err, c.Client.IsBroken())
+	}
+	p.Put(c)
+	if s := p.Stats(); s.Active != 1 || s.Idle != 1 {
+		t.Fatalf("Stats after Put = %+v", s)
+	}
+
+	// 空闲的连接同样已经断开, 第一条命令在坏连接上失败, 之后的命令使用新的连接
+	db := p.Client()
+	db.Get("a")
+	if err := db.Set("a", "1"); err != nil {
+		t.Fatalf("Set after restart fail err: %v", err)
+	}
+}
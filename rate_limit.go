@@ -0,0 +1,430 @@
+package gossdb_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/houbin910902/gossdb_client/gossdb/ssdb"
+)
+
+//  一次请求的数量超过了限流器的容量, 永远不会被允许
+var ErrRateLimitExceedsCapacity = errors.New("gossdb_client: rate limit request exceeds capacity")
+
+//  检查限流器的参数, limit 必须大于 0, window 至少为 1 毫秒
+func checkLimiterArgs(limit int64, window time.Duration) error {
+	if limit <= 0 {
+		return fmt.Errorf("%w: rate limit %d must be positive", ssdb.ErrBadArguments, limit)
+	}
+	if window < time.Millisecond {
+		return fmt.Errorf("%w: rate limit window %v must be at least 1ms", ssdb.ErrBadArguments, window)
+	}
+	return nil
+}
+
+//  限流的结果
+type RateLimitResult struct {
+	// 是否允许
+	Allowed bool
+	// 允许之后当前剩余的配额, 不允许时为当前可用的配额
+	Remaining int64
+	// 不允许时为建议的重试等待时间; Reserve 成功时为执行之前需要等待的时间
+	RetryAfter time.Duration
+}
+
+//  基于 ssdb 的限流器, 可以在多个进程之间共享配额.
+//  key 为限流的对象, 例如用户 id 或者接口名, 每个 key 的配额互相独立
+type RateLimiter interface {
+	// 立即消耗 n 个配额, 配额不足时不消耗, 返回的 RetryAfter 为建议的等待时间
+	Allow(ctx context.Context, key string, n int64) (*RateLimitResult, error)
+	// 预约 n 个配额, 最多等待 maxWait: 配额在 maxWait 之内可用时消耗配额, 调用方需要等待 RetryAfter 后再执行;
+	// 否则不消耗配额. maxWait 为 0 时等同于 Allow
+	Reserve(ctx context.Context, key string, n int64, maxWait time.Duration) (*RateLimitResult, error)
+}
+
+//  固定窗口限流器, 每个窗口内最多允许 limit 次.
+//  每个 key 的每个窗口使用一个计数器 name:key:窗口编号, 用 incr 计数, 用 expire 在窗口结束后自动删除.
+//  窗口边界附近的短时间内最多可能允许 2*limit 次
+type FixedWindowLimiter struct {
+	c      *DbClient
+	name   string
+	limit  int64
+	window int64
+}
+
+//  创建固定窗口限流器, 不会访问服务端. c 应当可以被多个 goroutine 同时使用, 例如 Pool.Client()
+//  name 限流器的名字, 用作计数器名字的前缀
+//  limit 每个窗口内最多允许的次数
+//  window 窗口的长度, 精确到毫秒
+//  返回 err，limit 小于等于 0 或者 window 小于 1 毫秒时返回 ssdb.ErrBadArguments
+func (c *DbClient) NewFixedWindowLimiter(name string, limit int64, window time.Duration) (*FixedWindowLimiter, error) {
+	if err := checkLimiterArgs(limit, window); err != nil {
+		return nil, err
+	}
+	return &FixedWindowLimiter{c: c, name: name, limit: limit, window: window.Milliseconds()}, nil
+}
+
+//  参见 RateLimiter.Allow
+func (l *FixedWindowLimiter) Allow(ctx context.Context, key string, n int64) (*RateLimitResult, error) {
+	return l.Reserve(ctx, key, n, 0)
+}
+
+//  参见 RateLimiter.Reserve. 从当前窗口开始依次尝试之后的窗口, 直到找到有足够配额的窗口或者超过 maxWait
+func (l *FixedWindowLimiter) Reserve(ctx context.Context, key string, n int64, maxWait time.Duration) (*RateLimitResult, error) {
+	if n > l.limit {
+		return nil, ErrRateLimitExceedsCapacity
+	}
+	c := l.c.WithContext(ctx)
+	now := time.Now().UnixMilli()
+	for w := now / l.window; ; w++ {
+		delay := time.Duration(w*l.window-now) * time.Millisecond
+		if delay < 0 {
+			delay = 0
+		}
+		if delay > maxWait {
+			return &RateLimitResult{RetryAfter: delay}, nil
+		}
+		name := l.name + ":" + key + ":" + strconv.FormatInt(w, 10)
+		// 在窗口结束后 1 秒过期
+		ttl := ((w+1)*l.window-now+999)/1000 + 1
+		p := c.Pipeline()
+		r := p.IncR(name, n)
+		p.Expire(name, ttl)
+		if _, err := p.Exec(); err != nil {
+			return nil, err
+		}
+		count, err := r.Int64()
+		if err != nil {
+			return nil, err
+		}
+		if count <= l.limit {
+			return &RateLimitResult{Allowed: true, Remaining: l.limit - count, RetryAfter: delay}, nil
+		}
+		// 退回多加的计数, 让更小的请求仍然可以使用剩余的配额
+		if _, err = c.IncR(name, -n); err != nil {
+			return nil, err
+		}
+	}
+}
+
+//  滑动窗口限流器, 任意 window 长度的时间内最多允许 limit 次.
+//  每个 key 使用一个 zset name:key 记录每次请求, 权重为请求的时间(unix 毫秒). 先写入再检查数量,
+//  并发的请求超过配额时都会撤销, 因此不会多于 limit 次, 但竞争激烈时可能少于 limit 次.
+//  ssdb 的 expire 只作用于 key-value, zset 在下一次请求时删除过期的记录, 不再使用的 key 用 Cleanup 删除
+type SlidingWindowLimiter struct {
+	c      *DbClient
+	name   string
+	limit  int64
+	window int64
+}
+
+//  创建滑动窗口限流器, 不会访问服务端. c 应当可以被多个 goroutine 同时使用, 例如 Pool.Client()
+//  name 限流器的名字, 用作 zset 名字的前缀
+//  limit 每个窗口内最多允许的次数
+//  window 窗口的长度, 精确到毫秒
+//  返回 err，limit 小于等于 0 或者 window 小于 1 毫秒时返回 ssdb.ErrBadArguments
+func (c *DbClient) NewSlidingWindowLimiter(name string, limit int64, window time.Duration) (*SlidingWindowLimiter, error) {
+	if err := checkLimiterArgs(limit, window); err != nil {
+		return nil, err
+	}
+	return &SlidingWindowLimiter{c: c, name: name, limit: limit, window: window.Milliseconds()}, nil
+}
+
+//  参见 RateLimiter.Allow
+func (l *SlidingWindowLimiter) Allow(ctx context.Context, key string, n int64) (*RateLimitResult, error) {
+	return l.Reserve(ctx, key, n, 0)
+}
+
+//  参见 RateLimiter.Reserve. 预约的请求以执行的时间写入 zset
+func (l *SlidingWindowLimiter) Reserve(ctx context.Context, key string, n int64, maxWait time.Duration) (*RateLimitResult, error) {
+	if n > l.limit {
+		return nil, ErrRateLimitExceedsCapacity
+	}
+	c := l.c.WithContext(ctx)
+	name := l.name + ":" + key
+	for attempt := 0; attempt < 3; attempt++ {
+		now := time.Now().UnixMilli()
+		p := c.Pipeline()
+		p.Do("zremrangebyscore", name, "", now-l.window)
+		size := p.Do("zsize", name)
+		if _, err := p.Exec(); err != nil {
+			return nil, err
+		}
+		m, err := size.Int64()
+		if err != nil {
+			return nil, err
+		}
+		at, err := l.reserveAt(c, name, now, m, n)
+		if err != nil {
+			return nil, err
+		}
+		delay := time.Duration(at-now) * time.Millisecond
+		if delay > maxWait {
+			return &RateLimitResult{Remaining: max(0, l.limit-m), RetryAfter: delay}, nil
+		}
+
+		token := randomToken()
+		add := []interface{}{"multi_zset", name}
+		del := []interface{}{"multi_zdel", name}
+		for i := int64(0); i < n; i++ {
+			member := token + ":" + strconv.FormatInt(i, 10)
+			add = append(add, member, at)
+			del = append(del, member)
+		}
+		p.Do(add...)
+		count := p.Do("zcount", name, at-l.window+1, "")
+		if _, err = p.Exec(); err != nil {
+			return nil, err
+		}
+		total, err := count.Int64()
+		if err != nil {
+			return nil, err
+		}
+		if total <= l.limit {
+			return &RateLimitResult{Allowed: true, Remaining: l.limit - total, RetryAfter: delay}, nil
+		}
+		// 与其它请求竞争失败, 撤销后重试
+		if _, err = c.Do(del...); err != nil {
+			return nil, err
+		}
+	}
+	return &RateLimitResult{RetryAfter: time.Duration(l.window/l.limit) * time.Millisecond}, nil
+}
+
+//  计算 n 个请求最早可以执行的时间: zset 中 m 条记录按时间排序, 需要等到最早的 m+n-limit 条记录移出窗口.
+//  已经预约的记录可能在 now 之后, 新的请求不早于其中最晚的一条
+func (l *SlidingWindowLimiter) reserveAt(c *DbClient, name string, now, m, n int64) (int64, error) {
+	if m+n <= l.limit {
+		return now, nil
+	}
+	p := c.Pipeline()
+	oldest := p.Do("zrange", name, m+n-l.limit-1, 1)
+	latest := p.Do("zrrange", name, 0, 1)
+	if _, err := p.Exec(); err != nil {
+		return 0, err
+	}
+	at := now
+	for _, r := range []*Reply{oldest, latest} {
+		kv, err := r.Strings()
+		if err != nil {
+			return 0, err
+		}
+		if len(kv) < 2 {
+			continue
+		}
+		t, err := strconv.ParseInt(kv[1], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		if r == oldest {
+			t += l.window
+		}
+		at = max(at, t)
+	}
+	return at, nil
+}
+
+//  删除所有 key 中已经移出窗口的记录, 没有记录的 zset 随之删除
+//  返回 removed, 删除的 zset 数
+//  返回 err，可能的错误，操作成功返回 nil
+func (l *SlidingWindowLimiter) Cleanup(ctx context.Context) (removed int, err error) {
+	c := l.c.WithContext(ctx)
+	return cleanupNames(c.ZListIter(limiterNames(l.name)), l.name, func(names []string) (int, error) {
+		now := time.Now().UnixMilli()
+		p := c.Pipeline()
+		sizes := make([]*Reply, len(names))
+		for i, name := range names {
+			p.Do("zremrangebyscore", name, "", now-l.window)
+			sizes[i] = p.Do("zsize", name)
+		}
+		if _, err := p.Exec(); err != nil {
+			return 0, err
+		}
+		n := 0
+		for _, r := range sizes {
+			if size, err := r.Int64(); err == nil && size == 0 {
+				n++
+			}
+		}
+		return n, nil
+	})
+}
+
+//  令牌桶限流器, 令牌以每 window 补充 limit 个的速度补充, 桶中最多 burst 个令牌.
+//  每个 key 使用一个 hashmap name:key, tokens 字段为令牌数(千分之一个令牌为单位), ts 字段为上一次补充的时间(unix 毫秒).
+//  所有修改都使用 hincr: 补充令牌前用 hincr 把 ts 推进到当前时间, 只有结果恰好等于当前时间的请求负责补充这段时间的令牌,
+//  其余的请求撤销对 ts 的修改, 因此并发的请求不会重复补充.
+//  ssdb 的 expire 只作用于 key-value, 令牌已满的 key 用 Cleanup 删除
+type TokenBucketLimiter struct {
+	c      *DbClient
+	name   string
+	limit  int64
+	window int64
+	burst  int64
+}
+
+//  令牌数的单位, 千分之一个令牌
+const tokenScale = 1000
+
+//  创建令牌桶限流器, 不会访问服务端. c 应当可以被多个 goroutine 同时使用, 例如 Pool.Client()
+//  name 限流器的名字, 用作 hashmap 名字的前缀
+//  limit, window 每 window 补充 limit 个令牌, window 精确到毫秒
+//  burst 桶的容量, 即最多允许的突发请求数, 小于等于 0 时为 limit
+//  返回 err，limit 小于等于 0 或者 window 小于 1 毫秒时返回 ssdb.ErrBadArguments
+func (c *DbClient) NewTokenBucketLimiter(name string, limit int64, window time.Duration, burst int64) (*TokenBucketLimiter, error) {
+	if err := checkLimiterArgs(limit, window); err != nil {
+		return nil, err
+	}
+	if burst <= 0 {
+		burst = limit
+	}
+	return &TokenBucketLimiter{c: c, name: name, limit: limit, window: window.Milliseconds(), burst: burst}, nil
+}
+
+//  参见 RateLimiter.Allow
+func (l *TokenBucketLimiter) Allow(ctx context.Context, key string, n int64) (*RateLimitResult, error) {
+	return l.Reserve(ctx, key, n, 0)
+}
+
+//  参见 RateLimiter.Reserve. 预约时令牌数可以为负, 之后补充的令牌先偿还预约的部分
+func (l *TokenBucketLimiter) Reserve(ctx context.Context, key string, n int64, maxWait time.Duration) (*RateLimitResult, error) {
+	if n > l.burst {
+		return nil, ErrRateLimitExceedsCapacity
+	}
+	c := l.c.WithContext(ctx)
+	name := l.name + ":" + key
+	state, err := c.MultiHGet(name, "tokens", "ts")
+	if err != nil {
+		return nil, err
+	}
+	tokens, _ := strconv.ParseInt(state["tokens"], 10, 64)
+	last, _ := strconv.ParseInt(state["ts"], 10, 64)
+
+	now := time.Now().UnixMilli()
+	full := l.burst * tokenScale
+	var refill int64
+	if elapsed := now - last; elapsed > 0 {
+		v, err := c.HIncR(name, "ts", elapsed)
+		if err != nil {
+			return nil, err
+		}
+		if v == now {
+			// 读取之后其它请求只会消耗令牌, 按读到的令牌数补满不会超过容量
+			refill = max(0, min(l.refill(elapsed), full-tokens))
+		} else if _, err = c.HIncR(name, "ts", -elapsed); err != nil {
+			// 读取之后 ts 被其它请求修改, 由它们负责补充
+			return nil, err
+		}
+	}
+
+	cost := n * tokenScale
+	if tokens, err = c.HIncR(name, "tokens", refill-cost); err != nil {
+		return nil, err
+	}
+	// 读取时恰好有请求在撤销消耗, 补充后可能略微超过容量
+	if before := tokens + cost; refill > 0 && before > full {
+		if tokens, err = c.HIncR(name, "tokens", full-before); err != nil {
+			return nil, err
+		}
+	}
+	if tokens >= 0 {
+		return &RateLimitResult{Allowed: true, Remaining: tokens / tokenScale}, nil
+	}
+
+	wait := l.wait(-tokens)
+	if wait <= maxWait {
+		return &RateLimitResult{Allowed: true, RetryAfter: wait}, nil
+	}
+	if tokens, err = c.HIncR(name, "tokens", cost); err != nil {
+		return nil, err
+	}
+	return &RateLimitResult{Remaining: max(0, tokens/tokenScale), RetryAfter: wait}, nil
+}
+
+//  elapsed 毫秒内补充的令牌数. 超过容量的部分由调用方截断, 这里只防止溢出
+func (l *TokenBucketLimiter) refill(elapsed int64) int64 {
+	elapsed = min(elapsed, math.MaxInt32*l.window/(l.limit*tokenScale))
+	return elapsed * l.limit * tokenScale / l.window
+}
+
+//  补充 tokens 个令牌(千分之一个令牌为单位)需要的时间
+func (l *TokenBucketLimiter) wait(tokens int64) time.Duration {
+	ms := (tokens*l.window + l.limit*tokenScale - 1) / (l.limit * tokenScale)
+	return time.Duration(ms) * time.Millisecond
+}
+
+//  删除所有令牌已经补满的 key. 与请求同时进行时, 刚刚消耗的令牌可能随 key 一起被删除
+//  返回 removed, 删除的 key 数
+//  返回 err，可能的错误，操作成功返回 nil
+func (l *TokenBucketLimiter) Cleanup(ctx context.Context) (removed int, err error) {
+	c := l.c.WithContext(ctx)
+	return cleanupNames(c.HListIter(limiterNames(l.name)), l.name, func(names []string) (int, error) {
+		p := c.Pipeline()
+		states := make([]*Reply, len(names))
+		for i, name := range names {
+			states[i] = p.Do("multi_hget", name, "tokens", "ts")
+		}
+		if _, err := p.Exec(); err != nil {
+			return 0, err
+		}
+		now := time.Now().UnixMilli()
+		for i, name := range names {
+			m, err := states[i].Map()
+			if err != nil {
+				return 0, err
+			}
+			tokens, _ := strconv.ParseInt(m["tokens"], 10, 64)
+			ts, _ := strconv.ParseInt(m["ts"], 10, 64)
+			if tokens+l.refill(max(0, now-ts)) >= l.burst*tokenScale {
+				p.Do("hclear", name)
+			}
+		}
+		n := p.Len()
+		if n == 0 {
+			return 0, nil
+		}
+		if _, err := p.Exec(); err != nil {
+			return 0, err
+		}
+		return n, nil
+	})
+}
+
+//  限流器所有 key 的名字所在的区间
+func limiterNames(name string) *ScanOptions {
+	return &ScanOptions{Start: name + ":", End: name + ";", PageSize: DefaultScanPageSize}
+}
+
+//  遍历限流器的 key, 每页调用一次 clean
+func cleanupNames(it *ScanIterator, prefix string, clean func(names []string) (int, error)) (int, error) {
+	defer it.Close()
+	removed := 0
+	names := make([]string, 0, DefaultScanPageSize)
+	flush := func() error {
+		if len(names) == 0 {
+			return nil
+		}
+		n, err := clean(names)
+		removed += n
+		names = names[:0]
+		return err
+	}
+	for it.Next() {
+		if !strings.HasPrefix(it.Key(), prefix+":") {
+			continue
+		}
+		if names = append(names, it.Key()); len(names) == cap(names) {
+			if err := flush(); err != nil {
+				return removed, err
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		return removed, err
+	}
+	return removed, flush()
+}
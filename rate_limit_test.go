@@ -0,0 +1,197 @@
+package gossdb_client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/houbin910902/gossdb_client/gossdb/ssdb"
+)
+
+//  创建三种限流器, 窗口为 1 小时
+func newTestLimiters(t *testing.T, db *PooledClient, limit int64) map[string]RateLimiter {
+	fw, err := db.NewFixedWindowLimiter("fw", limit, time.Hour)
+	if err != nil {
+		t.Fatalf("NewFixedWindowLimiter fail err: %v", err)
+	}
+	sw, err := db.NewSlidingWindowLimiter("sw", limit, time.Hour)
+	if err != nil {
+		t.Fatalf("NewSlidingWindowLimiter fail err: %v", err)
+	}
+	tb, err := db.NewTokenBucketLimiter("tb", limit, time.Hour, 0)
+	if err != nil {
+		t.Fatalf("NewTokenBucketLimiter fail err: %v", err)
+	}
+	return map[string]RateLimiter{"fixed": fw, "sliding": sw, "bucket": tb}
+}
+
+func TestRateLimiters(t *testing.T) {
+	db := newTestPoolClient(t)
+	ctx := context.Background()
+	limiters := newTestLimiters(t, db, 3)
+	for kind, l := range limiters {
+		for i := int64(2); i >= 0; i-- {
+			r, err := l.Allow(ctx, "user:1", 1)
+			if err != nil || !r.Allowed || r.Remaining != i || r.RetryAfter != 0 {
+				t.Fatalf("%s: Allow = %+v, %v, want remaining %d", kind, r, err, i)
+			}
+		}
+		r, err := l.Allow(ctx, "user:1", 1)
+		if err != nil || r.Allowed || r.RetryAfter <= 0 || r.RetryAfter > time.Hour {
+			t.Errorf("%s: Allow over limit = %+v, %v", kind, r, err)
+		}
+		// 不同的 key 互不影响
+		if r, _ = l.Allow(ctx, "user:2", 3); !r.Allowed {
+			t.Errorf("%s: Allow other key = %+v", kind, r)
+		}
+		if _, err = l.Allow(ctx, "user:3", 4); !errors.Is(err, ErrRateLimitExceedsCapacity) {
+			t.Errorf("%s: Allow over capacity err = %v", kind, err)
+		}
+
+		if r, _ = l.Reserve(ctx, "user:1", 1, time.Minute); r.Allowed {
+			t.Errorf("%s: Reserve beyond maxWait = %+v", kind, r)
+		}
+		r, err = l.Reserve(ctx, "user:1", 1, 2*time.Hour)
+		if err != nil || !r.Allowed || r.RetryAfter <= 0 || r.RetryAfter > time.Hour {
+			t.Errorf("%s: Reserve = %+v, %v", kind, r, err)
+		}
+	}
+}
+
+func TestNewRateLimiterInvalid(t *testing.T) {
+	db := newTestPoolClient(t)
+	for _, c := range []struct {
+		limit  int64
+		window time.Duration
+	}{
+		{0, time.Second},
+		{-1, time.Second},
+		{10, 0},
+		{10, 500 * time.Microsecond},
+	} {
+		if _, err := db.NewFixedWindowLimiter("fw", c.limit, c.window); !errors.Is(err, ssdb.ErrBadArguments) {
+			t.Errorf("NewFixedWindowLimiter(%d, %v) err = %v, want ErrBadArguments", c.limit, c.window, err)
+		}
+		if _, err := db.NewSlidingWindowLimiter("sw", c.limit, c.window); !errors.Is(err, ssdb.ErrBadArguments) {
+			t.Errorf("NewSlidingWindowLimiter(%d, %v) err = %v, want ErrBadArguments", c.limit, c.window, err)
+		}
+		if _, err := db.NewTokenBucketLimiter("tb", c.limit, c.window, 0); !errors.Is(err, ssdb.ErrBadArguments) {
+			t.Errorf("NewTokenBucketLimiter(%d, %v) err = %v, want ErrBadArguments", c.limit, c.window, err)
+		}
+	}
+}
+
+func TestFixedWindowLimiterExpire(t *testing.T) {
+	db := newTestPoolClient(t)
+	l, err := db.NewFixedWindowLimiter("fw", 5, 10*time.Second)
+	if err != nil {
+		t.Fatalf("NewFixedWindowLimiter fail err: %v", err)
+	}
+	l.Allow(context.Background(), "k", 1)
+	keys, _ := db.Keys("fw:k:", "fw:k;", 10)
+	if len(keys) != 1 {
+		t.Fatalf("keys = %v", keys)
+	}
+	if ttl, _ := db.Ttl(keys[0]); ttl <= 0 || ttl > 11 {
+		t.Errorf("ttl = %d", ttl)
+	}
+}
+
+func TestSlidingWindowLimiter(t *testing.T) {
+	db := newTestPoolClient(t)
+	ctx := context.Background()
+	l, err := db.NewSlidingWindowLimiter("sw", 2, 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewSlidingWindowLimiter fail err: %v", err)
+	}
+
+	l.Allow(ctx, "k", 2)
+	r, _ := l.Allow(ctx, "k", 1)
+	if r.Allowed || r.RetryAfter <= 0 || r.RetryAfter > 200*time.Millisecond {
+		t.Errorf("Allow = %+v", r)
+	}
+	// 预约排在已有的记录移出窗口之后
+	first, _ := l.Reserve(ctx, "k", 2, time.Second)
+	second, _ := l.Reserve(ctx, "k", 1, time.Second)
+	if !first.Allowed || !second.Allowed || second.RetryAfter < first.RetryAfter+150*time.Millisecond {
+		t.Errorf("Reserve = %+v, %+v", first, second)
+	}
+
+	if n, err := l.Cleanup(ctx); err != nil || n != 0 {
+		t.Errorf("Cleanup = %d, %v", n, err)
+	}
+	time.Sleep(second.RetryAfter + 250*time.Millisecond)
+	if n, err := l.Cleanup(ctx); err != nil || n != 1 {
+		t.Errorf("Cleanup after window = %d, %v", n, err)
+	}
+	if size, _ := db.ZSize("sw:k"); size != 0 {
+		t.Errorf("zset size = %d", size)
+	}
+}
+
+func TestTokenBucketLimiter(t *testing.T) {
+	db := newTestPoolClient(t)
+	ctx := context.Background()
+	l, err := db.NewTokenBucketLimiter("tb", 10, time.Second, 3)
+	if err != nil {
+		t.Fatalf("NewTokenBucketLimiter fail err: %v", err)
+	}
+
+	if r, _ := l.Allow(ctx, "k", 3); !r.Allowed || r.Remaining != 0 {
+		t.Errorf("Allow burst = %+v", r)
+	}
+	r, _ := l.Allow(ctx, "k", 1)
+	if r.Allowed || r.RetryAfter <= 0 || r.RetryAfter > 100*time.Millisecond {
+		t.Errorf("Allow empty = %+v", r)
+	}
+	if r, _ = l.Reserve(ctx, "k", 2, time.Second); !r.Allowed || r.RetryAfter <= 100*time.Millisecond || r.RetryAfter > 200*time.Millisecond {
+		t.Errorf("Reserve = %+v", r)
+	}
+	if n, _ := l.Cleanup(ctx); n != 0 {
+		t.Errorf("Cleanup of a used bucket = %d", n)
+	}
+
+	// 补满之后可以再次突发, 并且可以被清理
+	time.Sleep(600 * time.Millisecond)
+	if n, err := l.Cleanup(ctx); err != nil || n != 1 {
+		t.Errorf("Cleanup = %d, %v", n, err)
+	}
+	if size, _ := db.HSize("tb:k"); size != 0 {
+		t.Errorf("hash size = %d", size)
+	}
+	if r, _ = l.Allow(ctx, "k", 3); !r.Allowed {
+		t.Errorf("Allow after refill = %+v", r)
+	}
+}
+
+func TestRateLimitersConcurrent(t *testing.T) {
+	db := newTestPoolClient(t)
+	limiters := newTestLimiters(t, db, 5)
+	for kind, l := range limiters {
+		var mu sync.Mutex
+		allowed := 0
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				r, err := l.Allow(context.Background(), "k", 1)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if r.Allowed {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		if allowed == 0 || allowed > 5 {
+			t.Errorf("%s: allowed %d of 20, want at most 5", kind, allowed)
+		}
+	}
+}
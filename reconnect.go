@@ -0,0 +1,141 @@
+package gossdb_client
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/houbin910902/gossdb_client/gossdb/ssdb"
+)
+
+//  断线重连策略.
+//  连接断开后, 下一条命令执行前会先重新连接并重新认证. 只读的幂等命令(get, hget, zscan 等)
+//  在网络错误时会按指数退避重试; incr, qpush 等非幂等命令以及所有写命令不会重试, 直接返回错误.
+//  参数编码失败、认证失败等不是网络错误, 也不会重试.
+type ReconnectPolicy struct {
+	// 幂等命令最多重试的次数
+	MaxRetries int
+	// 第一次重试前的等待时间, 之后每次翻倍. 默认 50ms
+	MinBackoff time.Duration
+	// 最长的等待时间. 默认 5s
+	MaxBackoff time.Duration
+}
+
+//  幂等的只读命令, 网络错误时可以安全地重试
+var idempotentCmds = map[string]bool{
+	"get": true, "exists": true, "ttl": true, "strlen": true, "substr": true, "getbit": true,
+	"keys": true, "rkeys": true, "scan": true, "rscan": true, "multi_get": true,
+	"hget": true, "hexists": true, "hsize": true, "hkeys": true, "hgetall": true,
+	"hscan": true, "hrscan": true, "hlist": true, "hrlist": true, "multi_hget": true,
+	"zget": true, "zexists": true, "zsize": true, "zkeys": true, "zscan": true, "zrscan": true,
+	"zlist": true, "zrlist": true, "zcount": true, "zsum": true, "zavg": true,
+	"zrank": true, "zrrank": true, "zrange": true, "zrrange": true, "multi_zget": true,
+	"qsize": true, "qfront": true, "qback": true, "qget": true, "qrange": true, "qslice": true,
+	"qlist": true, "qrlist": true,
+	"ping": true, "info": true, "dbsize": true, "version": true,
+}
+
+//  开启断线重连, policy 为 nil 时关闭.
+//  只对 NewDbClient 创建的 client 有效, 连接池中的连接断开后由连接池丢弃.
+func (c *DbClient) SetReconnect(policy *ReconnectPolicy) {
+	if policy == nil {
+		c.reconnect = nil
+		return
+	}
+	p := *policy
+	if p.MinBackoff <= 0 {
+		p.MinBackoff = 50 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 5 * time.Second
+	}
+	if p.MaxBackoff < p.MinBackoff {
+		p.MaxBackoff = p.MinBackoff
+	}
+	c.reconnect = &p
+}
+
+//  执行命令, 连接断开时先重连, 幂等命令在网络错误时重试
+func (c *DbClient) doRetry(args []interface{}) ([]string, error) {
+	retry := len(args) > 0 && idempotentCmds[toCmd(args[0])]
+	for attempt := 0; ; attempt++ {
+		var resp []string
+		err := c.redialIfBroken()
+		if err == nil {
+			resp, err = c.roundTrip(args)
+			if err == nil {
+				return resp, nil
+			}
+		}
+		if !retry || attempt >= c.reconnect.MaxRetries || !retryable(err) {
+			return nil, err
+		}
+		if err := c.sleep(c.reconnect.backoff(attempt)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+//  连接不可用时重新连接并认证. 此时还没有发出任何命令, 所以对所有命令都是安全的
+func (c *DbClient) redialIfBroken() error {
+	if !c.Client.IsBroken() {
+		return nil
+	}
+	if err := c.Client.Reconnect(); err != nil {
+		return err
+	}
+	if c.password == "" {
+		return nil
+	}
+	resp, err := c.roundTrip([]interface{}{"auth", c.password})
+	if err != nil {
+		return err
+	}
+	if err := respError([]interface{}{"auth"}, resp); err != nil {
+		// 没有认证的连接不能继续使用, 关闭后连接处于断开状态, 下一条命令会再次重连
+		c.Client.Close()
+		return err
+	}
+	return nil
+}
+
+func (c *DbClient) sleep(d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-c.Context().Done():
+		return c.Context().Err()
+	}
+}
+
+//  第 attempt 次重试前的等待时间, 在 [d/2, d] 之间随机, 避免多个 client 同时重连
+func (p *ReconnectPolicy) backoff(attempt int) time.Duration {
+	d := p.MinBackoff
+	for i := 0; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	half := int64(d / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+//  只有网络错误可以重试. ctx 取消或超时、参数编码失败、认证失败等都不重试
+func retryable(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, ssdb.ErrBroken) {
+		return true
+	}
+	// ctx 取消或超时返回的是 ctx.Err(), 不是 net.Error
+	var ne net.Error
+	return errors.As(err, &ne)
+}
+
+func toCmd(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
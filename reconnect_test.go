@@ -0,0 +1,89 @@
+package gossdb_client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/houbin910902/gossdb_client/gossdb/ssdb"
+)
+
+func TestReconnectDropped(t *testing.T) {
+	db, s := newTestClient(t)
+	db.SetReconnect(&ReconnectPolicy{MaxRetries: 2, MinBackoff: time.Millisecond})
+	db.Set("n", "1")
+
+	// incr 不是幂等命令, 连接断开时直接返回错误
+	s.CloseClientConnections()
+	if _, err := db.IncR("n", 1); err == nil {
+		t.Fatal("IncR on a dropped connection should fail")
+	}
+	if !db.Client.IsBroken() {
+		t.Fatal("client should be broken")
+	}
+	// 下一条命令先重连并认证
+	if n, err := db.IncR("n", 1); err != nil || n != 2 {
+		t.Fatalf("IncR after redial = %d, %v", n, err)
+	}
+
+	// 管道在发出前重连
+	s.CloseClientConnections()
+	if err := db.Set("x", "1"); err == nil || !db.Client.IsBroken() {
+		t.Fatalf("Set on a dropped connection err = %v", err)
+	}
+	p := db.Pipeline()
+	get := p.Get("n")
+	if _, err := p.Exec(); err != nil {
+		t.Fatalf("Exec after redial fail err: %v", err)
+	}
+	if v, _ := get.String(); v != "2" {
+		t.Errorf("Get = %q", v)
+	}
+}
+
+func TestReconnectAuthFailure(t *testing.T) {
+	db, s := newTestClient(t)
+	// 任何一次重试都会等待到 ctx 超时
+	db.SetReconnect(&ReconnectPolicy{MaxRetries: 2, MinBackoff: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	c := db.WithContext(ctx)
+
+	s.SetPassword("changed")
+	s.CloseClientConnections()
+	c.Set("a", "1")
+	var ce *CommandError
+	if _, err := c.Get("a"); !errors.As(err, &ce) || ce.Code != "error" {
+		t.Fatalf("Get with a wrong password err = %v", err)
+	}
+	if !db.Client.IsBroken() {
+		t.Fatal("client should be broken after auth failure")
+	}
+
+	s.SetPassword(testPassword)
+	if err := c.Set("a", "1"); err != nil {
+		t.Fatalf("Set after re-auth fail err: %v", err)
+	}
+	if v, err := c.Get("a"); err != nil || v != "1" {
+		t.Errorf("Get after re-auth = %q, %v", v, err)
+	}
+}
+
+func TestReconnectBadArguments(t *testing.T) {
+	db, _ := newTestClient(t)
+	db.SetReconnect(&ReconnectPolicy{MaxRetries: 2, MinBackoff: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// 编码失败不是网络错误, 不重试, 连接仍然可用
+	if _, err := db.WithContext(ctx).do("get", struct{}{}); !errors.Is(err, ssdb.ErrBadArguments) {
+		t.Fatalf("get err = %v, want ErrBadArguments", err)
+	}
+	if db.Client.IsBroken() {
+		t.Fatal("client should not be broken")
+	}
+	if err := db.Set("a", "1"); err != nil {
+		t.Fatalf("Set fail err: %v", err)
+	}
+}
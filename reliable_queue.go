@@ -0,0 +1,381 @@
+package gossdb_client
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//  ReliableQueue 的默认选项
+const (
+	DefaultVisibilityTimeout = 30 * time.Second
+	DefaultMaxAttempts       = 5
+)
+
+//  可靠队列的选项
+type ReliableQueueOptions struct {
+	// 取出的消息在这段时间内没有 Ack 时重新投递, 默认 DefaultVisibilityTimeout
+	VisibilityTimeout time.Duration
+	// 最多投递的次数, 超过后移到死信队列. 默认 DefaultMaxAttempts, 小于 0 时不限制
+	MaxAttempts int64
+	// 检查超时消息和空队列的间隔, 默认 1s
+	PollInterval time.Duration
+	// 每次重新投递的最大个数, 默认 100
+	BatchSize int64
+	// Run 中并发执行 handler 的 worker 数, 默认 1
+	Concurrency int
+	// Run 中处理消息或者访问 ssdb 出错时调用, 访问 ssdb 出错时 msg 为 nil. 可以为 nil
+	OnError func(msg *QueueMessage, err error)
+}
+
+//  从可靠队列取出的消息
+type QueueMessage struct {
+	ID      string
+	Payload string
+	// 第几次投递, 从 1 开始
+	Attempt int64
+	// 在这之前没有 Ack 时消息会被重新投递
+	Deadline time.Time
+}
+
+//  可靠队列的统计
+type ReliableQueueStats struct {
+	// 等待投递的消息数
+	Pending int64
+	// 已经投递还没有 Ack 的消息数
+	InFlight int64
+	// 死信队列中的消息数
+	Dead int64
+}
+
+//  可靠队列, 消息至少被处理一次.
+//
+//  使用五个容器: name:pending 是队列, 保存等待投递的消息 id; name:inflight 是 zset, key 为每一次投递("消息 id:投递次数"),
+//  权重为重新投递的时间(unix 毫秒); name:messages 和 name:attempts 是 hashmap, 保存消息的内容和投递次数;
+//  name:dead 是队列, 保存超过投递次数的消息内容.
+//
+//  Pop 从 name:pending 取出消息 id 后把这次投递放入 name:inflight, Ack 时删除; 超时没有 Ack 的投递由 Requeue 把消息放回 name:pending.
+//  Ack、Nack 和 Requeue 用 zdel 的返回值认领 name:inflight 中的投递, 同一次投递只会被其中一个处理;
+//  超时后迟到的 Ack 和 Nack 只能认领自己的那次投递, 不会影响重新投递的消息.
+//  取出 id 和放入 name:inflight 之间进程崩溃时消息不会被重新投递, 留在 name:messages 中.
+//
+//  c 应当可以被多个 goroutine 同时使用, 例如 Pool.Client(). 用法:
+//
+//	q := db.NewReliableQueue("orders", nil)
+//	q.Push(ctx, payload)
+//	go q.Run(ctx, func(ctx context.Context, msg *gossdb_client.QueueMessage) error { ... })
+type ReliableQueue struct {
+	c        *DbClient
+	opts     ReliableQueueOptions
+	pending  string
+	inflight string
+	messages string
+	attempts string
+	dead     string
+
+	// 上一次 Requeue 的时间(unix 纳秒), 用于 Pop 定期检查超时的消息
+	lastRequeue atomic.Int64
+}
+
+//  创建可靠队列, 不会访问服务端
+//  name 队列的名字, 用作五个容器名字的前缀
+//  opts 选项, 可以为 nil
+func (c *DbClient) NewReliableQueue(name string, opts *ReliableQueueOptions) *ReliableQueue {
+	var o ReliableQueueOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.VisibilityTimeout <= 0 {
+		o.VisibilityTimeout = DefaultVisibilityTimeout
+	}
+	if o.MaxAttempts == 0 {
+		o.MaxAttempts = DefaultMaxAttempts
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = time.Second
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 100
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = 1
+	}
+	return &ReliableQueue{
+		c:        c,
+		opts:     o,
+		pending:  name + ":pending",
+		inflight: name + ":inflight",
+		messages: name + ":messages",
+		attempts: name + ":attempts",
+		dead:     name + ":dead",
+	}
+}
+
+//  返回死信队列的名字, 可以用 QRange、QPopFront 等查看和处理
+func (q *ReliableQueue) DeadLetterQueue() string {
+	return q.dead
+}
+
+//  在队列的尾部加入一个或多个消息
+//  返回 ids, 消息的 id
+//  返回 err，可能的错误，操作成功返回 nil
+func (q *ReliableQueue) Push(ctx context.Context, payload ...string) ([]string, error) {
+	if len(payload) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, len(payload))
+	kvs := make(map[string]interface{}, len(payload))
+	push := []interface{}{"qpush_back", q.pending}
+	for i, v := range payload {
+		ids[i] = randomToken()
+		kvs[ids[i]] = v
+		push = append(push, ids[i])
+	}
+	p := q.c.WithContext(ctx).Pipeline()
+	// 先写入内容再加入队列, 保证取出时能读到内容
+	p.MultiHSet(q.messages, kvs)
+	r := p.Do(push...)
+	if _, err := p.Exec(); err != nil {
+		return nil, err
+	}
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+//  取出队列首部的消息, 消息在 VisibilityTimeout 之内没有 Ack 时会被重新投递.
+//  超过 MaxAttempts 的消息移到死信队列, 继续取下一个. 每隔 PollInterval 先把超时的消息放回队列
+//  返回 msg, 取出的消息; 队列为空时返回 ErrNotFound
+//  返回 err，可能的错误，操作成功返回 nil
+func (q *ReliableQueue) Pop(ctx context.Context) (*QueueMessage, error) {
+	now := time.Now()
+	if last := q.lastRequeue.Load(); now.UnixNano()-last >= int64(q.opts.PollInterval) &&
+		q.lastRequeue.CompareAndSwap(last, now.UnixNano()) {
+		if _, err := q.Requeue(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	c := q.c.WithContext(ctx)
+	for {
+		id, err := c.QPopFront(q.pending)
+		if err != nil {
+			return nil, err
+		}
+		p := c.Pipeline()
+		attempt := p.HIncR(q.attempts, id, 1)
+		payload := p.HGet(q.messages, id)
+		if _, err = p.Exec(); err != nil {
+			return nil, err
+		}
+		n, err := attempt.Int64()
+		if err != nil {
+			return nil, err
+		}
+		v, err := payload.String()
+		if errors.Is(err, ErrNotFound) {
+			// 消息已经被删除, 例如迟到的 Ack 和重新投递同时发生
+			q.remove(c, id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if q.opts.MaxAttempts > 0 && n > q.opts.MaxAttempts {
+			if err = q.bury(c, id, v); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		// 投递次数在 hincr 之后才知道, 因此单独放入 name:inflight
+		deadline := time.Now().Add(q.opts.VisibilityTimeout)
+		if err = c.ZSet(q.inflight, deliveryKey(id, n), deadline.UnixMilli()); err != nil {
+			return nil, err
+		}
+		return &QueueMessage{ID: id, Payload: v, Attempt: n, Deadline: deadline}, nil
+	}
+}
+
+//  name:inflight 中一次投递的 key
+func deliveryKey(id string, attempt int64) string {
+	return id + ":" + strconv.FormatInt(attempt, 10)
+}
+
+//  从投递的 key 中取出消息 id
+func deliveryID(key string) string {
+	if i := strings.LastIndexByte(key, ':'); i >= 0 {
+		return key[:i]
+	}
+	return key
+}
+
+//  把消息移到死信队列
+func (q *ReliableQueue) bury(c *DbClient, id, payload string) error {
+	p := c.Pipeline()
+	r := p.QPush(q.dead, payload)
+	p.HDel(q.messages, id)
+	p.HDel(q.attempts, id)
+	if _, err := p.Exec(); err != nil {
+		return err
+	}
+	return r.Err()
+}
+
+func (q *ReliableQueue) remove(c *DbClient, id string) error {
+	p := c.Pipeline()
+	p.HDel(q.messages, id)
+	p.HDel(q.attempts, id)
+	_, err := p.Exec()
+	return err
+}
+
+//  从 name:inflight 中认领 msg 的这一次投递
+func (q *ReliableQueue) claim(c *DbClient, msg *QueueMessage) (bool, error) {
+	p := c.Pipeline()
+	r := p.ZDel(q.inflight, deliveryKey(msg.ID, msg.Attempt))
+	if _, err := p.Exec(); err != nil {
+		return false, err
+	}
+	return r.Bool()
+}
+
+//  确认消息已经处理, 删除消息
+//  msg Pop 返回的消息, 只确认这一次投递
+//  返回 ok, 这次投递已经超时被重新投递或者已经确认时返回 false, 不会删除重新投递的消息
+//  返回 err，可能的错误，操作成功返回 nil
+func (q *ReliableQueue) Ack(ctx context.Context, msg *QueueMessage) (bool, error) {
+	c := q.c.WithContext(ctx)
+	if ok, err := q.claim(c, msg); err != nil || !ok {
+		return false, err
+	}
+	return true, q.remove(c, msg.ID)
+}
+
+//  处理失败, 把消息放回队列的尾部立即重新投递, 投递次数超过 MaxAttempts 时移到死信队列
+//  msg Pop 返回的消息, 只放回这一次投递
+//  返回 ok, 这次投递已经超时被重新投递或者已经确认时返回 false
+//  返回 err，可能的错误，操作成功返回 nil
+func (q *ReliableQueue) Nack(ctx context.Context, msg *QueueMessage) (bool, error) {
+	c := q.c.WithContext(ctx)
+	if ok, err := q.claim(c, msg); err != nil || !ok {
+		return false, err
+	}
+	_, err := c.QPushBack(q.pending, msg.ID)
+	return true, err
+}
+
+//  把最多 BatchSize 个超时没有 Ack 的消息放回队列的首部. Pop 和 Run 会定期调用
+//  返回 n, 放回的消息数
+//  返回 err，可能的错误，操作成功返回 nil
+func (q *ReliableQueue) Requeue(ctx context.Context) (int, error) {
+	c := q.c.WithContext(ctx)
+	keys, _, err := c.ZScan(q.inflight, "", "", time.Now().UnixMilli(), q.opts.BatchSize)
+	if err != nil || len(keys) == 0 {
+		return 0, err
+	}
+	p := c.Pipeline()
+	claims := make([]*Reply, len(keys))
+	for i, key := range keys {
+		claims[i] = p.ZDel(q.inflight, key)
+	}
+	if _, err = p.Exec(); err != nil {
+		return 0, err
+	}
+	push := []interface{}{"qpush_front", q.pending}
+	for i, key := range keys {
+		if ok, err := claims[i].Bool(); err == nil && ok {
+			push = append(push, deliveryID(key))
+		}
+	}
+	n := len(push) - 2
+	if n == 0 {
+		return 0, nil
+	}
+	if _, err = c.Do(push...); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+//  返回队列的统计
+//  返回 err，可能的错误，操作成功返回 nil
+func (q *ReliableQueue) Stats(ctx context.Context) (ReliableQueueStats, error) {
+	p := q.c.WithContext(ctx).Pipeline()
+	pending := p.QSize(q.pending)
+	inflight := p.Do("zsize", q.inflight)
+	dead := p.QSize(q.dead)
+	if _, err := p.Exec(); err != nil {
+		return ReliableQueueStats{}, err
+	}
+	var s ReliableQueueStats
+	var err error
+	if s.Pending, err = pending.Int64(); err != nil {
+		return ReliableQueueStats{}, err
+	}
+	if s.InFlight, err = inflight.Int64(); err != nil {
+		return ReliableQueueStats{}, err
+	}
+	if s.Dead, err = dead.Int64(); err != nil {
+		return ReliableQueueStats{}, err
+	}
+	return s, nil
+}
+
+//  用 Concurrency 个 worker 取出消息并执行 handler, 阻塞到 ctx 取消.
+//  handler 返回 nil 时 Ack, 返回错误时调用 OnError 并 Nack. handler 收到的 ctx 在消息的 Deadline 时取消,
+//  不会因为 Run 的 ctx 取消而取消: ctx 取消后不再取出新的消息, 等待正在执行的 handler 返回后退出
+//  返回 ctx.Err()
+func (q *ReliableQueue) Run(ctx context.Context, handler func(ctx context.Context, msg *QueueMessage) error) error {
+	opCtx := context.WithoutCancel(ctx)
+	var wg sync.WaitGroup
+	wg.Add(q.opts.Concurrency)
+	for i := 0; i < q.opts.Concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			q.workLoop(ctx, opCtx, handler)
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+func (q *ReliableQueue) workLoop(ctx, opCtx context.Context, handler func(context.Context, *QueueMessage) error) {
+	for ctx.Err() == nil {
+		msg, err := q.Pop(opCtx)
+		if err != nil {
+			if !errors.Is(err, ErrNotFound) {
+				q.onError(nil, err)
+			}
+			t := time.NewTimer(q.opts.PollInterval)
+			select {
+			case <-ctx.Done():
+			case <-t.C:
+			}
+			t.Stop()
+			continue
+		}
+		hctx, cancel := context.WithDeadline(opCtx, msg.Deadline)
+		err = handler(hctx, msg)
+		cancel()
+		if err != nil {
+			q.onError(msg, err)
+			_, err = q.Nack(opCtx, msg)
+		} else {
+			_, err = q.Ack(opCtx, msg)
+		}
+		if err != nil {
+			q.onError(msg, err)
+		}
+	}
+}
+
+func (q *ReliableQueue) onError(msg *QueueMessage, err error) {
+	if q.opts.OnError != nil {
+		q.opts.OnError(msg, err)
+	}
+}
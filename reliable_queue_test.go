@@ -0,0 +1,181 @@
+package gossdb_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReliableQueue(t *testing.T) {
+	db := newTestPoolClient(t)
+	ctx := context.Background()
+	q := db.NewReliableQueue("rq", nil)
+
+	ids, err := q.Push(ctx, "a", "b", "c")
+	if err != nil || len(ids) != 3 {
+		t.Fatalf("Push = %v, %v", ids, err)
+	}
+	msg, err := q.Pop(ctx)
+	if err != nil {
+		t.Fatalf("Pop fail err: %v", err)
+	}
+	if msg.ID != ids[0] || msg.Payload != "a" || msg.Attempt != 1 {
+		t.Errorf("Pop = %+v", msg)
+	}
+	if d := time.Until(msg.Deadline); d <= 0 || d > DefaultVisibilityTimeout {
+		t.Errorf("Deadline in %v", d)
+	}
+	if s, _ := q.Stats(ctx); s != (ReliableQueueStats{Pending: 2, InFlight: 1}) {
+		t.Errorf("Stats = %+v", s)
+	}
+	if ok, err := q.Ack(ctx, msg); !ok || err != nil {
+		t.Errorf("Ack = %v, %v", ok, err)
+	}
+	if ok, _ := q.Ack(ctx, msg); ok {
+		t.Error("second Ack should return false")
+	}
+	if s, _ := q.Stats(ctx); s != (ReliableQueueStats{Pending: 2}) {
+		t.Errorf("Stats after Ack = %+v", s)
+	}
+
+	// Nack 把消息放回队列尾部
+	msg, _ = q.Pop(ctx)
+	if ok, err := q.Nack(ctx, msg); !ok || err != nil {
+		t.Errorf("Nack = %v, %v", ok, err)
+	}
+	for _, want := range []string{"c", "b"} {
+		m, err := q.Pop(ctx)
+		if err != nil || m.Payload != want {
+			t.Fatalf("Pop = %+v, %v, want %s", m, err, want)
+		}
+		q.Ack(ctx, m)
+		if want == "b" && m.Attempt != 2 {
+			t.Errorf("Attempt = %d, want 2", m.Attempt)
+		}
+	}
+	if _, err = q.Pop(ctx); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Pop on empty queue err = %v", err)
+	}
+	for _, name := range []string{"rq:messages", "rq:attempts"} {
+		if size, _ := db.HSize(name); size != 0 {
+			t.Errorf("%s size = %d, want 0", name, size)
+		}
+	}
+}
+
+func TestReliableQueueRedeliver(t *testing.T) {
+	db := newTestPoolClient(t)
+	ctx := context.Background()
+	q := db.NewReliableQueue("rq", &ReliableQueueOptions{
+		VisibilityTimeout: 50 * time.Millisecond,
+		PollInterval:      10 * time.Millisecond,
+		MaxAttempts:       2,
+	})
+	q.Push(ctx, "job")
+
+	first, err := q.Pop(ctx)
+	if err != nil {
+		t.Fatalf("Pop fail err: %v", err)
+	}
+	// 不 Ack, 超时后重新投递
+	if _, err = q.Pop(ctx); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Pop before timeout err = %v", err)
+	}
+	time.Sleep(80 * time.Millisecond)
+	second, err := q.Pop(ctx)
+	if err != nil {
+		t.Fatalf("Pop after timeout fail err: %v", err)
+	}
+	if second.ID != first.ID || second.Attempt != 2 {
+		t.Errorf("redelivered %+v", second)
+	}
+	// 超时后迟到的 Ack 只能认领第一次投递, 不会删除重新投递的消息
+	if ok, err := q.Ack(ctx, first); ok || err != nil {
+		t.Errorf("stale Ack = %v, %v", ok, err)
+	}
+	if ok, err := q.Nack(ctx, first); ok || err != nil {
+		t.Errorf("stale Nack = %v, %v", ok, err)
+	}
+	if s, _ := q.Stats(ctx); s != (ReliableQueueStats{InFlight: 1}) {
+		t.Errorf("Stats after stale Ack = %+v", s)
+	}
+	if v, err := db.HGet("rq:messages", first.ID); err != nil || v != "job" {
+		t.Errorf("message after stale Ack = %q, %v", v, err)
+	}
+
+	// 第三次投递超过 MaxAttempts, 移到死信队列
+	time.Sleep(80 * time.Millisecond)
+	if _, err = q.Pop(ctx); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Pop err = %v, want ErrNotFound", err)
+	}
+	if s, _ := q.Stats(ctx); s != (ReliableQueueStats{Dead: 1}) {
+		t.Errorf("Stats = %+v", s)
+	}
+	if v, _ := db.QFront(q.DeadLetterQueue()); v != "job" {
+		t.Errorf("dead letter = %q", v)
+	}
+	if ok, _ := q.Ack(ctx, first); ok {
+		t.Error("Ack of a dead message should return false")
+	}
+}
+
+func TestReliableQueueRun(t *testing.T) {
+	db := newTestPoolClient(t)
+	var mu sync.Mutex
+	var errs []error
+	q := db.NewReliableQueue("rq", &ReliableQueueOptions{
+		Concurrency:  4,
+		PollInterval: 10 * time.Millisecond,
+		OnError: func(msg *QueueMessage, err error) {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	const total = 20
+	for i := 0; i < total; i++ {
+		q.Push(ctx, fmt.Sprint(i))
+	}
+
+	handled := map[string]int{}
+	all := make(chan struct{})
+	closed := false
+	handler := func(ctx context.Context, msg *QueueMessage) error {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("handler ctx has no deadline")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		handled[msg.Payload]++
+		if msg.Payload == "3" && msg.Attempt == 1 {
+			return errors.New("try again")
+		}
+		if len(handled) == total && handled["3"] == 2 && !closed {
+			closed = true
+			close(all)
+		}
+		return nil
+	}
+	ret := make(chan error, 1)
+	go func() { ret <- q.Run(ctx, handler) }()
+	select {
+	case <-all:
+	case <-time.After(2 * time.Second):
+		t.Fatal("messages not handled")
+	}
+	cancel()
+	if err := <-ret; !errors.Is(err, context.Canceled) {
+		t.Errorf("Run err = %v", err)
+	}
+	if s, _ := q.Stats(context.Background()); s != (ReliableQueueStats{}) {
+		t.Errorf("Stats = %+v", s)
+	}
+	if len(errs) != 1 {
+		t.Errorf("OnError called %d times: %v", len(errs), errs)
+	}
+}
@@ -0,0 +1,294 @@
+package gossdb_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/houbin910902/gossdb_client/gossdb/ssdb"
+)
+
+//  选择副本的方式
+type BalanceMode int
+
+const (
+	// 依次轮流使用每个可用的副本
+	RoundRobin BalanceMode = iota
+	// 使用最近平均响应时间最短的副本
+	LeastLatency
+)
+
+//  主从读写分离的配置
+type ReplicatedConfig struct {
+	// 主库的连接池配置, 所有写命令和非幂等命令都发往主库
+	Master PoolConfig
+	// 副本(从库)的连接池配置, 只读命令发往副本
+	Replicas []PoolConfig
+
+	// 选择副本的方式, 默认 RoundRobin
+	Balance BalanceMode
+	// 副本连续失败这么多次后被剔除, 默认 3
+	MaxFailures int
+	// 被剔除的副本经过这么长时间后重新参与选择, 默认 10s
+	EjectTimeout time.Duration
+	// 为 true 时没有可用的副本直接返回错误, 否则改为读主库
+	NoMasterFallback bool
+}
+
+//  主从读写分离的 client, 拥有 DbClient 的全部方法, 可以被多个 goroutine 同时使用.
+//  只读命令(get、hget、zscan、qrange 等)发往可用的副本, 副本网络出错时换一个副本重试, 都不可用时读主库;
+//  其它命令都发往主库. 需要读到自己刚写入的数据时, 使用 FromMaster 或 ReadFromMaster 强制读主库
+type ReplicatedClient struct {
+	DbClient
+	r *replicaSet
+}
+
+//  每个节点是一个连接池
+type replicaSet struct {
+	conf     ReplicatedConfig
+	master   *Pool
+	replicas []*replica
+	next     uint32
+}
+
+type replica struct {
+	addr string
+	pool *Pool
+
+	mu           sync.Mutex
+	failures     int
+	ejectedUntil time.Time
+	latency      time.Duration // 响应时间的指数加权平均
+}
+
+//  副本的状态
+type ReplicaStatus struct {
+	Addr     string
+	Ejected  bool
+	Failures int
+	Latency  time.Duration
+}
+
+type readFromMasterKey struct{}
+
+//  返回一个带有强制读主库标记的 ctx, 通过 WithContext 绑定后, 这个 client 副本的所有命令都发往主库
+func ReadFromMaster(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readFromMasterKey{}, true)
+}
+
+func readFromMaster(ctx context.Context) bool {
+	v, _ := ctx.Value(readFromMasterKey{}).(bool)
+	return v
+}
+
+//  创建主从读写分离的 client, 为主库和每个副本各创建一个连接池
+//  conf 配置
+//  返回 err，可能的错误，操作成功返回 nil
+func NewReplicatedClient(conf *ReplicatedConfig) (*ReplicatedClient, error) {
+	r := &replicaSet{conf: *conf}
+	if r.conf.MaxFailures <= 0 {
+		r.conf.MaxFailures = 3
+	}
+	if r.conf.EjectTimeout <= 0 {
+		r.conf.EjectTimeout = 10 * time.Second
+	}
+	var err error
+	if r.master, err = NewPool(&r.conf.Master); err != nil {
+		return nil, err
+	}
+	for i := range r.conf.Replicas {
+		rc := &r.conf.Replicas[i]
+		p, err := NewPool(rc)
+		if err != nil {
+			r.close()
+			return nil, err
+		}
+		r.replicas = append(r.replicas, &replica{addr: poolAddr(rc), pool: p})
+	}
+	return &ReplicatedClient{DbClient: DbClient{ex: r, codec: r.conf.Master.Codec}, r: r}, nil
+}
+
+func poolAddr(conf *PoolConfig) string {
+	return fmt.Sprintf("%s:%d", conf.Ip, conf.Port)
+}
+
+//  返回一个绑定了 ctx 的 ReplicatedClient 副本, 参见 DbClient.WithContext
+func (c *ReplicatedClient) WithContext(ctx context.Context) *ReplicatedClient {
+	return &ReplicatedClient{*c.DbClient.WithContext(ctx), c.r}
+}
+
+//  返回一个所有命令都发往主库的副本, 用于读取刚写入的数据
+func (c *ReplicatedClient) FromMaster() *ReplicatedClient {
+	return c.WithContext(ReadFromMaster(c.Context()))
+}
+
+//  返回所有副本的状态
+func (c *ReplicatedClient) Replicas() []ReplicaStatus {
+	now := time.Now()
+	out := make([]ReplicaStatus, 0, len(c.r.replicas))
+	for _, rp := range c.r.replicas {
+		rp.mu.Lock()
+		out = append(out, ReplicaStatus{
+			Addr:     rp.addr,
+			Ejected:  now.Before(rp.ejectedUntil),
+			Failures: rp.failures,
+			Latency:  rp.latency,
+		})
+		rp.mu.Unlock()
+	}
+	return out
+}
+
+//  关闭主库和所有副本的连接池
+func (c *ReplicatedClient) Close() error {
+	return c.r.close()
+}
+
+func (r *replicaSet) close() error {
+	err := r.master.Close()
+	for _, rp := range r.replicas {
+		rp.pool.Close()
+	}
+	return err
+}
+
+func (r *replicaSet) exec(ctx context.Context, args []interface{}) ([]string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if len(args) == 0 || !idempotentCmds[toCmd(args[0])] || readFromMaster(ctx) {
+		return r.master.exec(ctx, args)
+	}
+	var resp []string
+	err := r.read(ctx, func(p *Pool) (err error) {
+		resp, err = p.exec(ctx, args)
+		return err
+	})
+	return resp, err
+}
+
+func (r *replicaSet) execPipeline(ctx context.Context, cmds [][]interface{}) ([][]string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	readOnly := !readFromMaster(ctx)
+	for _, args := range cmds {
+		if len(args) == 0 || !idempotentCmds[toCmd(args[0])] {
+			readOnly = false
+			break
+		}
+	}
+	if !readOnly {
+		return r.master.execPipeline(ctx, cmds)
+	}
+	var resps [][]string
+	err := r.read(ctx, func(p *Pool) (err error) {
+		resps, err = p.execPipeline(ctx, cmds)
+		return err
+	})
+	return resps, err
+}
+
+var errNoReplica = errors.New("gossdb_client: no healthy replica")
+
+//  在副本上执行只读操作, 网络出错时换下一个副本, 全部失败时读主库
+func (r *replicaSet) read(ctx context.Context, fn func(p *Pool) error) error {
+	tried := make(map[*replica]bool, len(r.replicas))
+	for {
+		rp := r.pick(tried)
+		if rp == nil {
+			break
+		}
+		tried[rp] = true
+		start := time.Now()
+		err := fn(rp.pool)
+		if err == nil || !nodeFailure(err) {
+			rp.success(time.Since(start))
+			return err
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		rp.fail(r.conf.MaxFailures, r.conf.EjectTimeout)
+	}
+	if r.conf.NoMasterFallback {
+		return errNoReplica
+	}
+	return fn(r.master)
+}
+
+//  从没有试过的可用副本中选择一个, 没有时返回 nil
+func (r *replicaSet) pick(tried map[*replica]bool) *replica {
+	now := time.Now()
+	var healthy []*replica
+	for _, rp := range r.replicas {
+		if !tried[rp] && rp.available(now) {
+			healthy = append(healthy, rp)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+	if r.conf.Balance == LeastLatency {
+		best := healthy[0]
+		bestLatency := best.avgLatency()
+		for _, rp := range healthy[1:] {
+			if l := rp.avgLatency(); l < bestLatency {
+				best, bestLatency = rp, l
+			}
+		}
+		return best
+	}
+	n := atomic.AddUint32(&r.next, 1)
+	return healthy[int(n)%len(healthy)]
+}
+
+//  只有网络错误、连接被关闭和已经不可用的连接是节点的故障.
+//  服务端返回的错误(如 not_found)、参数错误、连接池耗尽和 ctx 取消或超时都不是
+func nodeFailure(err error) bool {
+	// context.DeadlineExceeded 同样实现了 net.Error
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var ne net.Error
+	return errors.As(err, &ne) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, ssdb.ErrBroken)
+}
+
+func (rp *replica) available(now time.Time) bool {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	return !now.Before(rp.ejectedUntil)
+}
+
+func (rp *replica) avgLatency() time.Duration {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	return rp.latency
+}
+
+func (rp *replica) success(d time.Duration) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	rp.failures = 0
+	if rp.latency == 0 {
+		rp.latency = d
+	} else {
+		rp.latency += (d - rp.latency) / 8
+	}
+}
+
+//  记录一次失败, 连续失败 max 次后剔除 timeout 时间. 重新参与选择后再失败一次会被立即剔除
+func (rp *replica) fail(max int, timeout time.Duration) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	rp.failures++
+	if rp.failures >= max {
+		rp.ejectedUntil = time.Now().Add(timeout)
+	}
+}
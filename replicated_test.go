@@ -0,0 +1,98 @@
+package gossdb_client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/houbin910902/gossdb_client/gossdb/ssdb"
+	"github.com/houbin910902/gossdb_client/ssdbtest"
+)
+
+func newTestNode(t *testing.T) (*ssdbtest.Server, PoolConfig) {
+	s := ssdbtest.NewServerWithAuth(testPassword)
+	t.Cleanup(s.Close)
+	return s, PoolConfig{Ip: s.Host(), Port: s.Port(), Password: testPassword}
+}
+
+func TestReplicatedClient(t *testing.T) {
+	_, mconf := newTestNode(t)
+	_, r1conf := newTestNode(t)
+	r2, r2conf := newTestNode(t)
+
+	rc, err := NewReplicatedClient(&ReplicatedConfig{
+		Master:      mconf,
+		Replicas:    []PoolConfig{r1conf, r2conf},
+		MaxFailures: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	// 写命令发往主库, 读命令发往副本, 测试中副本不会同步主库的数据
+	if err = rc.Set("a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if _, err = rc.Get("a"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Get from replica err = %v, want ErrNotFound", err)
+		}
+	}
+	if v, err := rc.FromMaster().Get("a"); err != nil || v != "1" {
+		t.Fatalf("FromMaster().Get = %q, %v", v, err)
+	}
+	if v, err := rc.WithContext(ReadFromMaster(context.Background())).Get("a"); err != nil || v != "1" {
+		t.Fatalf("ReadFromMaster Get = %q, %v", v, err)
+	}
+	for _, st := range rc.Replicas() {
+		if st.Ejected || st.Latency == 0 {
+			t.Fatalf("replica %+v should be healthy and used", st)
+		}
+	}
+
+	// 副本故障后被剔除, 读命令由其它副本处理
+	r2.Close()
+	for i := 0; i < 4; i++ {
+		if _, err = rc.Get("a"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Get after replica down err = %v", err)
+		}
+	}
+	st := rc.Replicas()
+	if st[0].Ejected || !st[1].Ejected {
+		t.Fatalf("Replicas = %+v, want second ejected", st)
+	}
+}
+
+func TestReplicatedNotNodeFailure(t *testing.T) {
+	_, mconf := newTestNode(t)
+	_, rconf := newTestNode(t)
+	rc, err := NewReplicatedClient(&ReplicatedConfig{
+		Master:           mconf,
+		Replicas:         []PoolConfig{rconf},
+		MaxFailures:      1,
+		NoMasterFallback: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	// 参数错误和 ctx 取消不是副本的故障, 副本不会被剔除
+	for i := 0; i < 3; i++ {
+		if _, err = rc.do("get", struct{}{}); !errors.Is(err, ssdb.ErrBadArguments) {
+			t.Fatalf("get err = %v, want ErrBadArguments", err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = rc.WithContext(ctx).Get("a"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Get err = %v, want context.Canceled", err)
+	}
+	if st := rc.Replicas(); st[0].Ejected {
+		t.Fatalf("Replicas = %+v, want the replica kept", st)
+	}
+	if _, err = rc.Get("a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get err = %v, want ErrNotFound", err)
+	}
+}
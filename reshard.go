@@ -0,0 +1,388 @@
+package gossdb_client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strconv"
+)
+
+//  重新分片时迁移的数据类型
+const (
+	ReshardKV    = "kv"
+	ReshardHash  = "hash"
+	ReshardZSet  = "zset"
+	ReshardQueue = "queue"
+)
+
+var reshardKinds = []string{ReshardKV, ReshardHash, ReshardZSet, ReshardQueue}
+
+//  复制到目标分片的数据与源分片不一致, 源分片的数据没有被删除
+var ErrReshardVerify = errors.New("gossdb_client: reshard verify failed")
+
+//  重新分片的配置
+type ReshardConfig struct {
+	// 迁移前和迁移后的分片, 两者中名字相同的分片必须是同一个实例
+	From, To *ShardedClient
+	// 检查点文件, 中断后用同一个文件重新执行时从上次的位置继续. 为空时不记录
+	Checkpoint string
+	// 遍历和复制时每页的元素个数, 0 表示 DefaultScanPageSize
+	PageSize int64
+	// 只统计需要迁移的数据, 不复制也不删除
+	DryRun bool
+	// 每迁移一个 key 或容器后调用, 可以为 nil
+	Progress func(ReshardMove)
+}
+
+//  一个需要迁移的 key 或容器
+type ReshardMove struct {
+	Kind     string // ReshardKV、ReshardHash、ReshardZSet 或 ReshardQueue
+	Name     string // key 或容器的名字
+	From, To string // 源分片和目标分片的名字
+}
+
+//  重新分片的统计, 以数据类型为 key
+type ReshardStats struct {
+	Scanned map[string]int64 // 检查过的 key 和容器的个数
+	Moved   map[string]int64 // 迁移了的个数, DryRun 时为需要迁移的个数
+}
+
+//  检查点, 记录每个源分片每种数据类型已经处理到的名字.
+//  列表命令按名字有序返回, 从记录的名字之后继续遍历即可
+type reshardCheckpoint struct {
+	Target string            `json:"target"` // 迁移后的哈希环, 用于确认是同一次迁移
+	Cursor map[string]string `json:"cursor"` // 分片/类型 -> 最后处理完的名字
+	Done   map[string]bool   `json:"done"`
+}
+
+//  把 conf.From 中的数据按 conf.To 的分布迁移: 依次用 Keys、HList、ZList、QList 遍历每个源分片,
+//  对于在 conf.To 中属于其它分片的 key 或容器, 先清空目标分片上的同名数据, 复制(kv 带有过期时间),
+//  校验目标分片上的数据与源分片一致后, 再从源分片删除.
+//
+//  每处理一页数据以及返回前都会更新检查点, 中断(包括 ctx 被取消)后使用同一个检查点文件重新执行即可继续,
+//  复制了但没有删除的数据会被重新复制. 迁移期间不应写入正在迁移的数据.
+//  返回 stats, 迁移的统计, 出错时为出错前的统计
+//  返回 err，可能的错误，操作成功返回 nil
+func Reshard(ctx context.Context, conf *ReshardConfig) (*ReshardStats, error) {
+	stats := &ReshardStats{Scanned: map[string]int64{}, Moved: map[string]int64{}}
+	pageSize := conf.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultScanPageSize
+	}
+	cp := &reshardCheckpoint{Target: conf.To.ring.id(), Cursor: map[string]string{}, Done: map[string]bool{}}
+	if conf.Checkpoint != "" && !conf.DryRun {
+		if err := cp.load(conf.Checkpoint); err != nil {
+			return stats, err
+		}
+		defer cp.save(conf.Checkpoint)
+	}
+
+	for _, src := range conf.From.ring.shards {
+		srcClient := &src.pool.Client().WithContext(ctx).DbClient
+		for _, kind := range reshardKinds {
+			ck := src.name + "/" + kind
+			if cp.Done[ck] {
+				continue
+			}
+			opts := &ScanOptions{Start: cp.Cursor[ck], PageSize: pageSize}
+			var it *ScanIterator
+			switch kind {
+			case ReshardKV:
+				it = srcClient.KeysIter(opts)
+			case ReshardHash:
+				it = srcClient.HListIter(opts)
+			case ReshardZSet:
+				it = srcClient.ZListIter(opts)
+			case ReshardQueue:
+				it = srcClient.QListIter(opts)
+			}
+			var n int64
+			for it.Next() {
+				if err := ctx.Err(); err != nil {
+					return stats, err
+				}
+				name := it.Key()
+				stats.Scanned[kind]++
+				if dst := conf.To.ring.locate(name); dst.name != src.name {
+					if !conf.DryRun {
+						dstClient := &dst.pool.Client().WithContext(ctx).DbClient
+						if err := migrate(kind, name, srcClient, dstClient, pageSize); err != nil {
+							return stats, err
+						}
+					}
+					stats.Moved[kind]++
+					if conf.Progress != nil {
+						conf.Progress(ReshardMove{Kind: kind, Name: name, From: src.name, To: dst.name})
+					}
+				}
+				cp.Cursor[ck] = name
+				if n++; n%pageSize == 0 && conf.Checkpoint != "" && !conf.DryRun {
+					if err := cp.save(conf.Checkpoint); err != nil {
+						return stats, err
+					}
+				}
+			}
+			if err := it.Err(); err != nil {
+				return stats, err
+			}
+			cp.Done[ck] = true
+		}
+	}
+	if conf.Checkpoint != "" && !conf.DryRun {
+		return stats, cp.save(conf.Checkpoint)
+	}
+	return stats, nil
+}
+
+//  哈希环的标识, 由所有虚拟节点计算
+func (r *ring) id() string {
+	h := fnv.New64a()
+	for _, p := range r.points {
+		fmt.Fprintf(h, "%d=%s;", p.hash, p.shard.name)
+	}
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+func (cp *reshardCheckpoint) load(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var old reshardCheckpoint
+	if err = json.Unmarshal(data, &old); err != nil {
+		return fmt.Errorf("gossdb_client: invalid reshard checkpoint %s: %w", path, err)
+	}
+	if old.Target != cp.Target {
+		return fmt.Errorf("gossdb_client: reshard checkpoint %s belongs to a different target layout", path)
+	}
+	if old.Cursor != nil {
+		cp.Cursor = old.Cursor
+	}
+	if old.Done != nil {
+		cp.Done = old.Done
+	}
+	return nil
+}
+
+//  先写临时文件再改名, 中断时不会留下不完整的检查点
+func (cp *reshardCheckpoint) save(path string) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func migrate(kind, name string, src, dst *DbClient, pageSize int64) error {
+	var err error
+	switch kind {
+	case ReshardKV:
+		err = migrateKV(name, src, dst)
+	case ReshardHash:
+		err = migrateHash(name, src, dst, pageSize)
+	case ReshardZSet:
+		err = migrateZSet(name, src, dst, pageSize)
+	case ReshardQueue:
+		err = migrateQueue(name, src, dst, int(pageSize))
+	}
+	if errors.Is(err, ErrReshardVerify) {
+		return fmt.Errorf("%w: %s %s", err, kind, name)
+	}
+	return err
+}
+
+//  ssdb 只有 kv 支持过期时间
+func migrateKV(key string, src, dst *DbClient) error {
+	ttl, err := src.Ttl(key)
+	if err != nil {
+		return err
+	}
+	val, err := src.GetBytes(key)
+	if errors.Is(err, ErrNotFound) {
+		// 遍历之后过期了
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if ttl > 0 {
+		err = dst.Set(key, val, ttl)
+	} else {
+		err = dst.Set(key, val)
+	}
+	if err != nil {
+		return err
+	}
+	got, err := dst.GetBytes(key)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, val) {
+		return ErrReshardVerify
+	}
+	return src.Del(key)
+}
+
+func migrateHash(name string, src, dst *DbClient, pageSize int64) error {
+	if err := dst.HClear(name); err != nil {
+		return err
+	}
+	opts := &ScanOptions{PageSize: pageSize}
+	it := src.HScanIter(name, opts)
+	batch := make(map[string]interface{})
+	for it.Next() {
+		batch[it.Key()] = it.Value()
+		if int64(len(batch)) >= pageSize {
+			if err := dst.MultiHSet(name, batch); err != nil {
+				return err
+			}
+			batch = make(map[string]interface{})
+		}
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		if err := dst.MultiHSet(name, batch); err != nil {
+			return err
+		}
+	}
+	if err := sameContent(src.HScanIter(name, opts), dst.HScanIter(name, opts)); err != nil {
+		return err
+	}
+	return src.HClear(name)
+}
+
+func migrateZSet(name string, src, dst *DbClient, pageSize int64) error {
+	if err := dst.ZClear(name); err != nil {
+		return err
+	}
+	opts := &ScanOptions{PageSize: pageSize}
+	it := src.ZScanIter(name, NegInf, PosInf, opts)
+	// 权重按服务端返回的字符串原样复制, 超过 2^53 的整数转换成 float64 会丢失精度
+	var batch []interface{}
+	for it.Next() {
+		batch = append(batch, it.Key(), it.Value())
+		if int64(len(batch)) >= 2*pageSize {
+			if err := multiZSetRaw(dst, name, batch); err != nil {
+				return err
+			}
+			batch = nil
+		}
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		if err := multiZSetRaw(dst, name, batch); err != nil {
+			return err
+		}
+	}
+	err := sameContent(src.ZScanIter(name, NegInf, PosInf, opts), dst.ZScanIter(name, NegInf, PosInf, opts))
+	if err != nil {
+		return err
+	}
+	return src.ZClear(name)
+}
+
+func migrateQueue(name string, src, dst *DbClient, pageSize int) error {
+	if err := dst.QClear(name); err != nil {
+		return err
+	}
+	var n int
+	for offset := 0; ; offset += pageSize {
+		items, err := src.QRange(name, offset, pageSize)
+		if err != nil {
+			return err
+		}
+		if len(items) > 0 {
+			values := make([]interface{}, len(items))
+			for i, v := range items {
+				values[i] = v
+			}
+			if _, err = dst.QPushBackArray(name, values); err != nil {
+				return err
+			}
+		}
+		n += len(items)
+		if len(items) < pageSize {
+			break
+		}
+	}
+	for offset := 0; offset < n; offset += pageSize {
+		a, err := src.QRange(name, offset, pageSize)
+		if err != nil {
+			return err
+		}
+		b, err := dst.QRange(name, offset, pageSize)
+		if err != nil {
+			return err
+		}
+		if len(a) != len(b) {
+			return ErrReshardVerify
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return ErrReshardVerify
+			}
+		}
+	}
+	if size, err := dst.Qsize(name); err != nil {
+		return err
+	} else if size != int64(n) {
+		return ErrReshardVerify
+	}
+	return src.QClear(name)
+}
+
+//  用 multi_zset 写入 key 和权重字符串交替排列的 kvs
+func multiZSetRaw(c *DbClient, name string, kvs []interface{}) error {
+	resp, err := c.do("multi_zset", name, kvs)
+	if err != nil {
+		return err
+	}
+	if len(resp) > 0 && resp[0] == "ok" {
+		return nil
+	}
+	return handError(resp, name)
+}
+
+//  逐个比较两个遍历的元素, zset 比较权重的字符串形式
+func sameContent(a, b *ScanIterator) error {
+	for {
+		na, nb := a.Next(), b.Next()
+		if na != nb {
+			break
+		}
+		if !na {
+			if err := a.Err(); err != nil {
+				return err
+			}
+			return b.Err()
+		}
+		if a.Key() != b.Key() {
+			break
+		}
+		if a.Value() != b.Value() {
+			break
+		}
+	}
+	if err := a.Err(); err != nil {
+		return err
+	}
+	if err := b.Err(); err != nil {
+		return err
+	}
+	return ErrReshardVerify
+}
@@ -0,0 +1,149 @@
+package gossdb_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+//  三个节点, 迁移前使用前两个
+func newReshardClients(t *testing.T) (from, to *ShardedClient) {
+	var shards []ShardConfig
+	for i := 0; i < 3; i++ {
+		_, pc := newTestNode(t)
+		shards = append(shards, ShardConfig{Name: fmt.Sprintf("s%d", i), PoolConfig: pc})
+	}
+	var err error
+	if from, err = NewShardedClient(&ShardedConfig{Shards: shards[:2]}); err != nil {
+		t.Fatal(err)
+	}
+	if to, err = NewShardedClient(&ShardedConfig{Shards: shards}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		from.Close()
+		to.Close()
+	})
+	return from, to
+}
+
+func fillReshardData(t *testing.T, c *ShardedClient) {
+	for i := 0; i < 20; i++ {
+		n := fmt.Sprintf("n%02d", i)
+		ttl := []int64{}
+		if i%2 == 0 {
+			ttl = append(ttl, 1000)
+		}
+		if err := c.Set(n, "v"+n, ttl...); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.MultiHSet(n, map[string]interface{}{"a": 1, "b": n}); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.MultiZSetFloat(n, map[string]float64{"x": 15, "y": -2}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.QPush(n, "q1", n, "q3"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+//  检查每个节点上只有属于它的数据, 并且数据完整
+func checkReshardData(t *testing.T, c *ShardedClient) {
+	for _, name := range c.Shards() {
+		node := c.Shard(name)
+		for _, list := range []func(string, string, int64) ([]string, error){node.Keys, node.HList, node.ZList, node.QList} {
+			names, err := list("", "", -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, n := range names {
+				if c.ShardOf(n) != name {
+					t.Fatalf("%s left on %s, belongs to %s", n, name, c.ShardOf(n))
+				}
+			}
+		}
+	}
+	for i := 0; i < 20; i++ {
+		n := fmt.Sprintf("n%02d", i)
+		if v, err := c.Get(n); err != nil || v != "v"+n {
+			t.Fatalf("Get(%s) = %q, %v", n, v, err)
+		}
+		ttl, err := c.Ttl(n)
+		if err != nil || (i%2 == 0) != (ttl > 0) {
+			t.Fatalf("Ttl(%s) = %d, %v", n, ttl, err)
+		}
+		if h, err := c.HGetAll(n); err != nil || !reflect.DeepEqual(h, map[string]string{"a": "1", "b": n}) {
+			t.Fatalf("HGetAll(%s) = %v, %v", n, h, err)
+		}
+		if s, err := c.ZGetFloat(n, "y"); err != nil || s != -2 {
+			t.Fatalf("ZGetFloat(%s) = %v, %v", n, s, err)
+		}
+		if q, err := c.QRange(n, 0, 10); err != nil || !reflect.DeepEqual(q, []string{"q1", n, "q3"}) {
+			t.Fatalf("QRange(%s) = %v, %v", n, q, err)
+		}
+	}
+}
+
+func TestReshard(t *testing.T) {
+	from, to := newReshardClients(t)
+	fillReshardData(t, from)
+
+	stats, err := Reshard(context.Background(), &ReshardConfig{From: from, To: to, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved := stats.Moved[ReshardKV]
+	if stats.Scanned[ReshardHash] != 20 || moved == 0 || moved == 20 {
+		t.Fatalf("dry run stats = %+v", stats)
+	}
+	if v, err := from.Get("n00"); err != nil || v != "vn00" {
+		t.Fatalf("dry run changed data: %q, %v", v, err)
+	}
+
+	stats, err = Reshard(context.Background(), &ReshardConfig{From: from, To: to, PageSize: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, kind := range reshardKinds {
+		if stats.Moved[kind] != moved {
+			t.Fatalf("moved %s = %d, want %d", kind, stats.Moved[kind], moved)
+		}
+	}
+	checkReshardData(t, to)
+}
+
+func TestReshardResume(t *testing.T) {
+	from, to := newReshardClients(t)
+	fillReshardData(t, from)
+	cp := filepath.Join(t.TempDir(), "reshard.json")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n := 0
+	_, err := Reshard(ctx, &ReshardConfig{From: from, To: to, Checkpoint: cp, PageSize: 2, Progress: func(ReshardMove) {
+		if n++; n == 5 {
+			cancel()
+		}
+	}})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v", err)
+	}
+
+	// 不同的目标分布不能使用这个检查点
+	if _, err = Reshard(context.Background(), &ReshardConfig{From: from, To: from, Checkpoint: cp}); err == nil {
+		t.Fatal("checkpoint of another layout accepted")
+	}
+
+	stats, err := Reshard(context.Background(), &ReshardConfig{From: from, To: to, Checkpoint: cp, PageSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Scanned[ReshardKV] == 20 {
+		t.Fatal("resume rescanned all keys")
+	}
+	checkReshardData(t, to)
+}
@@ -0,0 +1,253 @@
+package gossdb_client
+
+//  没有设置 ScanOptions.PageSize 时每页的元素个数
+const DefaultScanPageSize = 100
+
+//  遍历的选项, 为 nil 时遍历整个区间
+type ScanOptions struct {
+	// 遍历的区间 (Start, End], 按遍历的顺序, 反向遍历时 Start 是较大的一端. 空字符串表示不限制.
+	// 遍历 zset 时不使用, 区间由权重边界指定
+	Start, End string
+	// 反向遍历
+	Reverse bool
+	// 每次请求最多返回的元素个数, 0 表示 DefaultScanPageSize
+	PageSize int64
+	// 最多遍历的元素总数, 0 表示不限制
+	Limit int64
+}
+
+//  分页遍历 key、hashmap、zset 或名字列表的游标, 每次请求一页, 翻页对调用方透明.
+//  不能被多个 goroutine 同时使用. 用法:
+//
+//	it := db.HScanIter("user:1", nil)
+//	for it.Next() {
+//		fmt.Println(it.Key(), it.Value())
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type ScanIterator struct {
+	// 从 start 之后请求最多 n 个元素, 返回响应中 ok 之后的部分
+	fetch func(start string, score float64, n int64) ([]string, error)
+	// 每个元素在响应中占的字段数, key-value 和 key-score 为 2
+	stride  int
+	isScore bool
+
+	pageSize int64
+	limit    int64
+	count    int64
+
+	page []string
+	last bool // 当前页已经是最后一页
+	done bool
+	err  error
+
+	key   string
+	value string
+	score float64
+}
+
+func newScanIterator(opts *ScanOptions, stride int, fetch func(start string, score float64, n int64) ([]string, error)) *ScanIterator {
+	it := &ScanIterator{fetch: fetch, stride: stride, pageSize: DefaultScanPageSize}
+	if opts != nil {
+		if opts.PageSize > 0 {
+			it.pageSize = opts.PageSize
+		}
+		it.limit = opts.Limit
+	}
+	return it
+}
+
+//  前进到下一个元素, 遍历结束或出错时返回 false, 出错时 Err 返回错误
+func (it *ScanIterator) Next() bool {
+	if it.done {
+		return false
+	}
+	if it.limit > 0 && it.count >= it.limit {
+		it.done = true
+		return false
+	}
+	if len(it.page) == 0 {
+		if it.last {
+			it.done = true
+			return false
+		}
+		n := it.pageSize
+		if it.limit > 0 && it.limit-it.count < n {
+			n = it.limit - it.count
+		}
+		page, err := it.fetch(it.key, it.score, n)
+		if err != nil {
+			it.err = err
+			it.done = true
+			return false
+		}
+		if len(page) < it.stride {
+			it.done = true
+			return false
+		}
+		it.page = page
+		it.last = int64(len(page)/it.stride) < n
+	}
+
+	it.key = it.page[0]
+	if it.stride > 1 {
+		it.value = it.page[1]
+		if it.isScore {
+			score, err := parseScore(it.value, it.key)
+			if err != nil {
+				it.err = err
+				it.done = true
+				return false
+			}
+			it.score = score
+		}
+	}
+	it.page = it.page[it.stride:]
+	it.count++
+	return true
+}
+
+//  当前元素的 key, 遍历名字列表时为名字
+func (it *ScanIterator) Key() string {
+	return it.key
+}
+
+//  当前元素的值, 遍历 zset 时为权重的字符串形式, 只遍历 key 时为空
+func (it *ScanIterator) Value() string {
+	return it.value
+}
+
+//  当前元素的权重, 只在遍历 zset 时有效
+func (it *ScanIterator) Score() float64 {
+	return it.score
+}
+
+//  遍历中遇到的错误, 正常结束时返回 nil
+func (it *ScanIterator) Err() error {
+	return it.err
+}
+
+//  提前结束遍历, 之后 Next 返回 false
+func (it *ScanIterator) Close() {
+	it.done = true
+	it.page = nil
+}
+
+//  对区间的遍历, cmd 和 rcmd 分别为正向和反向的命令, prefix 为区间参数之前的参数
+func (c *DbClient) rangeIter(cmd, rcmd string, prefix []interface{}, stride int, opts *ScanOptions) *ScanIterator {
+	var o ScanOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Reverse {
+		cmd = rcmd
+	}
+	first := true
+	return newScanIterator(opts, stride, func(start string, _ float64, n int64) ([]string, error) {
+		if first {
+			start = o.Start
+			first = false
+		}
+		args := append(append([]interface{}{cmd}, prefix...), start, o.End, n)
+		resp, err := c.do(args...)
+		if err != nil {
+			return nil, err
+		}
+		return resp[1:], nil
+	})
+}
+
+//  遍历处于区间 (Start, End] 的 key, 参见 Keys
+func (c *DbClient) KeysIter(opts *ScanOptions) *ScanIterator {
+	return c.rangeIter("keys", "rkeys", nil, 1, opts)
+}
+
+//  遍历处于区间 (Start, End] 的 key-value, 参见 Scan
+func (c *DbClient) ScanIter(opts *ScanOptions) *ScanIterator {
+	return c.rangeIter("scan", "rscan", nil, 2, opts)
+}
+
+//  遍历 hashmap 中处于区间 (Start, End] 的 key-value, 参见 HScan
+func (c *DbClient) HScanIter(setName string, opts *ScanOptions) *ScanIterator {
+	return c.rangeIter("hscan", "hrscan", []interface{}{setName}, 2, opts)
+}
+
+//  遍历 hashmap 中处于区间 (Start, End] 的 key, 参见 HKeys.
+//  ssdb 没有反向的 hkeys, 反向遍历时使用 hrscan 并丢弃值
+func (c *DbClient) HKeysIter(setName string, opts *ScanOptions) *ScanIterator {
+	if opts != nil && opts.Reverse {
+		it := c.HScanIter(setName, opts)
+		it.fetch = keysOnly(it.fetch)
+		it.stride = 1
+		return it
+	}
+	return c.rangeIter("hkeys", "hkeys", []interface{}{setName}, 1, opts)
+}
+
+//  遍历名字处于区间 (Start, End] 的 hashmap, 参见 HList
+func (c *DbClient) HListIter(opts *ScanOptions) *ScanIterator {
+	return c.rangeIter("hlist", "hrlist", nil, 1, opts)
+}
+
+//  遍历名字处于区间 (Start, End] 的 zset, 参见 ZList
+func (c *DbClient) ZListIter(opts *ScanOptions) *ScanIterator {
+	return c.rangeIter("zlist", "zrlist", nil, 1, opts)
+}
+
+//  遍历名字处于区间 (Start, End] 的队列, 参见 QList
+func (c *DbClient) QListIter(opts *ScanOptions) *ScanIterator {
+	return c.rangeIter("qlist", "qrlist", nil, 1, opts)
+}
+
+//  遍历 zset 中权重处于 min 和 max 之间的 key-score, 按权重排序, 权重相同时按 key 排序.
+//  游标同时记录上一页最后一个元素的 key 和权重, 权重相同的元素跨页时不会重复或遗漏.
+//  opts.Reverse 为 true 时从 max 到 min 遍历, opts.Start 和 opts.End 不使用
+func (c *DbClient) ZScanIter(setName string, min, max ScoreBound, opts *ScanOptions) *ScanIterator {
+	cmd, lo, hi, inward := "zscan", min, max, 1.0
+	if opts != nil && opts.Reverse {
+		cmd, lo, hi, inward = "zrscan", max, min, -1
+	}
+	scoreStart, scoreEnd, boundErr := scoreBounds(lo, hi, inward)
+	first := true
+	it := newScanIterator(opts, 2, func(key string, score float64, n int64) ([]string, error) {
+		if boundErr != nil {
+			return nil, newCommandError([]interface{}{cmd, setName, key, lo, hi, n}, boundErr)
+		}
+		start := scoreStart
+		if first {
+			first = false
+		} else {
+			start = formatScore(score)
+		}
+		resp, err := c.do(cmd, setName, key, start, scoreEnd, n)
+		if err != nil {
+			return nil, err
+		}
+		return resp[1:], nil
+	})
+	it.isScore = true
+	return it
+}
+
+//  遍历 zset 中权重处于 min 和 max 之间的 key, 参见 ZScanIter.
+//  zkeys 不返回权重, 无法作为游标, 因此同样使用 zscan, Value 和 Score 仍然有效
+func (c *DbClient) ZKeysIter(setName string, min, max ScoreBound, opts *ScanOptions) *ScanIterator {
+	return c.ZScanIter(setName, min, max, opts)
+}
+
+//  把 key-value 的分页请求转换为只有 key 的分页请求
+func keysOnly(fetch func(string, float64, int64) ([]string, error)) func(string, float64, int64) ([]string, error) {
+	return func(start string, score float64, n int64) ([]string, error) {
+		page, err := fetch(start, score, n)
+		if err != nil {
+			return nil, err
+		}
+		keys := make([]string, 0, len(page)/2)
+		for i := 0; i+1 < len(page); i += 2 {
+			keys = append(keys, page[i])
+		}
+		return keys, nil
+	}
+}
+
@@ -0,0 +1,104 @@
+package gossdb_client
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func collectKeys(t *testing.T, it *ScanIterator) []string {
+	t.Helper()
+	keys := []string{}
+	for it.Next() {
+		keys = append(keys, it.Key())
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestScanIter(t *testing.T) {
+	db, _ := newTestClient(t)
+
+	kvs := map[string]interface{}{}
+	var all []string
+	for i := 0; i < 25; i++ {
+		k := fmt.Sprintf("k%02d", i)
+		kvs[k] = i
+		all = append(all, k)
+	}
+	if err := db.MultiSet(kvs); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.MultiHSet("h", kvs); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := collectKeys(t, db.KeysIter(&ScanOptions{PageSize: 4})); !reflect.DeepEqual(got, all) {
+		t.Fatalf("KeysIter = %v", got)
+	}
+	got := collectKeys(t, db.HKeysIter("h", &ScanOptions{PageSize: 3, Reverse: true, Start: "k20", Limit: 5}))
+	if !reflect.DeepEqual(got, []string{"k19", "k18", "k17", "k16", "k15"}) {
+		t.Fatalf("HKeysIter reverse = %v", got)
+	}
+
+	it := db.HScanIter("h", &ScanOptions{PageSize: 10})
+	n := 0
+	for it.Next() {
+		if it.Value() != fmt.Sprint(n) {
+			t.Fatalf("HScanIter %s = %s", it.Key(), it.Value())
+		}
+		if n++; n == 12 {
+			it.Close()
+		}
+	}
+	if n != 12 || it.Err() != nil {
+		t.Fatalf("HScanIter stopped after %d, %v", n, it.Err())
+	}
+
+	if got := collectKeys(t, db.HListIter(nil)); !reflect.DeepEqual(got, []string{"h"}) {
+		t.Fatalf("HListIter = %v", got)
+	}
+}
+
+func TestZScanIter(t *testing.T) {
+	db, _ := newTestClient(t)
+
+	// 大量相同的权重, 翻页时游标必须同时记录 key 和权重
+	kvs := map[string]float64{}
+	var want []string
+	for i := 0; i < 10; i++ {
+		k := fmt.Sprintf("m%d", i)
+		kvs[k] = float64(i / 4)
+		want = append(want, k)
+	}
+	if err := db.MultiZSetFloat("z", kvs); err != nil {
+		t.Fatal(err)
+	}
+	if got := collectKeys(t, db.ZScanIter("z", NegInf, PosInf, &ScanOptions{PageSize: 3})); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ZScanIter = %v", got)
+	}
+
+	it := db.ZScanIter("z", Exclusive(0), Inclusive(1), &ScanOptions{PageSize: 2, Reverse: true})
+	var scores []float64
+	got := []string{}
+	for it.Next() {
+		got = append(got, it.Key())
+		scores = append(scores, it.Score())
+	}
+	if !reflect.DeepEqual(got, []string{"m7", "m6", "m5", "m4"}) || !reflect.DeepEqual(scores, []float64{1, 1, 1, 1}) {
+		t.Fatalf("ZScanIter reverse = %v %v, %v", got, scores, it.Err())
+	}
+
+	db.CloseDbClient()
+	it = db.ZKeysIter("z", NegInf, PosInf, nil)
+	if it.Next() || it.Err() == nil {
+		t.Fatal("ZKeysIter on closed client should fail")
+	}
+	var ce *CommandError
+	if !errors.As(it.Err(), &ce) || ce.Cmd != "zscan" {
+		t.Fatalf("ZKeysIter err = %#v", it.Err())
+	}
+}
@@ -1,25 +1,24 @@
 package gossdb_client
 
 import (
-	"fmt"
 	"strconv"
 	"github.com/houbin910902/to"
 )
 
 //  设置指定 key 的值内容
 //  key 键值
-//  val 存贮的value值, val只支持基本的类型, 如果要支持复杂的类型, 需要开启连接池的Encoding选项
+//  val 存贮的value值, val只支持基本的类型, 复杂的类型请使用 SetObject
 //  ttl 可选, 设置的过期时间, 单位为秒
 //  返回err, 可能的错误, 操作成功返回nil
 func (c *DbClient) Set(key string, val interface{}, ttl ...int64) (err error) {
 	var resp []string
 	if len(ttl) > 0 {
-		resp, err = c.Client.Do("setx", key, val, ttl[0])
+		resp, err = c.do("setx", key, val, ttl[0])
 	} else {
-		resp, err = c.Client.Do("set", key, val)
+		resp, err = c.do("set", key, val)
 	}
 	if err != nil {
-		return fmt.Errorf("set %s error: %s", key, err.Error())
+		return err
 	}
 	if len(resp) > 0 && resp[0] == "ok" {
 		return nil
@@ -29,14 +28,14 @@ func (c *DbClient) Set(key string, val interface{}, ttl ...int64) (err error) {
 
 //  当key不存在时, 设置指定key的值内容. 如果已存在, 则不设置.
 //  key 键值
-//  val 存贮的value值, val只支持基本的类型, 如果要支持复杂的类型, 需要开启连接池的Encoding选项
+//  val 存贮的value值, val只支持基本的类型, 复杂的类型请使用 SetObject
 //  返回 err, 可能的错误, 操作成功返回nil
 //  返回 val 1: value 已经设置, 0: key 已经存在, 不更新.
 func (c *DbClient) SetNx(key string, val interface{}) (string, error) {
-	resp, err := c.Client.Do("setnx", key, val)
+	resp, err := c.do("setnx", key, val)
 
 	if err != nil {
-		return "", fmt.Errorf("SetNx %s error: %s", key, err.Error())
+		return "", err
 	}
 	if len(resp) > 0 && resp[0] == "ok" {
 		return string(resp[1]), nil
@@ -46,12 +45,12 @@ func (c *DbClient) SetNx(key string, val interface{}) (string, error) {
 
 //  获取指定key的值内容
 //  key 键值
-//  返回 一个 Value,可以方便的向其它类型转换
+//  返回 一个 Value,可以方便的向其它类型转换, key 不存在时返回 ErrNotFound
 //  返回 一个可能的错误，操作成功返回 nil
 func (c *DbClient) Get(key string) (string, error) {
-	resp, err := c.Client.Do("get", key)
+	resp, err := c.do("get", key)
 	if err != nil {
-		return "", fmt.Errorf("%s Get %s error", err.Error(), key)
+		return "", err
 	}
 	if len(resp) == 2 && resp[0] == "ok" {
 		return string(resp[1]), nil
@@ -59,16 +58,32 @@ func (c *DbClient) Get(key string) (string, error) {
 	return "", handError(resp, key)
 }
 
+//  获取指定key的值内容, 以 []byte 返回, 不复制接收缓冲区, 适合较大的二进制值
+//  返回的切片只在同一个 client 执行下一条命令之前有效, 需要保留时请自行复制
+//  key 键值
+//  返回 key 的值内容, key 不存在时返回 ErrNotFound
+//  返回 一个可能的错误，操作成功返回 nil
+func (c *DbClient) GetBytes(key string) ([]byte, error) {
+	resp, err := c.doBytes("get", key)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) == 2 {
+		return resp[1], nil
+	}
+	return nil, handErrorBytes(resp, key)
+}
+
 
 //  更新key对应的value, 并返回更新前的旧的 value.
 //  key 键值
-//  val 存贮的value值, val只支持基本的类型, 如果要支持复杂的类型, 需要开启连接池的Encoding选项
+//  val 存贮的value值, val只支持基本的类型, 复杂的类型请使用 SetObject
 //  返回 一个 Value, 可以方便的向其它类型转换. 如果key不存在则返回"", 否则返回key对应的值内容.
 //  返回 一个可能的错误，操作成功返回 nil
 func (c *DbClient) GetSet(key string, val interface{}) (string, error) {
-	resp, err := c.Client.Do("getset", key, val)
+	resp, err := c.do("getset", key, val)
 	if err != nil {
-		return "", fmt.Errorf("GetSet %s error: %s", key, err.Error())
+		return "", err
 	}
 	if len(resp) == 2 && resp[0] == "ok" {
 		return string(resp[1]), nil
@@ -82,9 +97,9 @@ func (c *DbClient) GetSet(key string, val interface{}) (string, error) {
 //  返回 re, 设置是否成功，如果当前 key 不存在返回 false
 //  返回 err，执行的错误，操作成功返回 nil
 func (c *DbClient) Expire(key string, ttl int64) (re bool, err error) {
-	resp, err := c.Client.Do("expire", key, ttl)
+	resp, err := c.do("expire", key, ttl)
 	if err != nil {
-		return false, fmt.Errorf("expire %s error: %s", key, err.Error())
+		return false, err
 	}
 	if len(resp) == 2 && resp[0] == "ok" {
 		return resp[1] == "1", nil
@@ -97,9 +112,9 @@ func (c *DbClient) Expire(key string, ttl int64) (re bool, err error) {
 //  返回 re，如果当前 key 不存在返回 false
 //  返回 err，执行的错误，操作成功返回 nil
 func (c *DbClient) Exists(key string) (re bool, err error) {
-	resp, err := c.Client.Do("exists", key)
+	resp, err := c.do("exists", key)
 	if err != nil {
-		return false, fmt.Errorf("exists %s error: %s", key, err.Error())
+		return false, err
 	}
 	if len(resp) == 2 && resp[0] == "ok" {
 		return resp[1] == "1", nil
@@ -111,9 +126,9 @@ func (c *DbClient) Exists(key string) (re bool, err error) {
 //  key 要删除的 key
 //  返回 err，执行的错误，操作成功返回 nil
 func (c *DbClient) Del(key string) error {
-	resp, err := c.Client.Do("del", key)
+	resp, err := c.do("del", key)
 	if err != nil {
-		return fmt.Errorf("del %s error: %s", key, err.Error())
+		return err
 	}
 	//response looks like s: [ok 1]
 	if len(resp) > 0 && resp[0] == "ok" {
@@ -128,9 +143,9 @@ func (c *DbClient) Del(key string) error {
 //  返回 ttl, key 的存活时间(秒), -1 表示没有设置存活时间.
 //  返回 err，执行的错误，操作成功返回 nil
 func (c *DbClient) Ttl(key string) (ttl int64, err error) {
-	resp, err := c.Client.Do("ttl", key)
+	resp, err := c.do("ttl", key)
 	if err != nil {
-		return -1, fmt.Errorf("ttl %s error: %s", key, err.Error())
+		return -1, err
 	}
 	//response looks like s: [ok 1]
 	if len(resp) > 0 && resp[0] == "ok" {
@@ -146,10 +161,10 @@ func (c *DbClient) Ttl(key string) (ttl int64, err error) {
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) IncR(key string, num int64) (val int64, err error) {
 
-	resp, err := c.Client.Do("incr", key, num)
+	resp, err := c.do("incr", key, num)
 
 	if err != nil {
-		return -1, fmt.Errorf("incr %s error: %s", key, err.Error())
+		return -1, err
 	}
 	if len(resp) == 2 && resp[0] == "ok" {
 		return strconv.ParseInt(resp[1], 10, 64)
@@ -168,10 +183,10 @@ func (c *DbClient) MultiSet(kvs map[string]interface{}) (err error) {
 		args = append(args, k)
 		args = append(args, v)
 	}
-	resp, err := c.Client.Do("multi_set", args)
+	resp, err := c.do("multi_set", args)
 
 	if err != nil {
-		return fmt.Errorf("MultiSet %s error: %s", kvs, err.Error())
+		return err
 	}
 
 	if len(resp) > 0 && resp[0] == "ok" {
@@ -188,10 +203,10 @@ func (c *DbClient) MultiGet(key ...string) (val map[string]string, err error) {
 	if len(key) == 0 {
 		return make(map[string]string), nil
 	}
-	resp, err := c.Client.Do("multi_get", key)
+	resp, err := c.do("multi_get", key)
 
 	if err != nil {
-		return nil, fmt.Errorf("MultiGet %s error: %s", key, err.Error())
+		return nil, err
 	}
 
 	size := len(resp)
@@ -205,6 +220,29 @@ func (c *DbClient) MultiGet(key ...string) (val map[string]string, err error) {
 	return nil, handError(resp, key)
 }
 
+//  批量获取一批 key 对应的值内容, 以 []byte 返回, 不复制接收缓冲区.
+//  返回的切片只在同一个 client 执行下一条命令之前有效, 需要保留时请自行复制
+//  key, 要获取的 key，可以为多个
+//  返回 keys和values分片, 按服务端返回的顺序一一对应, 不存在的 key 不会出现在结果中
+//  返回 err, 可能的错误, 操作成功返回 nil
+func (c *DbClient) MultiGetBytes(key ...string) (keys [][]byte, values [][]byte, err error) {
+	if len(key) == 0 {
+		return [][]byte{}, [][]byte{}, nil
+	}
+	resp, err := c.doBytes("multi_get", key)
+	if err != nil {
+		return nil, nil, err
+	}
+	size := len(resp)
+	keys = make([][]byte, 0, (size-1)/2)
+	values = make([][]byte, 0, (size-1)/2)
+	for i := 1; i+1 < size; i += 2 {
+		keys = append(keys, resp[i])
+		values = append(values, resp[i+1])
+	}
+	return keys, values, nil
+}
+
 //  批量获取一批 key 对应的值内容.
 //  key, 要获取的 key，可以为多个
 //  返回 keys和value分片
@@ -213,10 +251,10 @@ func (c *DbClient) MultiGetSlice(key ...string) (keys []string, values []string,
 	if len(key) == 0 {
 		return []string{}, []string{}, nil
 	}
-	resp, err := c.Client.Do("multi_get", key)
+	resp, err := c.do("multi_get", key)
 
 	if err != nil {
-		return nil, nil, fmt.Errorf("MultiGet %s error: %s", key, err.Error())
+		return nil, nil, err
 	}
 
 	size := len(resp)
@@ -242,10 +280,10 @@ func (c *DbClient) MultiGetArray(key []string) (val map[string]string, err error
 	if len(key) == 0 {
 		return make(map[string]string), nil
 	}
-	resp, err := c.Client.Do("multi_get", key)
+	resp, err := c.do("multi_get", key)
 
 	if err != nil {
-		return nil, fmt.Errorf("MultiGet %s error: %s", key, err.Error())
+		return nil, err
 	}
 
 	size := len(resp)
@@ -267,10 +305,10 @@ func (c *DbClient) MultiGetArraySlice(key []string) (keys []string, values []str
 	if len(key) == 0 {
 		return []string{}, []string{}, nil
 	}
-	resp, err := c.Client.Do("multi_get", key)
+	resp, err := c.do("multi_get", key)
 
 	if err != nil {
-		return nil, nil, fmt.Errorf("MultiGet %s error: %s", key, err.Error())
+		return nil, nil, err
 	}
 
 	size := len(resp)
@@ -296,10 +334,10 @@ func (c *DbClient) MultiDel(key ...string) (err error) {
 	if len(key) == 0 {
 		return nil
 	}
-	resp, err := c.Client.Do("multi_del", key)
+	resp, err := c.do("multi_del", key)
 
 	if err != nil {
-		return fmt.Errorf("MultiDel %s error: %s", key, err.Error())
+		return err
 	}
 
 	if len(resp) > 0 && resp[0] == "ok" {
@@ -316,10 +354,10 @@ func (c *DbClient) MultiDel(key ...string) (err error) {
 //  返回 err, 可能的错误, 操作成功返回 nil
 func (c *DbClient) SetBit(key string, offset int64, bit byte) (byte, error) {
 
-	resp, err := c.Client.Do("setbit", key, offset, bit)
+	resp, err := c.do("setbit", key, offset, int(bit))
 
 	if err != nil {
-		return 255, fmt.Errorf("%s SetBit %s error", err, key)
+		return 255, err
 	}
 	if len(resp) == 2 && resp[0] == "ok" {
 		return byte(int8(to.Int64(resp[1]))), nil
@@ -334,9 +372,9 @@ func (c *DbClient) SetBit(key string, offset int64, bit byte) (byte, error) {
 //  返回 val，位值
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) GetBit(key string, offset int64) (byte, error) {
-	resp, err := c.Client.Do("getbit", key, offset)
+	resp, err := c.do("getbit", key, offset)
 	if err != nil {
-		return 255, fmt.Errorf("GetBit %s error: %s", key, err.Error())
+		return 255, err
 	}
 	if len(resp) == 2 && resp[0] == "ok" {
 		return byte(int8(to.Int64(resp[1]))), nil
@@ -354,13 +392,13 @@ func (c *DbClient) GetBit(key string, offset int64) (byte, error) {
 func (c *DbClient) Substr(key string, start int64, size ...int64) (val string, err error) {
 	var resp []string
 	if len(size) > 0 {
-		resp, err = c.Client.Do("substr", key, start, size[0])
+		resp, err = c.do("substr", key, start, size[0])
 	} else {
-		resp, err = c.Client.Do("substr", key, start)
+		resp, err = c.do("substr", key, start)
 	}
 
 	if err != nil {
-		return "", fmt.Errorf("substr %s error: %s", key, err.Error())
+		return "", err
 	}
 	if len(resp) > 1 && resp[0] == "ok" {
 		return resp[1], nil
@@ -374,9 +412,9 @@ func (c *DbClient) Substr(key string, start int64, size ...int64) (val string, e
 //  返回 字符串的长度, key 不存在则返回 0.
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) StrLen(key string) (int64, error) {
-	resp, err := c.Client.Do("strlen", key)
+	resp, err := c.do("strlen", key)
 	if err != nil {
-		return -1, fmt.Errorf("strlen %s error: %s", key, err.Error())
+		return -1, err
 	}
 	if len(resp) > 1 && resp[0] == "ok" {
 		return strconv.ParseInt(resp[1], 10, 64)
@@ -392,10 +430,10 @@ func (c *DbClient) StrLen(key string) (int64, error) {
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) Keys(keyStart, keyEnd string, limit int64) ([]string, error) {
 
-	resp, err := c.Client.Do("keys", keyStart, keyEnd, limit)
+	resp, err := c.do("keys", keyStart, keyEnd, limit)
 
 	if err != nil {
-		return nil, fmt.Errorf("keys %s %s %v error: %s", keyStart, keyEnd, limit, err.Error())
+		return nil, err
 	}
 	if len(resp) > 0 && resp[0] == "ok" {
 		return resp[1:], nil
@@ -411,9 +449,9 @@ func (c *DbClient) Keys(keyStart, keyEnd string, limit int64) ([]string, error)
 //  返回 返回包含 key 的数组.
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) RKeys(keyStart, keyEnd string, limit int64) ([]string, error) {
-	resp, err := c.Client.Do("rkeys", keyStart, keyEnd, limit)
+	resp, err := c.do("rkeys", keyStart, keyEnd, limit)
 	if err != nil {
-		return nil, fmt.Errorf("rkeys %s %s %v error: %s", keyStart, keyEnd, limit, err.Error())
+		return nil, err
 	}
 	if len(resp) > 0 && resp[0] == "ok" {
 		return resp[1:], nil
@@ -429,10 +467,10 @@ func (c *DbClient) RKeys(keyStart, keyEnd string, limit int64) ([]string, error)
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) Scan(keyStart, keyEnd string, limit int64) (map[string]string, error) {
 
-	resp, err := c.Client.Do("scan", keyStart, keyEnd, limit)
+	resp, err := c.do("scan", keyStart, keyEnd, limit)
 
 	if err != nil {
-		return nil, fmt.Errorf("scan %s %s %v error: %s", keyStart, keyEnd, limit, err.Error())
+		return nil, err
 	}
 	if len(resp) > 0 && resp[0] == "ok" {
 		re := make(map[string]string)
@@ -455,10 +493,10 @@ func (c *DbClient) Scan(keyStart, keyEnd string, limit int64) (map[string]string
 //  返回 err, 可能的错误, 操作成功返回 nil
 func (c *DbClient) RScan(keyStart, keyEnd string, limit int64) (map[string]string, error) {
 
-	resp, err := c.Client.Do("rscan", keyStart, keyEnd, limit)
+	resp, err := c.do("rscan", keyStart, keyEnd, limit)
 
 	if err != nil {
-		return nil, fmt.Errorf("rscan %s %s %v error: %s", keyStart, keyEnd, limit, err.Error())
+		return nil, err
 	}
 	if len(resp) > 0 && resp[0] == "ok" {
 		re := make(map[string]string)
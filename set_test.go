@@ -1,62 +1,236 @@
 package gossdb_client
 
 import (
+	"context"
+	"errors"
 	"testing"
-	"fmt"
 	"time"
+
+	"github.com/houbin910902/gossdb_client/gossdb/ssdb"
+	"github.com/houbin910902/gossdb_client/ssdbtest"
 )
 
-func TestSet(t *testing.T) {
-	db, err:= NewDbClient("127.0.0.1", 8888, "11111111111111111111111111111111")
-	if err != nil{
+const testPassword = "11111111111111111111111111111111"
+
+func newTestClient(t *testing.T) (*DbClient, *ssdbtest.Server) {
+	s := ssdbtest.NewServerWithAuth(testPassword)
+	t.Cleanup(s.Close)
+	db, err := NewDbClient(s.Host(), s.Port(), testPassword)
+	if err != nil {
 		t.Fatalf("NewDbClient fail err: %s", err.Error())
 	}
-	defer db.CloseDbClient()
-	//aRet, err := db.Auth("")
-	//if err != nil{
-	//	t.Fatalf("Auth fail err: %s", err)
-	//}
-	//fmt.Println("Auth ret: ", aRet)
-
-	// 开始测试set
+	t.Cleanup(func() { db.CloseDbClient() })
+	return db, s
+}
 
-	time.Sleep(time.Second*120)
+func TestSet(t *testing.T) {
+	db, s := newTestClient(t)
 
-	err = db.Set("a", "123456")
-	if err != nil{
+	err := db.Set("a", "123456")
+	if err != nil {
 		t.Fatalf("Set fail. err: %s", err.Error())
 	}
 	Value, err := db.Get("a")
-	if err != nil{
+	if err != nil {
 		t.Fatalf("Get fail. err: %s", err.Error())
 	}
 	if Value != "123456" {
 		t.Fatalf("Get fail. Values is %s", Value)
 	}
-	fmt.Printf("Value = %v", Value)
-}
 
-func TestAuth(t *testing.T)  {
-	db, err := NewDbClient("127.0.0.1", 8888, "11111111111111111111111111111111")
-	if err != nil{
-		t.Fatalf("NewDbClient fail err: %s", err.Error())
+	// 过期时间
+	now := time.Now()
+	s.Now = func() time.Time { return now }
+	if err = db.Set("b", "1", 10); err != nil {
+		t.Fatalf("Set ttl fail. err: %s", err.Error())
+	}
+	if ttl, _ := db.Ttl("b"); ttl != 10 {
+		t.Fatalf("Ttl = %d, want 10", ttl)
+	}
+	s.Now = func() time.Time { return now.Add(11 * time.Second) }
+	if re, _ := db.Exists("b"); re {
+		t.Fatalf("b should expire")
 	}
-	//aRet, err := db.Auth("11111111111111111111111111111111")
-	//if err != nil{
-	//	t.Fatalf("Auth fail err: %s", err)
-	//}
-	//fmt.Println("Auth ret: ", aRet)
 
+	n, err := db.IncR("n", 5)
+	if err != nil || n != 5 {
+		t.Fatalf("IncR = %d, %v", n, err)
+	}
+}
 
-	// 开始测试set
-	err = db.Set("a", "123456")
+func TestAuth(t *testing.T) {
+	db, s := newTestClient(t)
+
+	err := db.Set("a", "123456")
 	if err != nil {
 		t.Fatalf("Set fail. err: %s", err.Error())
 	}
-	mapRet, err := db.MultiGetArray([]string{"a", "b"})
-	if err!= nil{
-		t.Fatalf("")
+	if err = db.MultiSet(map[string]interface{}{"b": 1, "c": "x"}); err != nil {
+		t.Fatalf("MultiSet fail. err: %s", err.Error())
+	}
+	mapRet, err := db.MultiGetArray([]string{"a", "b", "d"})
+	if err != nil {
+		t.Fatalf("MultiGetArray fail. err: %s", err.Error())
+	}
+	if len(mapRet) != 2 || mapRet["a"] != "123456" || mapRet["b"] != "1" {
+		t.Fatalf("MultiGetArray = %v", mapRet)
 	}
-	fmt.Println("mapRet: ", mapRet)
 
+	_, err = NewDbClient(s.Host(), s.Port(), "wrong")
+	if !errors.Is(err, ErrAuth) {
+		t.Fatalf("NewDbClient with wrong password err = %v, want ErrAuth", err)
+	}
+}
+
+func TestGetNotFound(t *testing.T) {
+	db, _ := newTestClient(t)
+
+	_, err := db.Get("missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get err = %v, want ErrNotFound", err)
+	}
+	var ce *CommandError
+	if !errors.As(err, &ce) || ce.Cmd != "get" || ce.Code != "not_found" {
+		t.Fatalf("Get err = %#v", err)
+	}
+
+	if err = db.Set("empty", ""); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := db.Get("empty"); err != nil || v != "" {
+		t.Fatalf("Get empty = %q, %v", v, err)
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	db, _ := newTestClient(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := db.WithContext(ctx).Get("a"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Get err = %v, want context.Canceled", err)
+	}
+	// 发出之前就取消, 连接仍然可用
+	if err := db.Set("a", "1"); err != nil {
+		t.Fatalf("Set after cancel fail. err: %s", err.Error())
+	}
+	// 参数编码失败时连接仍然可用, ctx 的截止时间不会留在连接上
+	dctx, dcancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer dcancel()
+	if _, err := db.WithContext(dctx).do("set", "a", struct{}{}); !errors.Is(err, ssdb.ErrBadArguments) {
+		t.Fatalf("set err = %v, want ErrBadArguments", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := db.Set("a", "2"); err != nil || db.Client.IsBroken() {
+		t.Fatalf("Set after the deadline fail. err: %v", err)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	db, s := newTestClient(t)
+	db.SetReconnect(&ReconnectPolicy{MaxRetries: 2, MinBackoff: time.Millisecond})
+
+	if err := db.Set("a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	s.CloseClientConnections()
+
+	// get 是幂等命令, 重新连接并认证后重试
+	v, err := db.Get("a")
+	if err != nil || v != "1" {
+		t.Fatalf("Get after restart = %q, %v", v, err)
+	}
+}
+
+func TestGetBytes(t *testing.T) {
+	db, _ := newTestClient(t)
+
+	blob := []byte("\x00\n\r\n1\xff")
+	if err := db.Set("blob", blob); err != nil {
+		t.Fatal(err)
+	}
+	v, err := db.GetBytes("blob")
+	if err != nil || string(v) != string(blob) {
+		t.Fatalf("GetBytes = %q, %v", v, err)
+	}
+	if _, err = db.GetBytes("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetBytes err = %v, want ErrNotFound", err)
+	}
+
+	if err = db.HSet("h", "k", blob); err != nil {
+		t.Fatal(err)
+	}
+	if v, err = db.HGetBytes("h", "k"); err != nil || string(v) != string(blob) {
+		t.Fatalf("HGetBytes = %q, %v", v, err)
+	}
+
+	keys, values, err := db.MultiGetBytes("blob", "missing", "h")
+	if err != nil || len(keys) != 1 || string(keys[0]) != "blob" || string(values[0]) != string(blob) {
+		t.Fatalf("MultiGetBytes = %q, %q, %v", keys, values, err)
+	}
+	keys, values, err = db.MultiHGetBytes("h", "k")
+	if err != nil || len(keys) != 1 || string(values[0]) != string(blob) {
+		t.Fatalf("MultiHGetBytes = %q, %q, %v", keys, values, err)
+	}
+}
+
+func TestSetBit(t *testing.T) {
+	db, _ := newTestClient(t)
+
+	if old, err := db.SetBit("bits", 9, 1); err != nil || old != 0 {
+		t.Fatalf("SetBit = %d, %v", old, err)
+	}
+	if v, err := db.GetBit("bits", 9); err != nil || v != 1 {
+		t.Fatalf("GetBit = %d, %v", v, err)
+	}
+	// 参数无法编码时没有发出任何数据, 连接仍然可用
+	if _, err := db.Client.Do("set", "a", struct{}{}); !errors.Is(err, ssdb.ErrBadArguments) {
+		t.Fatalf("Do err = %v, want ErrBadArguments", err)
+	}
+	if db.Client.IsBroken() {
+		t.Fatal("connection broken by an encode error")
+	}
+	if err := db.Set("a", "1"); err != nil {
+		t.Fatalf("Set after encode error fail. err: %s", err.Error())
+	}
+}
+
+func TestContextInterruptsRead(t *testing.T) {
+	db, s := newTestClient(t)
+	s.SetLatency(time.Second)
+
+	// 超时打断阻塞的读, 连接之后不可用
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := db.WithContext(ctx).Get("a"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Get err = %v, want context.DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("Get returned after %v", d)
+	}
+	if !db.Client.IsBroken() {
+		t.Fatal("connection not marked broken after an interrupted read")
+	}
+	if _, err := db.Get("a"); !errors.Is(err, ssdb.ErrBroken) {
+		t.Fatalf("Get on broken connection err = %v, want ErrBroken", err)
+	}
+
+	// 取消同样打断阻塞的读
+	db2, err := NewDbClient(s.Host(), s.Port(), testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db2.CloseDbClient()
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	p := db2.WithContext(ctx).Pipeline()
+	p.Get("a")
+	p.Get("b")
+	if _, err = p.Exec(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Exec err = %v, want context.Canceled", err)
+	}
+	if !db2.Client.IsBroken() {
+		t.Fatal("connection not marked broken after a cancelled pipeline")
+	}
 }
@@ -0,0 +1,454 @@
+package gossdb_client
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//  没有设置 ShardedConfig.VirtualNodes 时每个权重单位的虚拟节点数
+const DefaultVirtualNodes = 160
+
+//  一个分片
+type ShardConfig struct {
+	// 分片在哈希环上的名字, 为空时使用 Ip:Port. 名字决定了 key 的分布, 更换机器时保持名字不变可以避免数据迁移
+	Name string
+	// 分片的连接池配置
+	PoolConfig
+	// 权重, 虚拟节点数与权重成正比, 默认 1
+	Weight int
+}
+
+//  分片的配置
+type ShardedConfig struct {
+	Shards []ShardConfig
+	// 每个权重单位的虚拟节点数, 默认 DefaultVirtualNodes
+	VirtualNodes int
+}
+
+//  按一致性哈希把数据分布到多个 ssdb 实例的 client, 拥有 DbClient 的全部方法, 可以被多个 goroutine 同时使用.
+//
+//  单个 key 的命令以及 hashmap、zset、队列的命令按 key 或名字路由到一个分片, 同一个容器的数据总在同一个分片上.
+//  key 中包含 {tag} 时只用 tag 计算分片, 例如 user:{42}:profile 和 user:{42}:follows 总在同一个分片上.
+//  multi_get、multi_set、multi_del 按 key 拆分到各个分片并行执行后合并结果.
+//  keys、scan、hlist、zlist、qlist 等列表命令在所有分片上执行, 结果归并排序后截取 limit 个.
+//  dbsize 返回所有分片的和, 其它没有 key 的命令(ping、flushdb 等)以及 Admin 的 ip 管理命令在所有分片上执行, 返回第一个分片的结果.
+//  Admin 的 key 区间命令只对单个实例有意义, 应当通过 Shard 获取分片后执行
+type ShardedClient struct {
+	DbClient
+	ring *ring
+}
+
+type shard struct {
+	name string
+	pool *Pool
+}
+
+//  一致性哈希环
+type ring struct {
+	shards []*shard
+	points []ringPoint // 按 hash 排序
+}
+
+type ringPoint struct {
+	hash  uint32
+	shard *shard
+}
+
+//  创建分片 client, 为每个分片创建一个连接池
+//  conf 配置
+//  返回 err，可能的错误，操作成功返回 nil
+func NewShardedClient(conf *ShardedConfig) (*ShardedClient, error) {
+	if len(conf.Shards) == 0 {
+		return nil, fmt.Errorf("gossdb_client: no shards")
+	}
+	vnodes := conf.VirtualNodes
+	if vnodes <= 0 {
+		vnodes = DefaultVirtualNodes
+	}
+	r := &ring{}
+	names := make(map[string]bool)
+	for i := range conf.Shards {
+		sc := conf.Shards[i]
+		if sc.Name == "" {
+			sc.Name = poolAddr(&sc.PoolConfig)
+		}
+		if names[sc.Name] {
+			r.close()
+			return nil, fmt.Errorf("gossdb_client: duplicate shard name %s", sc.Name)
+		}
+		names[sc.Name] = true
+		p, err := NewPool(&sc.PoolConfig)
+		if err != nil {
+			r.close()
+			return nil, err
+		}
+		s := &shard{name: sc.Name, pool: p}
+		r.shards = append(r.shards, s)
+
+		weight := sc.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		for j := 0; j < vnodes*weight; j++ {
+			h := crc32.ChecksumIEEE([]byte(sc.Name + "#" + strconv.Itoa(j)))
+			r.points = append(r.points, ringPoint{h, s})
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i].hash < r.points[j].hash })
+	return &ShardedClient{DbClient: DbClient{ex: r, codec: conf.Shards[0].Codec}, ring: r}, nil
+}
+
+//  返回一个绑定了 ctx 的 ShardedClient 副本, 参见 DbClient.WithContext
+func (c *ShardedClient) WithContext(ctx context.Context) *ShardedClient {
+	return &ShardedClient{*c.DbClient.WithContext(ctx), c.ring}
+}
+
+//  返回 key 或容器名字所在分片的名字
+func (c *ShardedClient) ShardOf(key string) string {
+	return c.ring.locate(key).name
+}
+
+//  返回所有分片的名字, 按配置的顺序
+func (c *ShardedClient) Shards() []string {
+	names := make([]string, len(c.ring.shards))
+	for i, s := range c.ring.shards {
+		names[i] = s.name
+	}
+	return names
+}
+
+//  返回直接访问一个分片的 client, 命令不经过路由. 分片不存在时返回 nil
+func (c *ShardedClient) Shard(name string) *PooledClient {
+	for _, s := range c.ring.shards {
+		if s.name == name {
+			return s.pool.Client().WithContext(c.Context())
+		}
+	}
+	return nil
+}
+
+//  关闭所有分片的连接池
+func (c *ShardedClient) Close() error {
+	return c.ring.close()
+}
+
+func (r *ring) close() error {
+	var err error
+	for _, s := range r.shards {
+		if e := s.pool.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+//  返回 key 所在的分片: 哈希环上顺时针方向的第一个虚拟节点
+func (r *ring) locate(key string) *shard {
+	h := crc32.ChecksumIEEE([]byte(hashTag(key)))
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].shard
+}
+
+//  返回 key 中用于计算分片的部分: key 中第一个 { 与其后第一个 } 之间的内容不为空时只使用这部分, 否则使用整个 key
+func hashTag(key string) string {
+	if i := strings.IndexByte(key, '{'); i >= 0 {
+		if j := strings.IndexByte(key[i+1:], '}'); j > 0 {
+			return key[i+1 : i+1+j]
+		}
+	}
+	return key
+}
+
+//  命令的第一个参数, 即 key 或容器的名字
+func routeKey(args []interface{}) (string, bool) {
+	if len(args) < 2 {
+		return "", false
+	}
+	return keyString(args[1]), true
+}
+
+func keyString(v interface{}) string {
+	switch k := v.(type) {
+	case string:
+		return k
+	case []byte:
+		return string(k)
+	}
+	return fmt.Sprint(v)
+}
+
+//  展开参数中的 []string 和 []interface{}, 与 ssdb 的编码方式一致
+func flattenArgs(args []interface{}) []interface{} {
+	out := make([]interface{}, 0, len(args))
+	for _, a := range args {
+		switch a := a.(type) {
+		case []string:
+			for _, s := range a {
+				out = append(out, s)
+			}
+		case []interface{}:
+			out = append(out, flattenArgs(a)...)
+		default:
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+//  按 key 拆分的命令, 值为每个 key 在参数中占的个数
+var splitCmds = map[string]int{"multi_get": 1, "multi_del": 1, "multi_set": 2}
+
+//  在所有分片上执行并归并排序的列表命令, 值为每个元素在响应中占的个数
+var mergeCmds = map[string]int{
+	"keys": 1, "scan": 2, "hlist": 1, "zlist": 1, "qlist": 1,
+	"rkeys": -1, "rscan": -2, "hrlist": -1, "zrlist": -1, "qrlist": -1,
+}
+
+//  参数不是 key, 需要在所有分片上执行的命令. auth 的参数是密码, 每个分片的连接都要认证
+var broadcastCmds = map[string]bool{"add_allow_ip": true, "del_allow_ip": true, "auth": true}
+
+func (r *ring) exec(ctx context.Context, args []interface{}) ([]string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	args = flattenArgs(args)
+	cmd := toCmd(args[0])
+	if stride, ok := splitCmds[cmd]; ok {
+		return r.execSplit(ctx, args, stride)
+	}
+	if stride, ok := mergeCmds[cmd]; ok {
+		return r.execMerge(ctx, args, stride)
+	}
+	if key, ok := routeKey(args); ok && !broadcastCmds[cmd] {
+		return r.locate(key).pool.exec(ctx, args)
+	}
+	return r.execAll(ctx, args)
+}
+
+//  在多个分片上并行执行, cmds 为每个分片的命令
+func (r *ring) parallel(ctx context.Context, cmds map[*shard][]interface{}) (map[*shard][]string, error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var firstErr error
+	resps := make(map[*shard][]string, len(cmds))
+	for s, args := range cmds {
+		wg.Add(1)
+		go func(s *shard, args []interface{}) {
+			defer wg.Done()
+			resp, err := s.pool.exec(ctx, args)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			resps[s] = resp
+		}(s, args)
+	}
+	wg.Wait()
+	return resps, firstErr
+}
+
+func (r *ring) execSplit(ctx context.Context, args []interface{}, stride int) ([]string, error) {
+	cmds := make(map[*shard][]interface{})
+	for i := 1; i+stride <= len(args); i += stride {
+		s := r.locate(keyString(args[i]))
+		if cmds[s] == nil {
+			cmds[s] = []interface{}{args[0]}
+		}
+		cmds[s] = append(cmds[s], args[i:i+stride]...)
+	}
+	if len(cmds) == 0 {
+		return r.shards[0].pool.exec(ctx, args)
+	}
+	resps, err := r.parallel(ctx, cmds)
+	if err != nil {
+		return nil, err
+	}
+	if stride == 1 && toCmd(args[0]) == "multi_get" {
+		out := []string{"ok"}
+		for _, resp := range resps {
+			out = append(out, resp[1:]...)
+		}
+		return out, nil
+	}
+	return sumResps(resps), nil
+}
+
+//  合并形如 [ok n] 的响应
+func sumResps(resps map[*shard][]string) []string {
+	var n int64
+	for _, resp := range resps {
+		if len(resp) > 1 {
+			v, _ := strconv.ParseInt(resp[1], 10, 64)
+			n += v
+		}
+	}
+	return []string{"ok", strconv.FormatInt(n, 10)}
+}
+
+//  列表命令的参数为 start, end, limit, 各个分片的结果已经有序, 归并后截取 limit 个
+func (r *ring) execMerge(ctx context.Context, args []interface{}, stride int) ([]string, error) {
+	cmds := make(map[*shard][]interface{}, len(r.shards))
+	for _, s := range r.shards {
+		cmds[s] = args
+	}
+	resps, err := r.parallel(ctx, cmds)
+	if err != nil {
+		return nil, err
+	}
+	desc := stride < 0
+	if desc {
+		stride = -stride
+	}
+	limit := int64(-1)
+	if len(args) > 3 {
+		if n, err := strconv.ParseInt(fmt.Sprint(args[3]), 10, 64); err == nil {
+			limit = n
+		}
+	}
+
+	// 每个分片的剩余元素
+	lists := make([][]string, 0, len(resps))
+	for _, resp := range resps {
+		if len(resp) > 1 {
+			lists = append(lists, resp[1:])
+		}
+	}
+	out := []string{"ok"}
+	for n := int64(0); limit < 0 || n < limit; n++ {
+		best := -1
+		for i, l := range lists {
+			if len(l) < stride {
+				continue
+			}
+			if best < 0 || (l[0] < lists[best][0]) != desc {
+				best = i
+			}
+		}
+		if best < 0 {
+			break
+		}
+		out = append(out, lists[best][:stride]...)
+		lists[best] = lists[best][stride:]
+	}
+	return out, nil
+}
+
+//  没有 key 的命令在所有分片上执行
+func (r *ring) execAll(ctx context.Context, args []interface{}) ([]string, error) {
+	cmds := make(map[*shard][]interface{}, len(r.shards))
+	for _, s := range r.shards {
+		cmds[s] = args
+	}
+	resps, err := r.parallel(ctx, cmds)
+	if err != nil {
+		return nil, err
+	}
+	if toCmd(args[0]) == "dbsize" {
+		return sumResps(resps), nil
+	}
+	// 任何一个分片失败时返回它的响应, 例如某个分片的密码不同
+	for _, s := range r.shards {
+		if resp := resps[s]; len(resp) > 0 && resp[0] != "ok" {
+			return resp, nil
+		}
+	}
+	return resps[r.shards[0]], nil
+}
+
+//  管道中的命令按分片分组, 每个分片一个管道并行执行; 需要拆分、合并或在所有分片上执行的命令单独执行.
+//  单独执行的命令把管道分成几段, 各段按顺序执行, 所以这些命令总能看到之前的命令写入的结果,
+//  之后的命令也总能看到它写入的结果. 响应按命令的原始顺序返回
+func (r *ring) execPipeline(ctx context.Context, cmds [][]interface{}) ([][]string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	resps := make([][]string, len(cmds))
+	start := 0
+	for i, args := range cmds {
+		args = flattenArgs(args)
+		if !r.pipelineSingle(args) {
+			continue
+		}
+		if err := r.execSegment(ctx, cmds[start:i], resps[start:i]); err != nil {
+			return nil, err
+		}
+		resp, err := r.exec(ctx, args)
+		if err != nil {
+			if _, ok := err.(*CommandError); !ok || resp == nil {
+				return nil, err
+			}
+		}
+		resps[i] = resp
+		start = i + 1
+	}
+	if err := r.execSegment(ctx, cmds[start:], resps[start:]); err != nil {
+		return nil, err
+	}
+	return resps, nil
+}
+
+//  命令是否不能放进某个分片的管道中
+func (r *ring) pipelineSingle(args []interface{}) bool {
+	cmd := toCmd(args[0])
+	_, split := splitCmds[cmd]
+	_, merge := mergeCmds[cmd]
+	_, ok := routeKey(args)
+	return split || merge || !ok || broadcastCmds[cmd]
+}
+
+//  执行管道中的一段命令, 它们都只访问一个分片, 按分片分组后并行执行, 响应写入 resps
+func (r *ring) execSegment(ctx context.Context, cmds [][]interface{}, resps [][]string) error {
+	type group struct {
+		cmds  [][]interface{}
+		index []int
+	}
+	groups := make(map[*shard]*group)
+	for i, args := range cmds {
+		args = flattenArgs(args)
+		key, _ := routeKey(args)
+		s := r.locate(key)
+		g := groups[s]
+		if g == nil {
+			g = &group{}
+			groups[s] = g
+		}
+		g.cmds = append(g.cmds, args)
+		g.index = append(g.index, i)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var firstErr error
+	for s, g := range groups {
+		wg.Add(1)
+		go func(s *shard, g *group) {
+			defer wg.Done()
+			rs, err := s.pool.execPipeline(ctx, g.cmds)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				return
+			}
+			for j, i := range g.index {
+				resps[i] = rs[j]
+			}
+		}(s, g)
+	}
+	wg.Wait()
+	return firstErr
+}
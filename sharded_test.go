@@ -0,0 +1,230 @@
+package gossdb_client
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/houbin910902/gossdb_client/ssdbtest"
+)
+
+func TestShardedClient(t *testing.T) {
+	var servers []*ssdbtest.Server
+	conf := &ShardedConfig{}
+	for i := 0; i < 3; i++ {
+		s, pc := newTestNode(t)
+		servers = append(servers, s)
+		conf.Shards = append(conf.Shards, ShardConfig{Name: fmt.Sprintf("s%d", i), PoolConfig: pc})
+	}
+	sc, err := NewShardedClient(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+
+	kvs := map[string]interface{}{}
+	var keys []string
+	for i := 0; i < 30; i++ {
+		k := fmt.Sprintf("k%02d", i)
+		kvs[k] = i
+		keys = append(keys, k)
+	}
+	if err = sc.MultiSet(kvs); err != nil {
+		t.Fatal(err)
+	}
+	// 每个分片只保存路由到它的 key
+	for i, s := range servers {
+		db, err := NewDbClient(s.Host(), s.Port(), testPassword)
+		if err != nil {
+			t.Fatal(err)
+		}
+		local, _ := db.Keys("", "", -1)
+		db.CloseDbClient()
+		if len(local) == 0 || len(local) == len(keys) {
+			t.Fatalf("shard %d holds %d keys", i, len(local))
+		}
+		for _, k := range local {
+			if sc.ShardOf(k) != conf.Shards[i].Name {
+				t.Fatalf("%s stored on s%d, routed to %s", k, i, sc.ShardOf(k))
+			}
+		}
+	}
+
+	// auth 在所有分片上执行, 任何一个分片认证失败都返回错误
+	if _, err = sc.Auth(testPassword); err != nil {
+		t.Fatalf("Auth fail err: %v", err)
+	}
+	for i, s := range servers {
+		s.SetPassword("other")
+		var ce *CommandError
+		if _, err = sc.Auth(testPassword); !errors.As(err, &ce) || ce.Code != "error" {
+			t.Errorf("Auth with a different password on s%d err = %v", i, err)
+		}
+		s.SetPassword(testPassword)
+	}
+
+	got, err := sc.MultiGet(keys...)
+	if err != nil || len(got) != len(keys) || got["k07"] != "7" {
+		t.Fatalf("MultiGet = %v, %v", got, err)
+	}
+	if v, err := sc.Get("k11"); err != nil || v != "11" {
+		t.Fatalf("Get = %q, %v", v, err)
+	}
+	if ks, err := sc.Keys("k04", "", 5); err != nil || !reflect.DeepEqual(ks, keys[5:10]) {
+		t.Fatalf("Keys = %v, %v", ks, err)
+	}
+	if ks, err := sc.RKeys("", "", 3); err != nil || !reflect.DeepEqual(ks, []string{"k29", "k28", "k27"}) {
+		t.Fatalf("RKeys = %v, %v", ks, err)
+	}
+
+	p := sc.Pipeline()
+	r1 := p.Get("k01")
+	r2 := p.Get("k02")
+	if _, err = p.Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := r1.String(); v != "1" {
+		t.Fatalf("pipeline k01 = %q", v)
+	}
+	if v, _ := r2.String(); v != "2" {
+		t.Fatalf("pipeline k02 = %q", v)
+	}
+
+	if err = sc.MultiDel(keys...); err != nil {
+		t.Fatal(err)
+	}
+	if ks, _ := sc.Keys("", "", -1); len(ks) != 0 {
+		t.Fatalf("Keys after MultiDel = %v", ks)
+	}
+
+	// ip 管理命令在所有分片上执行
+	if err = sc.Admin().AddAllowIP("10."); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range sc.Shards() {
+		if ips, err := sc.Shard(name).Admin().ListAllowIP(); err != nil || !reflect.DeepEqual(ips, []string{"10."}) {
+			t.Fatalf("%s ListAllowIP = %v, %v", name, ips, err)
+		}
+	}
+}
+
+func TestShardedPipelineOrder(t *testing.T) {
+	conf := &ShardedConfig{}
+	for i := 0; i < 3; i++ {
+		_, pc := newTestNode(t)
+		conf.Shards = append(conf.Shards, ShardConfig{Name: fmt.Sprintf("s%d", i), PoolConfig: pc})
+	}
+	sc, err := NewShardedClient(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+
+	var keys []interface{}
+	for i := 0; i < 10; i++ {
+		keys = append(keys, fmt.Sprintf("k%d", i))
+	}
+	// 拆分到各个分片的命令与前后的命令按顺序执行
+	p := sc.Pipeline()
+	for _, k := range keys {
+		p.Set(k.(string), "1")
+	}
+	before := p.Do(append([]interface{}{"multi_get"}, keys...)...)
+	p.Do(append([]interface{}{"multi_del"}, keys...)...)
+	var after []*Reply
+	for _, k := range keys {
+		after = append(after, p.Exists(k.(string)))
+	}
+	p.Set("k0", "2")
+	get := p.Get("k0")
+	if _, err = p.Exec(); err != nil {
+		t.Fatal(err)
+	}
+	if raw := before.Raw(); len(raw) != 1+2*len(keys) {
+		t.Errorf("multi_get before multi_del = %q", raw)
+	}
+	for i, r := range after {
+		if ok, err := r.Bool(); err != nil || ok {
+			t.Errorf("k%d exists after multi_del = %v, %v", i, ok, err)
+		}
+	}
+	if v, _ := get.String(); v != "2" {
+		t.Errorf("Get = %q", v)
+	}
+}
+
+func TestShardRing(t *testing.T) {
+	newRing := func(n int, weights ...int) *ShardedClient {
+		conf := &ShardedConfig{}
+		for i := 0; i < n; i++ {
+			w := 1
+			if i < len(weights) {
+				w = weights[i]
+			}
+			conf.Shards = append(conf.Shards, ShardConfig{Name: fmt.Sprintf("s%d", i), Weight: w})
+		}
+		sc, err := NewShardedClient(conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sc
+	}
+
+	// 增加一个分片时, 只有约 1/4 的 key 移动, 并且都移动到新分片
+	a, b := newRing(3), newRing(4)
+	moved := 0
+	for i := 0; i < 10000; i++ {
+		k := fmt.Sprintf("key:%d", i)
+		if sa, sb := a.ShardOf(k), b.ShardOf(k); sa != sb {
+			moved++
+			if sb != "s3" {
+				t.Fatalf("%s moved from %s to %s", k, sa, sb)
+			}
+		}
+	}
+	if moved < 1500 || moved > 3500 {
+		t.Fatalf("%d of 10000 keys moved", moved)
+	}
+
+	// 权重为 3 的分片得到约 3/5 的 key
+	w := newRing(3, 3)
+	count := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		count[w.ShardOf(fmt.Sprintf("key:%d", i))]++
+	}
+	var names []string
+	for n := range count {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	if count["s0"] < 5000 || count["s0"] > 7000 {
+		t.Fatalf("distribution = %v", count)
+	}
+}
+
+func TestHashTag(t *testing.T) {
+	conf := &ShardedConfig{}
+	for i := 0; i < 8; i++ {
+		conf.Shards = append(conf.Shards, ShardConfig{Name: fmt.Sprintf("s%d", i)})
+	}
+	sc, err := NewShardedClient(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		tag := fmt.Sprintf("%d", i)
+		want := sc.ShardOf(tag)
+		for _, k := range []string{"user:{" + tag + "}:profile", "{" + tag + "}", "{" + tag + "}:x{y}"} {
+			if got := sc.ShardOf(k); got != want {
+				t.Fatalf("ShardOf(%s) = %s, want %s", k, got, want)
+			}
+		}
+	}
+	for _, k := range []string{"a{}b", "a{b", "a}b{"} {
+		if hashTag(k) != k {
+			t.Fatalf("hashTag(%s) = %s", k, hashTag(k))
+		}
+	}
+}
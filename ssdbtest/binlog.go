@@ -0,0 +1,249 @@
+package ssdbtest
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"sort"
+	"time"
+)
+
+// Binlog types and commands, as in ssdb's binlog.h.
+const (
+	logNoop = 0
+	logSync = 1
+	logCopy = 3
+
+	logKSet       = 1
+	logKDel       = 2
+	logHSet       = 3
+	logHDel       = 4
+	logZSet       = 5
+	logZDel       = 6
+	logBegin      = 7
+	logEnd        = 8
+	logQPushBack  = 10
+	logQPushFront = 11
+	logQPopBack   = 12
+	logQPopFront  = 13
+)
+
+// NoopInterval is how often a sync140 stream sends a noop heartbeat
+// while there are no new binlogs.
+var NoopInterval = time.Second
+
+type binlog struct {
+	seq      uint64
+	typ, cmd byte
+	key      string // encoded like ssdb's internal keys
+	val      string
+	hasVal   bool
+}
+
+// packet encodes the binlog the way a master sends it to a slave:
+// seq (little endian) + type + cmd + key, then the value if any.
+func (b binlog) packet() []string {
+	h := make([]byte, 10, 10+len(b.key))
+	binary.LittleEndian.PutUint64(h, b.seq)
+	h[8], h[9] = b.typ, b.cmd
+	h = append(h, b.key...)
+	if b.hasVal {
+		return []string{string(h), b.val}
+	}
+	return []string{string(h)}
+}
+
+func kvKey(key string) string { return "k" + key }
+
+// nestedKey is the type byte, the length of name in one byte and name,
+// the prefix shared by the keys of hashes, zsets and queues.
+func nestedKey(typ byte, name string) string {
+	return string(typ) + string(byte(len(name))) + name
+}
+
+// hashKey is encode_hash_key in ssdb's t_hash.h.
+func hashKey(name, key string) string { return nestedKey('h', name) + "=" + key }
+
+// zitemKey is encode_zset_key in ssdb's t_zset.h, the key is prefixed
+// with its length in one byte.
+func zitemKey(name, key string) string {
+	return nestedKey('s', name) + string(byte(len(key))) + key
+}
+
+// qitemKey is encode_qitem_key in ssdb's t_queue.h.
+func qitemKey(name string, seq uint64) string {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], seq)
+	return nestedKey('q', name) + string(b[:])
+}
+
+// LastSeq returns the sequence number of the last binlog.
+func (s *Server) LastSeq() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.seq
+}
+
+func (s *Server) log(cmd byte, key string, val ...string) {
+	s.seq++
+	b := binlog{seq: s.seq, typ: logSync, cmd: cmd, key: key}
+	if len(val) > 0 {
+		b.val, b.hasVal = val[0], true
+	}
+	s.binlogs = append(s.binlogs, b)
+}
+
+// logWrite records binlogs for the basic write commands after they
+// succeeded. Commands such as hclear or expire are not logged.
+func (s *Server) logWrite(cmd string, args []string, resp []string) {
+	switch cmd {
+	case "set", "setx", "setnx", "getset", "incr", "setbit":
+		if v, ok := s.kv[args[0]]; ok {
+			s.log(logKSet, kvKey(args[0]), v)
+		}
+	case "multi_set":
+		for i := 0; i+1 < len(args); i += 2 {
+			s.log(logKSet, kvKey(args[i]), args[i+1])
+		}
+	case "del":
+		s.log(logKDel, kvKey(args[0]))
+	case "multi_del":
+		for _, k := range args {
+			s.log(logKDel, kvKey(k))
+		}
+	case "hset", "hincr":
+		s.log(logHSet, hashKey(args[0], args[1]), s.hash[args[0]][args[1]])
+	case "multi_hset":
+		for i := 1; i+1 < len(args); i += 2 {
+			s.log(logHSet, hashKey(args[0], args[i]), args[i+1])
+		}
+	case "hdel", "multi_hdel":
+		for _, k := range args[1:] {
+			s.log(logHDel, hashKey(args[0], k))
+		}
+	case "zset", "zincr":
+		s.log(logZSet, zitemKey(args[0], args[1]), formatScore(s.zset[args[0]][args[1]]))
+	case "multi_zset":
+		for i := 1; i+1 < len(args); i += 2 {
+			s.log(logZSet, zitemKey(args[0], args[i]), formatScore(s.zset[args[0]][args[i]]))
+		}
+	case "zdel", "multi_zdel":
+		for _, k := range args[1:] {
+			s.log(logZDel, zitemKey(args[0], k))
+		}
+	case "qpush", "qpush_back", "qpush_front":
+		c := byte(logQPushBack)
+		if cmd == "qpush_front" {
+			c = logQPushFront
+		}
+		for _, v := range args[1:] {
+			s.qseq++
+			s.log(c, qitemKey(args[0], s.qseq), v)
+		}
+	case "qpop", "qpop_front", "qpop_back":
+		c := byte(logQPopFront)
+		if cmd == "qpop_back" {
+			c = logQPopBack
+		}
+		for range resp[1:] {
+			s.qseq++
+			s.log(c, qitemKey(args[0], s.qseq))
+		}
+	}
+}
+
+// snapshot returns the copy phase of a sync: every item in the
+// database, ordered by encoded key, after the key start.
+func (s *Server) snapshot(start string) []binlog {
+	var out []binlog
+	add := func(cmd byte, key, val string) {
+		if key > start {
+			out = append(out, binlog{seq: s.seq, typ: logCopy, cmd: cmd, key: key, val: val, hasVal: true})
+		}
+	}
+	for k, v := range s.kv {
+		if _, found := s.get(k); found {
+			add(logKSet, kvKey(k), v)
+		}
+	}
+	for name, h := range s.hash {
+		for k, v := range h {
+			add(logHSet, hashKey(name, k), v)
+		}
+	}
+	for name, z := range s.zset {
+		for k, score := range z {
+			add(logZSet, zitemKey(name, k), formatScore(score))
+		}
+	}
+	for name, q := range s.queue {
+		for i, v := range q {
+			add(logQPushBack, qitemKey(name, uint64(i)), v)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].key < out[j].key })
+	return out
+}
+
+// sync streams binlogs to a slave, like ssdb's backend_sync. A slave
+// at seq 0, one asking for binlogs that are no longer kept, or one
+// with a copy in progress (a non-empty key) first gets a full copy.
+// It returns when the connection is closed.
+func (s *Server) sync(c net.Conn, r *bufio.Reader, args []string) {
+	var seq uint64
+	var key string
+	if len(args) > 0 {
+		n, _ := parseInt(args[0])
+		seq = uint64(n)
+	}
+	if len(args) > 1 {
+		key = args[1]
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		// the slave never sends anything, a read only returns on close
+		r.ReadByte()
+		close(closed)
+	}()
+
+	s.mu.Lock()
+	var packets [][]string
+	if seq == 0 || key != "" || len(s.binlogs) == 0 || s.binlogs[0].seq > seq+1 {
+		packets = append(packets, binlog{seq: s.seq, typ: logCopy, cmd: logBegin}.packet())
+		for _, b := range s.snapshot(key) {
+			packets = append(packets, b.packet())
+		}
+		packets = append(packets, binlog{seq: s.seq, typ: logCopy, cmd: logEnd}.packet())
+		seq = s.seq
+	}
+	s.mu.Unlock()
+
+	lastSent := time.Now()
+	for {
+		for _, p := range packets {
+			if _, err := c.Write(encode(p)); err != nil {
+				return
+			}
+			lastSent = time.Now()
+		}
+		packets = packets[:0]
+
+		select {
+		case <-closed:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+		s.mu.Lock()
+		for _, b := range s.binlogs {
+			if b.seq > seq {
+				packets = append(packets, b.packet())
+				seq = b.seq
+			}
+		}
+		s.mu.Unlock()
+		if len(packets) == 0 && time.Since(lastSent) >= NoopInterval {
+			packets = append(packets, binlog{seq: seq, typ: logNoop, key: "noop"}.packet())
+		}
+	}
+}
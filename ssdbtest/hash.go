@@ -0,0 +1,188 @@
+package ssdbtest
+
+import (
+	"strconv"
+)
+
+func init() {
+	register("hset", 3, cmdHset)
+	register("hget", 2, cmdHget)
+	register("hdel", 2, cmdHdel)
+	register("hincr", 2, cmdHincr)
+	register("hexists", 2, cmdHexists)
+	register("hsize", 1, cmdHsize)
+	register("hclear", 1, cmdHclear)
+	register("hgetall", 1, cmdHgetall)
+	register("hkeys", 4, cmdHscan(false, false))
+	register("hscan", 4, cmdHscan(false, true))
+	register("hrscan", 4, cmdHscan(true, true))
+	register("hlist", 3, cmdNames(func(s *Server) []string { return mapNames(s.hash) }, false))
+	register("hrlist", 3, cmdNames(func(s *Server) []string { return mapNames(s.hash) }, true))
+	register("multi_hset", 3, cmdMultiHset)
+	register("multi_hget", 2, cmdMultiHget)
+	register("multi_hdel", 2, cmdMultiHdel)
+}
+
+func (s *Server) hsetField(name, key, val string) bool {
+	h := s.hash[name]
+	if h == nil {
+		h = make(map[string]string)
+		s.hash[name] = h
+	}
+	_, existed := h[key]
+	h[key] = val
+	return !existed
+}
+
+func (s *Server) hdelField(name, key string) bool {
+	h := s.hash[name]
+	if _, found := h[key]; !found {
+		return false
+	}
+	delete(h, key)
+	if len(h) == 0 {
+		delete(s.hash, name)
+	}
+	return true
+}
+
+func cmdHset(s *Server, args []string) []string {
+	return okBool(s.hsetField(args[0], args[1], args[2]))
+}
+
+func cmdHget(s *Server, args []string) []string {
+	v, found := s.hash[args[0]][args[1]]
+	if !found {
+		return notFound()
+	}
+	return ok(v)
+}
+
+func cmdHdel(s *Server, args []string) []string {
+	return okBool(s.hdelField(args[0], args[1]))
+}
+
+func cmdHincr(s *Server, args []string) []string {
+	by := int64(1)
+	if len(args) > 2 {
+		n, valid := parseInt(args[2])
+		if !valid {
+			return errValue("value is not an integer or out of range")
+		}
+		by = n
+	}
+	var cur int64
+	if v, found := s.hash[args[0]][args[1]]; found {
+		n, valid := parseInt(v)
+		if !valid {
+			return []string{"error", "value is not an integer or out of range"}
+		}
+		cur = n
+	}
+	cur += by
+	s.hsetField(args[0], args[1], strconv.FormatInt(cur, 10))
+	return okInt(cur)
+}
+
+func cmdHexists(s *Server, args []string) []string {
+	_, found := s.hash[args[0]][args[1]]
+	return okBool(found)
+}
+
+func cmdHsize(s *Server, args []string) []string {
+	return okInt(int64(len(s.hash[args[0]])))
+}
+
+func cmdHclear(s *Server, args []string) []string {
+	n := len(s.hash[args[0]])
+	delete(s.hash, args[0])
+	return okInt(int64(n))
+}
+
+func cmdHgetall(s *Server, args []string) []string {
+	h := s.hash[args[0]]
+	resp := ok()
+	for _, k := range keyRange(mapKeys(h), "", "", -1, false) {
+		resp = append(resp, k, h[k])
+	}
+	return resp
+}
+
+func cmdHscan(reverse, withValues bool) func(s *Server, args []string) []string {
+	return func(s *Server, args []string) []string {
+		limit, valid := parseInt(args[3])
+		if !valid {
+			return errArgs()
+		}
+		h := s.hash[args[0]]
+		resp := ok()
+		for _, k := range keyRange(mapKeys(h), args[1], args[2], limit, reverse) {
+			resp = append(resp, k)
+			if withValues {
+				resp = append(resp, h[k])
+			}
+		}
+		return resp
+	}
+}
+
+func cmdMultiHset(s *Server, args []string) []string {
+	if len(args)%2 != 1 {
+		return errArgs()
+	}
+	var n int64
+	for i := 1; i < len(args); i += 2 {
+		if s.hsetField(args[0], args[i], args[i+1]) {
+			n++
+		}
+	}
+	return okInt(n)
+}
+
+func cmdMultiHget(s *Server, args []string) []string {
+	h := s.hash[args[0]]
+	resp := ok()
+	for _, k := range args[1:] {
+		if v, found := h[k]; found {
+			resp = append(resp, k, v)
+		}
+	}
+	return resp
+}
+
+func cmdMultiHdel(s *Server, args []string) []string {
+	var n int64
+	for _, k := range args[1:] {
+		if s.hdelField(args[0], k) {
+			n++
+		}
+	}
+	return okInt(n)
+}
+
+// cmdNames implements hlist, zlist, qlist and their reverse forms.
+func cmdNames(names func(s *Server) []string, reverse bool) func(s *Server, args []string) []string {
+	return func(s *Server, args []string) []string {
+		limit, valid := parseInt(args[2])
+		if !valid {
+			return errArgs()
+		}
+		return ok(keyRange(names(s), args[0], args[1], limit, reverse)...)
+	}
+}
+
+func mapKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func mapNames(m map[string]map[string]string) []string {
+	names := make([]string, 0, len(m))
+	for k := range m {
+		names = append(names, k)
+	}
+	return names
+}
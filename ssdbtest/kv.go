@@ -0,0 +1,313 @@
+package ssdbtest
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+func init() {
+	register("set", 2, cmdSet)
+	register("setx", 3, cmdSetx)
+	register("setnx", 2, cmdSetnx)
+	register("get", 1, cmdGet)
+	register("getset", 2, cmdGetset)
+	register("del", 1, cmdDel)
+	register("incr", 1, cmdIncr)
+	register("exists", 1, cmdExists)
+	register("expire", 2, cmdExpire)
+	register("ttl", 1, cmdTTL)
+	register("multi_set", 2, cmdMultiSet)
+	register("multi_get", 1, cmdMultiGet)
+	register("multi_del", 1, cmdMultiDel)
+	register("setbit", 3, cmdSetbit)
+	register("getbit", 2, cmdGetbit)
+	register("substr", 2, cmdSubstr)
+	register("strlen", 1, cmdStrlen)
+	register("keys", 3, cmdKeys(false, false))
+	register("rkeys", 3, cmdKeys(true, false))
+	register("scan", 3, cmdKeys(false, true))
+	register("rscan", 3, cmdKeys(true, true))
+}
+
+// get returns a KV value, expiring it first if its TTL has passed.
+func (s *Server) get(key string) (string, bool) {
+	if t, ok := s.ttl[key]; ok && !s.Now().Before(t) {
+		delete(s.kv, key)
+		delete(s.ttl, key)
+	}
+	v, ok := s.kv[key]
+	return v, ok
+}
+
+func (s *Server) set(key, val string) {
+	s.kv[key] = val
+	delete(s.ttl, key)
+}
+
+func (s *Server) del(key string) bool {
+	_, ok := s.get(key)
+	delete(s.kv, key)
+	delete(s.ttl, key)
+	return ok
+}
+
+func cmdSet(s *Server, args []string) []string {
+	s.set(args[0], args[1])
+	return ok("1")
+}
+
+func cmdSetx(s *Server, args []string) []string {
+	ttl, valid := parseInt(args[2])
+	if !valid {
+		return errValue("invalid ttl")
+	}
+	s.set(args[0], args[1])
+	s.ttl[args[0]] = s.Now().Add(time.Duration(ttl) * time.Second)
+	return ok("1")
+}
+
+func cmdSetnx(s *Server, args []string) []string {
+	if _, found := s.get(args[0]); found {
+		return ok("0")
+	}
+	s.set(args[0], args[1])
+	return ok("1")
+}
+
+func cmdGet(s *Server, args []string) []string {
+	v, found := s.get(args[0])
+	if !found {
+		return notFound()
+	}
+	return ok(v)
+}
+
+func cmdGetset(s *Server, args []string) []string {
+	old, found := s.get(args[0])
+	s.set(args[0], args[1])
+	if !found {
+		return notFound()
+	}
+	return ok(old)
+}
+
+func cmdDel(s *Server, args []string) []string {
+	s.del(args[0])
+	return ok("1")
+}
+
+func cmdIncr(s *Server, args []string) []string {
+	by := int64(1)
+	if len(args) > 1 {
+		n, valid := parseInt(args[1])
+		if !valid {
+			return errValue("value is not an integer or out of range")
+		}
+		by = n
+	}
+	var cur int64
+	if v, found := s.get(args[0]); found {
+		n, valid := parseInt(v)
+		if !valid {
+			return []string{"error", "value is not an integer or out of range"}
+		}
+		cur = n
+	}
+	cur += by
+	// incr keeps the TTL of the key
+	s.kv[args[0]] = strconv.FormatInt(cur, 10)
+	return okInt(cur)
+}
+
+func cmdExists(s *Server, args []string) []string {
+	_, found := s.get(args[0])
+	return okBool(found)
+}
+
+func cmdExpire(s *Server, args []string) []string {
+	ttl, valid := parseInt(args[1])
+	if !valid {
+		return errValue("invalid ttl")
+	}
+	if _, found := s.get(args[0]); !found {
+		return ok("0")
+	}
+	s.ttl[args[0]] = s.Now().Add(time.Duration(ttl) * time.Second)
+	return ok("1")
+}
+
+func cmdTTL(s *Server, args []string) []string {
+	if _, found := s.get(args[0]); !found {
+		return okInt(-1)
+	}
+	t, has := s.ttl[args[0]]
+	if !has {
+		return okInt(-1)
+	}
+	left := t.Sub(s.Now())
+	secs := int64(left / time.Second)
+	if left%time.Second > 0 {
+		secs++
+	}
+	return okInt(secs)
+}
+
+func cmdMultiSet(s *Server, args []string) []string {
+	if len(args)%2 != 0 {
+		return errArgs()
+	}
+	for i := 0; i < len(args); i += 2 {
+		s.set(args[i], args[i+1])
+	}
+	return okInt(int64(len(args) / 2))
+}
+
+func cmdMultiGet(s *Server, args []string) []string {
+	resp := ok()
+	for _, k := range args {
+		if v, found := s.get(k); found {
+			resp = append(resp, k, v)
+		}
+	}
+	return resp
+}
+
+func cmdMultiDel(s *Server, args []string) []string {
+	var n int64
+	for _, k := range args {
+		if s.del(k) {
+			n++
+		}
+	}
+	return okInt(n)
+}
+
+// ssdb numbers the bits of each byte from the least significant one.
+func cmdSetbit(s *Server, args []string) []string {
+	offset, valid := parseInt(args[1])
+	bit, valid2 := parseInt(args[2])
+	if !valid || !valid2 || offset < 0 || offset > 1<<30 || (bit != 0 && bit != 1) {
+		return errValue("offset is out of range [0, 4294967296]")
+	}
+	v, _ := s.get(args[0])
+	b := []byte(v)
+	idx := int(offset / 8)
+	if idx >= len(b) {
+		b = append(b, make([]byte, idx-len(b)+1)...)
+	}
+	mask := byte(1) << uint(offset%8)
+	old := b[idx]&mask != 0
+	if bit == 1 {
+		b[idx] |= mask
+	} else {
+		b[idx] &^= mask
+	}
+	s.kv[args[0]] = string(b)
+	return okBool(old)
+}
+
+func cmdGetbit(s *Server, args []string) []string {
+	offset, valid := parseInt(args[1])
+	if !valid || offset < 0 {
+		return errValue("offset is out of range")
+	}
+	v, _ := s.get(args[0])
+	idx := int(offset / 8)
+	if idx >= len(v) {
+		return ok("0")
+	}
+	return okBool(v[idx]&(byte(1)<<uint(offset%8)) != 0)
+}
+
+func cmdSubstr(s *Server, args []string) []string {
+	v, _ := s.get(args[0])
+	start, valid := parseInt(args[1])
+	if !valid {
+		return errArgs()
+	}
+	size := int64(len(v))
+	if len(args) > 2 {
+		if size, valid = parseInt(args[2]); !valid {
+			return errArgs()
+		}
+	}
+	n := int64(len(v))
+	if start < 0 {
+		start += n
+		if start < 0 {
+			start = 0
+		}
+	}
+	if start > n {
+		start = n
+	}
+	end := start + size
+	if size < 0 {
+		end = n + size
+	}
+	if end > n {
+		end = n
+	}
+	if end < start {
+		end = start
+	}
+	return ok(v[start:end])
+}
+
+func cmdStrlen(s *Server, args []string) []string {
+	v, _ := s.get(args[0])
+	return okInt(int64(len(v)))
+}
+
+func cmdKeys(reverse, withValues bool) func(s *Server, args []string) []string {
+	return func(s *Server, args []string) []string {
+		limit, valid := parseInt(args[2])
+		if !valid {
+			return errArgs()
+		}
+		names := make([]string, 0, len(s.kv))
+		for k := range s.kv {
+			if _, found := s.get(k); found {
+				names = append(names, k)
+			}
+		}
+		resp := ok()
+		for _, k := range keyRange(names, args[0], args[1], limit, reverse) {
+			resp = append(resp, k)
+			if withValues {
+				resp = append(resp, s.kv[k])
+			}
+		}
+		return resp
+	}
+}
+
+// keyRange returns the names in (start, end], or in [end, start) in
+// descending order when reverse is set. An empty start or end means no
+// bound on that side.
+func keyRange(names []string, start, end string, limit int64, reverse bool) []string {
+	sort.Strings(names)
+	if reverse {
+		for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+			names[i], names[j] = names[j], names[i]
+		}
+	}
+	var out []string
+	for _, k := range names {
+		if limit >= 0 && int64(len(out)) >= limit {
+			break
+		}
+		if !reverse {
+			if start != "" && k <= start || end != "" && k > end {
+				continue
+			}
+		} else {
+			if start != "" && k >= start || end != "" && k < end {
+				continue
+			}
+		}
+		out = append(out, k)
+	}
+	return out
+}
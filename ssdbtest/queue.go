@@ -0,0 +1,203 @@
+package ssdbtest
+
+func init() {
+	register("qsize", 1, cmdQsize)
+	register("qclear", 1, cmdQclear)
+	register("qfront", 1, cmdQget(0))
+	register("qback", 1, cmdQget(-1))
+	register("qget", 2, cmdQgetIndex)
+	register("qset", 3, cmdQset)
+	register("qpush", 2, cmdQpush(false))
+	register("qpush_back", 2, cmdQpush(false))
+	register("qpush_front", 2, cmdQpush(true))
+	register("qpop", 1, cmdQpop(false))
+	register("qpop_front", 1, cmdQpop(false))
+	register("qpop_back", 1, cmdQpop(true))
+	register("qtrim_front", 2, cmdQtrim(false))
+	register("qtrim_back", 2, cmdQtrim(true))
+	register("qrange", 3, cmdQrange)
+	register("qslice", 3, cmdQslice)
+	register("qlist", 3, cmdNames(queueNames, false))
+	register("qrlist", 3, cmdNames(queueNames, true))
+}
+
+func queueNames(s *Server) []string {
+	names := make([]string, 0, len(s.queue))
+	for k := range s.queue {
+		names = append(names, k)
+	}
+	return names
+}
+
+func (s *Server) setQueue(name string, q []string) {
+	if len(q) == 0 {
+		delete(s.queue, name)
+		return
+	}
+	s.queue[name] = q
+}
+
+// index converts a possibly negative queue index to an offset.
+func index(q []string, i int64) (int, bool) {
+	if i < 0 {
+		i += int64(len(q))
+	}
+	if i < 0 || i >= int64(len(q)) {
+		return 0, false
+	}
+	return int(i), true
+}
+
+func cmdQsize(s *Server, args []string) []string {
+	return okInt(int64(len(s.queue[args[0]])))
+}
+
+func cmdQclear(s *Server, args []string) []string {
+	n := len(s.queue[args[0]])
+	delete(s.queue, args[0])
+	return okInt(int64(n))
+}
+
+func cmdQget(i int64) func(s *Server, args []string) []string {
+	return func(s *Server, args []string) []string {
+		q := s.queue[args[0]]
+		idx, found := index(q, i)
+		if !found {
+			return notFound()
+		}
+		return ok(q[idx])
+	}
+}
+
+func cmdQgetIndex(s *Server, args []string) []string {
+	i, valid := parseInt(args[1])
+	if !valid {
+		return errArgs()
+	}
+	return cmdQget(i)(s, args)
+}
+
+func cmdQset(s *Server, args []string) []string {
+	i, valid := parseInt(args[1])
+	if !valid {
+		return errArgs()
+	}
+	q := s.queue[args[0]]
+	idx, found := index(q, i)
+	if !found {
+		return []string{"error", "index out of range"}
+	}
+	q[idx] = args[2]
+	return ok()
+}
+
+func cmdQpush(front bool) func(s *Server, args []string) []string {
+	return func(s *Server, args []string) []string {
+		q := s.queue[args[0]]
+		for _, item := range args[1:] {
+			if front {
+				q = append([]string{item}, q...)
+			} else {
+				q = append(q, item)
+			}
+		}
+		s.setQueue(args[0], q)
+		return okInt(int64(len(q)))
+	}
+}
+
+// cmdQpop pops one item, or up to size items when size is given.
+func cmdQpop(back bool) func(s *Server, args []string) []string {
+	return func(s *Server, args []string) []string {
+		q := s.queue[args[0]]
+		size := int64(1)
+		if len(args) > 1 {
+			n, valid := parseInt(args[1])
+			if !valid {
+				return errArgs()
+			}
+			size = n
+		} else if len(q) == 0 {
+			return notFound()
+		}
+		resp := ok()
+		for ; size > 0 && len(q) > 0; size-- {
+			if back {
+				resp = append(resp, q[len(q)-1])
+				q = q[:len(q)-1]
+			} else {
+				resp = append(resp, q[0])
+				q = q[1:]
+			}
+		}
+		s.setQueue(args[0], q)
+		return resp
+	}
+}
+
+func cmdQtrim(back bool) func(s *Server, args []string) []string {
+	return func(s *Server, args []string) []string {
+		size, valid := parseInt(args[1])
+		if !valid {
+			return errArgs()
+		}
+		q := s.queue[args[0]]
+		if size > int64(len(q)) {
+			size = int64(len(q))
+		}
+		if size < 0 {
+			size = 0
+		}
+		if back {
+			q = q[:len(q)-int(size)]
+		} else {
+			q = q[size:]
+		}
+		s.setQueue(args[0], q)
+		return okInt(size)
+	}
+}
+
+func cmdQrange(s *Server, args []string) []string {
+	offset, valid := parseInt(args[1])
+	limit, valid2 := parseInt(args[2])
+	if !valid || !valid2 {
+		return errArgs()
+	}
+	q := s.queue[args[0]]
+	if offset < 0 {
+		offset += int64(len(q))
+		if offset < 0 {
+			offset = 0
+		}
+	}
+	resp := ok()
+	for i := offset; i < int64(len(q)) && (limit < 0 || i < offset+limit); i++ {
+		resp = append(resp, q[i])
+	}
+	return resp
+}
+
+func cmdQslice(s *Server, args []string) []string {
+	begin, valid := parseInt(args[1])
+	end, valid2 := parseInt(args[2])
+	if !valid || !valid2 {
+		return errArgs()
+	}
+	q := s.queue[args[0]]
+	n := int64(len(q))
+	if begin < 0 {
+		begin += n
+	}
+	if end < 0 {
+		end += n
+	}
+	if begin < 0 {
+		begin = 0
+	}
+	resp := ok()
+	for i := begin; i <= end && i < n; i++ {
+		resp = append(resp, q[i])
+	}
+	return resp
+}
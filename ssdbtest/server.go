@@ -0,0 +1,361 @@
+// Package ssdbtest provides an in-memory SSDB server for tests.
+//
+// The server listens on a loopback address and speaks the same
+// length-prefixed protocol as ssdb-server. It implements the KV, hash,
+// zset and queue commands wrapped by gossdb_client, including TTLs on
+// KV keys and password authentication, plus ping, version, dbsize and
+// info, so tests can run without an external ssdb-server:
+//
+//	s := ssdbtest.NewServer()
+//	defer s.Close()
+//	db, err := gossdb_client.NewDbClient(s.Host(), s.Port(), "")
+package ssdbtest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is an in-memory SSDB server.
+type Server struct {
+	// Now returns the current time and is used for TTLs. Tests can
+	// replace it to move time forward without sleeping.
+	Now func() time.Time
+
+	ln net.Listener
+
+	mu    sync.Mutex
+	kv    map[string]string
+	ttl   map[string]time.Time
+	hash  map[string]map[string]string
+	zset  map[string]map[string]int64
+	queue map[string][]string
+	calls map[string]int64
+	fail  map[string]string
+
+	binlogs []binlog
+	seq     uint64
+	qseq    uint64
+
+	keyRange [2]string
+	allowIP  []string
+	denyIP   []string
+
+	connMu   sync.Mutex
+	conns    map[net.Conn]bool
+	closed   bool
+	done     chan struct{}
+	latency  time.Duration
+	password string
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a server without authentication on a random
+// loopback port. It panics if it can not listen, like httptest.NewServer.
+func NewServer() *Server {
+	return NewServerWithAuth("")
+}
+
+// NewServerWithAuth starts a server that requires password before any
+// other command. An empty password disables authentication.
+func NewServerWithAuth(password string) *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("ssdbtest: failed to listen on a port: %v", err))
+	}
+	s := &Server{
+		Now:      time.Now,
+		password: password,
+		ln:       ln,
+		conns:    make(map[net.Conn]bool),
+		calls:    make(map[string]int64),
+		fail:     make(map[string]string),
+		done:     make(chan struct{}),
+	}
+	s.reset()
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Addr returns the address the server listens on, in host:port form.
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Host returns the IP the server listens on.
+func (s *Server) Host() string {
+	return s.ln.Addr().(*net.TCPAddr).IP.String()
+}
+
+// Port returns the port the server listens on.
+func (s *Server) Port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+// Close stops the server and closes every client connection.
+func (s *Server) Close() {
+	s.connMu.Lock()
+	if s.closed {
+		s.connMu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.done)
+	s.connMu.Unlock()
+
+	s.ln.Close()
+	s.CloseClientConnections()
+	s.wg.Wait()
+}
+
+// CloseClientConnections closes every open client connection but keeps
+// listening and keeps the data, which looks like a server restart to
+// the clients.
+func (s *Server) CloseClientConnections() {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	for c := range s.conns {
+		c.Close()
+	}
+}
+
+// SetLatency delays every reply by d, so tests can interrupt a client
+// that is blocked reading. Zero disables the delay.
+func (s *Server) SetLatency(d time.Duration) {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	s.latency = d
+}
+
+// SetPassword changes the password checked by auth. Connections that
+// already authenticated stay authenticated, so tests can make a client
+// fail to re-authenticate after CloseClientConnections.
+func (s *Server) SetPassword(password string) {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	s.password = password
+}
+
+func (s *Server) currentPassword() string {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	return s.password
+}
+
+// FailCommand makes every following call of cmd reply with status
+// instead of running, so tests can exercise error handling. An empty
+// status makes cmd run normally again.
+func (s *Server) FailCommand(cmd, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if status == "" {
+		delete(s.fail, cmd)
+		return
+	}
+	s.fail[cmd] = status
+}
+
+// FlushAll deletes all data.
+func (s *Server) FlushAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reset()
+}
+
+func (s *Server) reset() {
+	s.kv = make(map[string]string)
+	s.ttl = make(map[string]time.Time)
+	s.hash = make(map[string]map[string]string)
+	s.zset = make(map[string]map[string]int64)
+	s.queue = make(map[string][]string)
+	s.binlogs = nil
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.connMu.Lock()
+		if s.closed {
+			s.connMu.Unlock()
+			c.Close()
+			return
+		}
+		s.conns[c] = true
+		s.connMu.Unlock()
+
+		s.wg.Add(1)
+		go s.serveConn(c)
+	}
+}
+
+func (s *Server) serveConn(c net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.connMu.Lock()
+		delete(s.conns, c)
+		s.connMu.Unlock()
+		c.Close()
+	}()
+
+	r := bufio.NewReader(c)
+	authed := s.currentPassword() == ""
+	for {
+		req, err := readRequest(r)
+		if err != nil {
+			return
+		}
+		var resp []string
+		cmd := strings.ToLower(req[0])
+		switch {
+		case cmd == "auth":
+			resp = s.auth(req)
+			authed = authed || resp[0] == "ok"
+		case !authed:
+			resp = []string{"noauth", "authentication required"}
+		case cmd == "sync140":
+			s.sync(c, r, req[1:])
+			return
+		default:
+			resp = s.exec(cmd, req[1:])
+		}
+		s.connMu.Lock()
+		d := s.latency
+		s.connMu.Unlock()
+		if d > 0 {
+			select {
+			case <-time.After(d):
+			case <-s.done:
+				return
+			}
+		}
+		if _, err := c.Write(encode(resp)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) auth(req []string) []string {
+	if len(req) != 2 {
+		return errArgs()
+	}
+	if password := s.currentPassword(); password != "" && req[1] != password {
+		return []string{"error", "invalid password"}
+	}
+	return []string{"ok", "1"}
+}
+
+func (s *Server) exec(cmd string, args []string) []string {
+	h, ok := commands[cmd]
+	if !ok {
+		return []string{"client_error", "Unknown Command: " + cmd}
+	}
+	if len(args) < h.minArgs {
+		return errArgs()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[cmd]++
+	if status, ok := s.fail[cmd]; ok {
+		return []string{status, "injected failure"}
+	}
+	resp := h.fn(s, args)
+	if resp[0] == "ok" {
+		s.logWrite(cmd, args, resp)
+	}
+	return resp
+}
+
+type command struct {
+	minArgs int
+	fn      func(s *Server, args []string) []string
+}
+
+var commands = map[string]command{}
+
+func register(name string, minArgs int, fn func(s *Server, args []string) []string) {
+	commands[name] = command{minArgs: minArgs, fn: fn}
+}
+
+// readRequest reads one packet: a list of length-prefixed blocks
+// followed by an empty line.
+func readRequest(r *bufio.Reader) ([]string, error) {
+	var req []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if len(req) == 0 {
+				continue
+			}
+			return req, nil
+		}
+		size, err := strconv.Atoi(line)
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("ssdbtest: bad block size %q", line)
+		}
+		buf := make([]byte, size+1)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		req = append(req, string(buf[:size]))
+	}
+}
+
+func encode(resp []string) []byte {
+	var buf bytes.Buffer
+	for _, s := range resp {
+		buf.WriteString(strconv.Itoa(len(s)))
+		buf.WriteByte('\n')
+		buf.WriteString(s)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+func ok(items ...string) []string {
+	return append([]string{"ok"}, items...)
+}
+
+func notFound() []string {
+	return []string{"not_found"}
+}
+
+func errArgs() []string {
+	return []string{"client_error", "wrong number of arguments"}
+}
+
+func errValue(msg string) []string {
+	return []string{"client_error", msg}
+}
+
+func okInt(n int64) []string {
+	return ok(strconv.FormatInt(n, 10))
+}
+
+func okBool(b bool) []string {
+	if b {
+		return ok("1")
+	}
+	return ok("0")
+}
+
+func parseInt(s string) (int64, bool) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	return n, err == nil
+}
@@ -0,0 +1,56 @@
+package ssdbtest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/houbin910902/gossdb_client/gossdb/ssdb"
+)
+
+func TestServer(t *testing.T) {
+	s := NewServerWithAuth("secret")
+	defer s.Close()
+
+	c, err := ssdb.Connect(s.Host(), s.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	tests := []struct {
+		args []interface{}
+		want []string
+	}{
+		{[]interface{}{"get", "a"}, []string{"noauth", "authentication required"}},
+		{[]interface{}{"auth", "bad"}, []string{"error", "invalid password"}},
+		{[]interface{}{"auth", "secret"}, []string{"ok", "1"}},
+		{[]interface{}{"get", "a"}, []string{"not_found"}},
+		{[]interface{}{"nosuchcmd"}, []string{"client_error", "Unknown Command: nosuchcmd"}},
+		{[]interface{}{"multi_zset", "z", "a", 3, "b", -2, "c", 5, "d", 3}, []string{"ok", "4"}},
+		{[]interface{}{"zscan", "z", "a", 3, "", 10}, []string{"ok", "d", "3", "c", "5"}},
+		{[]interface{}{"zrscan", "z", "", "", "", 2}, []string{"ok", "c", "5", "d", "3"}},
+		{[]interface{}{"zrank", "z", "a"}, []string{"ok", "1"}},
+		// 与 ssdb 相同, 权重是 int64, 小数部分被忽略
+		{[]interface{}{"zset", "z", "e", "98.5"}, []string{"ok", "1"}},
+		{[]interface{}{"zget", "z", "e"}, []string{"ok", "98"}},
+		{[]interface{}{"zavg", "z", "98", ""}, []string{"ok", "98"}},
+		{[]interface{}{"qpush_front", "q", "a", "b"}, []string{"ok", "2"}},
+		{[]interface{}{"qslice", "q", 0, -1}, []string{"ok", "b", "a"}},
+		{[]interface{}{"qpop_back", "q"}, []string{"ok", "a"}},
+		{[]interface{}{"qpop_back", "q", 5}, []string{"ok", "b"}},
+		{[]interface{}{"qpop_back", "q"}, []string{"not_found"}},
+		{[]interface{}{"hset", "h", "k", "\n\x00v"}, []string{"ok", "1"}},
+		{[]interface{}{"hget", "h", "k"}, []string{"ok", "\n\x00v"}},
+		{[]interface{}{"setbit", "bits", 9, 1}, []string{"ok", "0"}},
+		{[]interface{}{"get", "bits"}, []string{"ok", "\x00\x02"}},
+	}
+	for _, tt := range tests {
+		resp, err := c.Do(tt.args...)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.args, err)
+		}
+		if !reflect.DeepEqual(resp, tt.want) {
+			t.Errorf("%v = %q, want %q", tt.args, resp, tt.want)
+		}
+	}
+}
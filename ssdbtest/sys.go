@@ -0,0 +1,177 @@
+package ssdbtest
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Version is the server version reported by the version and info
+// commands.
+const Version = "1.9.7"
+
+func init() {
+	register("ping", 0, func(s *Server, args []string) []string { return ok() })
+	register("version", 0, func(s *Server, args []string) []string { return ok(Version) })
+	register("dbsize", 0, cmdDbsize)
+	register("info", 0, cmdInfo)
+
+	register("flushdb", 0, func(s *Server, args []string) []string {
+		s.reset()
+		return ok()
+	})
+	register("compact", 0, func(s *Server, args []string) []string { return ok() })
+	register("clear_binlog", 0, func(s *Server, args []string) []string { return ok() })
+	register("get_key_range", 0, func(s *Server, args []string) []string {
+		return ok(s.keyRange[0], s.keyRange[1])
+	})
+	register("set_key_range", 2, func(s *Server, args []string) []string {
+		s.keyRange = [2]string{args[0], args[1]}
+		return ok()
+	})
+	// The key range is not enforced, so there is nothing to ignore.
+	register("ignore_key_range", 0, func(s *Server, args []string) []string { return ok() })
+	register("list_allow_ip", 0, func(s *Server, args []string) []string { return ok(s.allowIP...) })
+	register("add_allow_ip", 1, func(s *Server, args []string) []string {
+		s.allowIP = addIP(s.allowIP, args[0])
+		return ok()
+	})
+	register("del_allow_ip", 1, func(s *Server, args []string) []string {
+		s.allowIP = delIP(s.allowIP, args[0])
+		return ok()
+	})
+	register("list_deny_ip", 0, func(s *Server, args []string) []string { return ok(s.denyIP...) })
+	register("add_deny_ip", 1, func(s *Server, args []string) []string {
+		s.denyIP = addIP(s.denyIP, args[0])
+		return ok()
+	})
+	register("del_deny_ip", 1, func(s *Server, args []string) []string {
+		s.denyIP = delIP(s.denyIP, args[0])
+		return ok()
+	})
+}
+
+// The IP lists are only recorded; connections are never refused.
+func addIP(list []string, ip string) []string {
+	for _, v := range list {
+		if v == ip {
+			return list
+		}
+	}
+	list = append(list, ip)
+	sort.Strings(list)
+	return list
+}
+
+func delIP(list []string, ip string) []string {
+	out := list[:0]
+	for _, v := range list {
+		if v != ip {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// size approximates the on-disk size the way ssdb-server does, from
+// the bytes of keys and values.
+func (s *Server) size() int64 {
+	var n int64
+	for k, v := range s.kv {
+		n += int64(len(k) + len(v))
+	}
+	for name, h := range s.hash {
+		for k, v := range h {
+			n += int64(len(name) + len(k) + len(v))
+		}
+	}
+	for name, z := range s.zset {
+		for k := range z {
+			n += int64(len(name) + len(k) + 8)
+		}
+	}
+	for name, q := range s.queue {
+		for _, v := range q {
+			n += int64(len(name) + len(v) + 8)
+		}
+	}
+	return n
+}
+
+func cmdDbsize(s *Server, args []string) []string {
+	return okInt(s.size())
+}
+
+// cmdInfo mimics the layout of ssdb-server's info reply: key-value
+// pairs whose values may be indented multi-line blocks. "info cmd"
+// adds per-command counters instead of the leveldb stats.
+func cmdInfo(s *Server, args []string) []string {
+	var total int64
+	for _, n := range s.calls {
+		total += n
+	}
+	var minSeq uint64
+	if len(s.binlogs) > 0 {
+		minSeq = s.binlogs[0].seq
+	}
+	s.connMu.Lock()
+	links := len(s.conns)
+	s.connMu.Unlock()
+
+	resp := ok("ssdb-server",
+		"version", Version,
+		"links", strconv.Itoa(links),
+		"total_calls", strconv.FormatInt(total, 10),
+		"dbsize", strconv.FormatInt(s.size(), 10),
+		"binlogs", fmt.Sprintf("    capacity : 20000000\n    min_seq  : %d\n    max_seq  : %d", minSeq, s.seq),
+		"serv_key_range", keyRangeBlock(s.keyRange[0], s.keyRange[1], "", "", "", "", "", ""),
+	)
+	kv := make([]string, 0, len(s.kv))
+	for k := range s.kv {
+		kv = append(kv, k)
+	}
+	kvStart, kvEnd := bounds(kv)
+	hStart, hEnd := bounds(mapNames(s.hash))
+	zStart, zEnd := bounds(zsetNames(s))
+	qStart, qEnd := bounds(queueNames(s))
+	resp = append(resp, "data_key_range", keyRangeBlock(kvStart, kvEnd, hStart, hEnd, zStart, zEnd, qStart, qEnd))
+
+	if len(args) > 0 && args[0] == "cmd" {
+		names := make([]string, 0, len(s.calls))
+		for name := range s.calls {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			resp = append(resp, "cmd."+name, fmt.Sprintf("calls: %d\ttime_wait: 0\ttime_proc: 0", s.calls[name]))
+		}
+		return resp
+	}
+	return append(resp, "leveldb.stats", strings.Join([]string{
+		"                               Compactions",
+		"Level  Files Size(MB) Time(sec) Read(MB) Write(MB)",
+		"--------------------------------------------------",
+		"  0        1        0         0        0         0",
+		"",
+	}, "\n"))
+}
+
+func bounds(names []string) (string, string) {
+	if len(names) == 0 {
+		return "", ""
+	}
+	sort.Strings(names)
+	return names[0], names[len(names)-1]
+}
+
+func keyRangeBlock(r ...string) string {
+	var b strings.Builder
+	for i, name := range []string{"kv  ", "hash", "zset", "list"} {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "    %s: %q - %q", name, r[2*i], r[2*i+1])
+	}
+	return b.String()
+}
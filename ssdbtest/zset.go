@@ -0,0 +1,408 @@
+package ssdbtest
+
+import (
+	"math"
+	"sort"
+	"strconv"
+)
+
+func init() {
+	register("zset", 3, cmdZset)
+	register("zget", 2, cmdZget)
+	register("zdel", 2, cmdZdel)
+	register("zincr", 2, cmdZincr)
+	register("zexists", 2, cmdZexists)
+	register("zsize", 1, cmdZsize)
+	register("zclear", 1, cmdZclear)
+	register("zlist", 3, cmdNames(zsetNames, false))
+	register("zrlist", 3, cmdNames(zsetNames, true))
+	register("zscan", 5, cmdZscan(false, true))
+	register("zrscan", 5, cmdZscan(true, true))
+	register("zkeys", 5, cmdZscan(false, false))
+	register("zrank", 2, cmdZrank(false))
+	register("zrrank", 2, cmdZrank(true))
+	register("zrange", 3, cmdZrange(false))
+	register("zrrange", 3, cmdZrange(true))
+	register("zcount", 3, cmdZcount)
+	register("zsum", 3, cmdZsum)
+	register("zavg", 3, cmdZavg)
+	register("zremrangebyrank", 3, cmdZremrangebyrank)
+	register("zremrangebyscore", 3, cmdZremrangebyscore)
+	register("zpop_front", 2, cmdZpop(false))
+	register("zpop_back", 2, cmdZpop(true))
+	register("multi_zset", 3, cmdMultiZset)
+	register("multi_zget", 2, cmdMultiZget)
+	register("multi_zdel", 2, cmdMultiZdel)
+}
+
+type zitem struct {
+	key   string
+	score int64
+}
+
+func zsetNames(s *Server) []string {
+	names := make([]string, 0, len(s.zset))
+	for k := range s.zset {
+		names = append(names, k)
+	}
+	return names
+}
+
+// sorted returns the items of a zset ordered by score, then key.
+func (s *Server) sorted(name string) []zitem {
+	z := s.zset[name]
+	items := make([]zitem, 0, len(z))
+	for k, v := range z {
+		items = append(items, zitem{k, v})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].score != items[j].score {
+			return items[i].score < items[j].score
+		}
+		return items[i].key < items[j].key
+	})
+	return items
+}
+
+func (s *Server) zsetKey(name, key string, score int64) bool {
+	z := s.zset[name]
+	if z == nil {
+		z = make(map[string]int64)
+		s.zset[name] = z
+	}
+	_, existed := z[key]
+	z[key] = score
+	return !existed
+}
+
+func (s *Server) zdelKey(name, key string) bool {
+	z := s.zset[name]
+	if _, found := z[key]; !found {
+		return false
+	}
+	delete(z, key)
+	if len(z) == 0 {
+		delete(s.zset, name)
+	}
+	return true
+}
+
+func formatScore(n int64) string {
+	return strconv.FormatInt(n, 10)
+}
+
+// parseScore parses a score like ssdb does with strtoll: scores are
+// int64, the leading integer is used and anything after it, such as
+// the fraction of "98.5", is ignored. Out of range values are clamped.
+// A string without a leading integer is invalid.
+func parseScore(s string) (int64, bool) {
+	end := 0
+	if end < len(s) && (s[end] == '-' || s[end] == '+') {
+		end++
+	}
+	digits := end
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	if end == digits {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(s[:end], 10, 64)
+	if err != nil && s[0] == '-' {
+		return math.MinInt64, true
+	} else if err != nil {
+		return math.MaxInt64, true
+	}
+	return n, true
+}
+
+// parseBound parses a score range bound, an empty string is the
+// smallest score when sign is negative and the largest otherwise.
+func parseBound(s string, sign int) (int64, bool) {
+	if s == "" {
+		if sign < 0 {
+			return math.MinInt64, true
+		}
+		return math.MaxInt64, true
+	}
+	return parseScore(s)
+}
+
+func cmdZset(s *Server, args []string) []string {
+	score, valid := parseScore(args[2])
+	if !valid {
+		return errValue("invalid score")
+	}
+	return okBool(s.zsetKey(args[0], args[1], score))
+}
+
+func cmdZget(s *Server, args []string) []string {
+	v, found := s.zset[args[0]][args[1]]
+	if !found {
+		return notFound()
+	}
+	return ok(formatScore(v))
+}
+
+func cmdZdel(s *Server, args []string) []string {
+	return okBool(s.zdelKey(args[0], args[1]))
+}
+
+func cmdZincr(s *Server, args []string) []string {
+	by := int64(1)
+	if len(args) > 2 {
+		n, valid := parseScore(args[2])
+		if !valid {
+			return errValue("invalid score")
+		}
+		by = n
+	}
+	cur := s.zset[args[0]][args[1]] + by
+	s.zsetKey(args[0], args[1], cur)
+	return ok(formatScore(cur))
+}
+
+func cmdZexists(s *Server, args []string) []string {
+	_, found := s.zset[args[0]][args[1]]
+	return okBool(found)
+}
+
+func cmdZsize(s *Server, args []string) []string {
+	return okInt(int64(len(s.zset[args[0]])))
+}
+
+func cmdZclear(s *Server, args []string) []string {
+	n := len(s.zset[args[0]])
+	delete(s.zset, args[0])
+	return okInt(int64(n))
+}
+
+// cmdZscan implements zscan, zrscan and zkeys: the items with
+// (score == score_start && key > key_start || score > score_start) and
+// score <= score_end, in the other direction for zrscan.
+func cmdZscan(reverse, withScores bool) func(s *Server, args []string) []string {
+	return func(s *Server, args []string) []string {
+		keyStart := args[1]
+		sign := -1
+		if reverse {
+			sign = 1
+		}
+		scoreStart, valid := parseBound(args[2], sign)
+		scoreEnd, valid2 := parseBound(args[3], -sign)
+		limit, valid3 := parseInt(args[4])
+		if !valid || !valid2 || !valid3 {
+			return errArgs()
+		}
+
+		items := s.sorted(args[0])
+		if reverse {
+			reverseItems(items)
+		}
+		resp := ok()
+		var n int64
+		for _, it := range items {
+			if limit >= 0 && n >= limit {
+				break
+			}
+			if !reverse {
+				if it.score < scoreStart || it.score > scoreEnd {
+					continue
+				}
+				if it.score == scoreStart && keyStart != "" && it.key <= keyStart {
+					continue
+				}
+			} else {
+				if it.score > scoreStart || it.score < scoreEnd {
+					continue
+				}
+				if it.score == scoreStart && keyStart != "" && it.key >= keyStart {
+					continue
+				}
+			}
+			resp = append(resp, it.key)
+			if withScores {
+				resp = append(resp, formatScore(it.score))
+			}
+			n++
+		}
+		return resp
+	}
+}
+
+func cmdZrank(reverse bool) func(s *Server, args []string) []string {
+	return func(s *Server, args []string) []string {
+		items := s.sorted(args[0])
+		if reverse {
+			reverseItems(items)
+		}
+		for i, it := range items {
+			if it.key == args[1] {
+				return okInt(int64(i))
+			}
+		}
+		return notFound()
+	}
+}
+
+func cmdZrange(reverse bool) func(s *Server, args []string) []string {
+	return func(s *Server, args []string) []string {
+		offset, valid := parseInt(args[1])
+		limit, valid2 := parseInt(args[2])
+		if !valid || !valid2 || offset < 0 {
+			return errArgs()
+		}
+		items := s.sorted(args[0])
+		if reverse {
+			reverseItems(items)
+		}
+		resp := ok()
+		for i := offset; i < int64(len(items)) && (limit < 0 || i < offset+limit); i++ {
+			resp = append(resp, items[i].key, formatScore(items[i].score))
+		}
+		return resp
+	}
+}
+
+// scoreRange returns the items with start <= score <= end.
+func (s *Server) scoreRange(args []string) ([]zitem, bool) {
+	start, valid := parseBound(args[1], -1)
+	end, valid2 := parseBound(args[2], 1)
+	if !valid || !valid2 {
+		return nil, false
+	}
+	var out []zitem
+	for _, it := range s.sorted(args[0]) {
+		if it.score >= start && it.score <= end {
+			out = append(out, it)
+		}
+	}
+	return out, true
+}
+
+func cmdZcount(s *Server, args []string) []string {
+	items, valid := s.scoreRange(args)
+	if !valid {
+		return errArgs()
+	}
+	return okInt(int64(len(items)))
+}
+
+func cmdZsum(s *Server, args []string) []string {
+	items, valid := s.scoreRange(args)
+	if !valid {
+		return errArgs()
+	}
+	var sum int64
+	for _, it := range items {
+		sum += it.score
+	}
+	return ok(formatScore(sum))
+}
+
+func cmdZavg(s *Server, args []string) []string {
+	items, valid := s.scoreRange(args)
+	if !valid {
+		return errArgs()
+	}
+	// ssdb sums the int64 scores, the average is a double
+	var sum int64
+	for _, it := range items {
+		sum += it.score
+	}
+	var avg float64
+	if len(items) > 0 {
+		avg = float64(sum) / float64(len(items))
+	}
+	return ok(strconv.FormatFloat(avg, 'f', -1, 64))
+}
+
+func cmdZremrangebyrank(s *Server, args []string) []string {
+	start, valid := parseInt(args[1])
+	end, valid2 := parseInt(args[2])
+	if !valid || !valid2 {
+		return errArgs()
+	}
+	items := s.sorted(args[0])
+	var n int64
+	for i, it := range items {
+		if int64(i) >= start && int64(i) <= end {
+			s.zdelKey(args[0], it.key)
+			n++
+		}
+	}
+	return okInt(n)
+}
+
+func cmdZremrangebyscore(s *Server, args []string) []string {
+	items, valid := s.scoreRange(args)
+	if !valid {
+		return errArgs()
+	}
+	for _, it := range items {
+		s.zdelKey(args[0], it.key)
+	}
+	return okInt(int64(len(items)))
+}
+
+func cmdZpop(back bool) func(s *Server, args []string) []string {
+	return func(s *Server, args []string) []string {
+		limit, valid := parseInt(args[1])
+		if !valid {
+			return errArgs()
+		}
+		items := s.sorted(args[0])
+		if back {
+			reverseItems(items)
+		}
+		resp := ok()
+		for i := 0; i < len(items) && int64(i) < limit; i++ {
+			s.zdelKey(args[0], items[i].key)
+			resp = append(resp, items[i].key, formatScore(items[i].score))
+		}
+		return resp
+	}
+}
+
+func cmdMultiZset(s *Server, args []string) []string {
+	if len(args)%2 != 1 {
+		return errArgs()
+	}
+	var n int64
+	for i := 1; i < len(args); i += 2 {
+		score, valid := parseScore(args[i+1])
+		if !valid {
+			return errValue("invalid score")
+		}
+		if s.zsetKey(args[0], args[i], score) {
+			n++
+		}
+	}
+	return okInt(n)
+}
+
+func cmdMultiZget(s *Server, args []string) []string {
+	z := s.zset[args[0]]
+	resp := ok()
+	for _, k := range args[1:] {
+		if v, found := z[k]; found {
+			resp = append(resp, k, formatScore(v))
+		}
+	}
+	return resp
+}
+
+func cmdMultiZdel(s *Server, args []string) []string {
+	var n int64
+	for _, k := range args[1:] {
+		if s.zdelKey(args[0], k) {
+			n++
+		}
+	}
+	return okInt(n)
+}
+
+func reverseItems(items []zitem) {
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+}
@@ -1,23 +1,20 @@
 package gossdb_client
 
-import (
-	"fmt"
-)
-
 //生成通过的错误信息，已经确定是有错误
+//服务端返回的错误状态码已经在 do 中转换为 *CommandError, 这里只会遇到状态码为 ok 但格式不对的响应
 func handError(resp []string, paras ...interface{}) error {
-	if len(resp) < 1 {
-		return fmt.Errorf("ssdb respone error")
-	}
-	//正常返回的不存在不报错，如果要捕捉这个问题请使用exists
-	if resp[0] == "not_found" {
-		return nil
-	}
-	if len(paras) > 0 {
-		return fmt.Errorf("access ssdb error, code is %v, parameter is %v", resp, paras)
-	} else {
-		return fmt.Errorf("access ssdb error, code is %v", resp)
+	e := &CommandError{Args: paras, Err: ErrProtocol}
+	if len(resp) > 0 {
+		e.Code = resp[0]
 	}
+	return e
 }
 
-
+//同 handError, 用于 []byte 形式的响应
+func handErrorBytes(resp [][]byte, paras ...interface{}) error {
+	e := &CommandError{Args: paras, Err: ErrProtocol}
+	if len(resp) > 0 {
+		e.Code = string(resp[0])
+	}
+	return e
+}
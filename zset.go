@@ -1,7 +1,6 @@
 package gossdb_client
 
 import (
-	"fmt"
 	"strconv"
 	"github.com/houbin910902/to"
 )
@@ -12,9 +11,9 @@ import (
 //  score 整数, key 对应的权重值
 //  返回 err, 可能的错误, 操作成功返回 nil
 func (c *DbClient) ZSet(setName, key string, score int64) (err error) {
-	resp, err := c.Client.Do("zset", setName, key, score)
+	resp, err := c.do("zset", setName, key, score)
 	if err != nil {
-		return fmt.Errorf("ZSet %s %s error: %s", setName, key, err.Error())
+		return err
 	}
 	if len(resp) > 0 && resp[0] == "ok" {
 		return nil
@@ -30,9 +29,9 @@ func (c *DbClient) ZSet(setName, key string, score int64) (err error) {
 //  返回 score 整数, key 对应的权重值
 //  返回 err, 可能的错误, 操作成功返回 nil
 func (c *DbClient) ZGet(setName, key string) (score int64, err error) {
-	resp, err := c.Client.Do("zget", setName, key)
+	resp, err := c.do("zget", setName, key)
 	if err != nil {
-		return 0, fmt.Errorf("ZGet %s/%s error: %s", setName, key, err.Error())
+		return 0, err
 	}
 	if len(resp) == 2 && resp[0] == "ok" {
 		return strconv.ParseInt(resp[1], 10, 64)
@@ -46,9 +45,9 @@ func (c *DbClient) ZGet(setName, key string) (score int64, err error) {
 //  key zset 中的 key.
 //  返回 err, 可能的错误, 操作成功返回 nil
 func (c *DbClient) ZDel(setName, key string) (err error) {
-	resp, err := c.Client.Do("zdel", setName, key)
+	resp, err := c.do("zdel", setName, key)
 	if err != nil {
-		return fmt.Errorf("ZDel %s/%s error: %s", setName, key, err.Error())
+		return err
 	}
 	if len(resp) > 0 && resp[0] == "ok" {
 		return nil
@@ -62,9 +61,9 @@ func (c *DbClient) ZDel(setName, key string) (err error) {
 //  返回 re 如果存在, 返回 true, 否则返回 false.
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) ZExists(setName, key string) (re bool, err error) {
-	resp, err := c.Client.Do("zexists", setName, key)
+	resp, err := c.do("zexists", setName, key)
 	if err != nil {
-		return false, fmt.Errorf("ZExists %s/%s error: %s", setName, key, err.Error())
+		return false, err
 	}
 
 	if len(resp) == 2 && resp[0] == "ok" {
@@ -80,9 +79,9 @@ func (c *DbClient) ZExists(setName, key string) (re bool, err error) {
 //  返回 count 返回符合条件的 key 的数量.
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) ZCount(setName string, start, end interface{}) (count int64, err error) {
-	resp, err := c.Client.Do("zcount", setName, start, end)
+	resp, err := c.do("zcount", setName, start, end)
 	if err != nil {
-		return -1, fmt.Errorf("ZCount %s %v %v error: %s", setName, start, end, err)
+		return -1, err
 	}
 
 	if len(resp) == 2 && resp[0] == "ok" {
@@ -95,9 +94,9 @@ func (c *DbClient) ZCount(setName string, start, end interface{}) (count int64,
 //  setName zset名称
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) ZClear(setName string) (err error) {
-	resp, err := c.Client.Do("zclear", setName)
+	resp, err := c.do("zclear", setName)
 	if err != nil {
-		return fmt.Errorf("%s ZClear %s error: %s", err, setName, err.Error())
+		return err
 	}
 
 	if len(resp) > 0 && resp[0] == "ok" {
@@ -120,10 +119,10 @@ func (c *DbClient) ZClear(setName string) (err error) {
 //  返回 scores 返回符合条件的 key 对应的权重.
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) ZScan(setName string, keyStart string, scoreStart, scoreEnd interface{}, limit int64) (keys []string, scores []int64, err error) {
-	resp, err := c.Client.Do("zscan", setName, keyStart, scoreStart, scoreEnd, limit)
+	resp, err := c.do("zscan", setName, keyStart, scoreStart, scoreEnd, limit)
 
 	if err != nil {
-		return nil, nil, fmt.Errorf("ZScan %s %v %v %v %v error: %s", setName, keyStart, scoreStart, scoreEnd, limit, err.Error())
+		return nil, nil, err
 	}
 	if len(resp) > 0 && resp[0] == "ok" {
 		size := len(resp)
@@ -132,7 +131,7 @@ func (c *DbClient) ZScan(setName string, keyStart string, scoreStart, scoreEnd i
 
 		for i := 1; i < size-1; i += 2 {
 			keys = append(keys, resp[i])
-			sco, _:= strconv.ParseInt(resp[1], 10, 64)
+			sco, _:= strconv.ParseInt(resp[i+1], 10, 64)
 			scores = append(scores, sco)
 		}
 		return keys, scores, nil
@@ -150,10 +149,10 @@ func (c *DbClient) ZScan(setName string, keyStart string, scoreStart, scoreEnd i
 //  返回 scores 返回符合条件的 key 对应的权重.
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) ZrScan(setName string, keyStart string, scoreStart, scoreEnd interface{}, limit int64) (keys []string, scores []int64, err error) {
-	resp, err := c.Client.Do("zrscan", setName, keyStart, scoreStart, scoreEnd, limit)
+	resp, err := c.do("zrscan", setName, keyStart, scoreStart, scoreEnd, limit)
 
 	if err != nil {
-		return nil, nil, fmt.Errorf("ZrScan %s %v %v %v %v error: %s", setName, keyStart, scoreStart, scoreEnd, limit, err.Error())
+		return nil, nil, err
 	}
 
 	if len(resp) > 0 && resp[0] == "ok" {
@@ -163,7 +162,7 @@ func (c *DbClient) ZrScan(setName string, keyStart string, scoreStart, scoreEnd
 
 		for i := 1; i < size-1; i += 2 {
 			keys = append(keys, resp[i])
-			sco, _:= strconv.ParseInt(resp[1], 10, 64)
+			sco, _:= strconv.ParseInt(resp[i+1], 10, 64)
 			scores = append(scores, sco)
 		}
 		return keys, scores, nil
@@ -183,10 +182,10 @@ func (c *DbClient) MultiZSet(setName string, kvs map[string]int64) (err error) {
 		args = append(args, k)
 		args = append(args, v)
 	}
-	resp, err := c.Client.Do("multi_zset", setName, args)
+	resp, err := c.do("multi_zset", setName, args)
 
 	if err != nil {
-		return fmt.Errorf("MultiZset %s %v error: %s", setName, kvs, err.Error())
+		return err
 	}
 
 	if len(resp) > 0 && resp[0] == "ok" {
@@ -205,10 +204,10 @@ func (c *DbClient) MultiZGet(setName string, key ...string) (val map[string]int6
 	if len(key) == 0 {
 		return make(map[string]int64), nil
 	}
-	resp, err := c.Client.Do("multi_zget", setName, key)
+	resp, err := c.do("multi_zget", setName, key)
 
 	if err != nil {
-		return nil, fmt.Errorf("MultiZget %s %s error: %s", setName, key, err.Error())
+		return nil, err
 	}
 	size := len(resp)
 	if size > 0 && resp[0] == "ok" {
@@ -233,10 +232,10 @@ func (c *DbClient) MultiZGetSlice(setName string, key ...string) (keys []string,
 	if len(key) == 0 {
 		return []string{}, []int64{}, nil
 	}
-	resp, err := c.Client.Do("multi_zget", setName, key)
+	resp, err := c.do("multi_zget", setName, key)
 
 	if err != nil {
-		return nil, nil, fmt.Errorf("MultiZGetSlice %s %s error: %s", setName, key, err.Error())
+		return nil, nil, err
 	}
 
 	size := len(resp)
@@ -263,10 +262,10 @@ func (c *DbClient) MultiZGetArray(setName string, key []string) (val map[string]
 	if len(key) == 0 {
 		return make(map[string]int64), nil
 	}
-	resp, err := c.Client.Do("multi_zget", setName, key)
+	resp, err := c.do("multi_zget", setName, key)
 
 	if err != nil {
-		return nil, fmt.Errorf("MultiZGetArray %s %s error: %s", setName, key, err.Error())
+		return nil, err
 	}
 	size := len(resp)
 	if size > 0 && resp[0] == "ok" {
@@ -290,10 +289,10 @@ func (c *DbClient) MultiZGetSliceArray(setName string, key []string) (keys []str
 	if len(key) == 0 {
 		return []string{}, []int64{}, nil
 	}
-	resp, err := c.Client.Do("multi_zget", setName, key)
+	resp, err := c.do("multi_zget", setName, key)
 
 	if err != nil {
-		return nil, nil, fmt.Errorf("MultiZGetSliceArray %s %s error: %s", setName, key, err.Error())
+		return nil, nil, err
 	}
 
 	size := len(resp)
@@ -321,10 +320,10 @@ func (c *DbClient) MultiZDel(setName string, key ...string) (err error) {
 	if len(key) == 0 {
 		return nil
 	}
-	resp, err := c.Client.Do("multi_zdel", key)
+	resp, err := c.do("multi_zdel", setName, key)
 
 	if err != nil {
-		return fmt.Errorf("MultiZDel %s %s error: %s", setName, key, err.Error())
+		return err
 	}
 
 	if len(resp) > 0 && resp[0] == "ok" {
@@ -344,9 +343,9 @@ func (c *DbClient) ZIncR(setName string, key string, num int64) (int64, error) {
 	if len(key) == 0 {
 		return 0, nil
 	}
-	resp, err := c.Client.Do("zincr", setName, key, num)
+	resp, err := c.do("zincr", setName, key, num)
 	if err != nil {
-		return 0, fmt.Errorf("ZIncR %s %s %v error: %s", setName, key, num, err.Error())
+		return 0, err
 	}
 
 	if len(resp) > 1 && resp[0] == "ok" {
@@ -363,9 +362,9 @@ func (c *DbClient) ZIncR(setName string, key string, num int64) (int64, error) {
 //  返回 []string 返回包含名字的slice.
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) ZList(nameStart, nameEnd string, limit int64) ([]string, error) {
-	resp, err := c.Client.Do("zlist", nameStart, nameEnd, limit)
+	resp, err := c.do("zlist", nameStart, nameEnd, limit)
 	if err != nil {
-		return nil, fmt.Errorf("ZList %s %s %v error: %s", nameStart, nameEnd, limit, err.Error())
+		return nil, err
 	}
 	if len(resp) > 0 && resp[0] == "ok" {
 		size := len(resp)
@@ -385,9 +384,9 @@ func (c *DbClient) ZList(nameStart, nameEnd string, limit int64) ([]string, erro
 //  返回 val 返回包含名字元素的个数.
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) ZSize(name string) (val int64, err error) {
-	resp, err := c.Client.Do("zsize", name)
+	resp, err := c.do("zsize", name)
 	if err != nil {
-		return 0, fmt.Errorf("ZSize %s  error: %s", name, err.Error())
+		return 0, err
 	}
 
 	if len(resp) > 0 && resp[0] == "ok" {
@@ -408,10 +407,10 @@ func (c *DbClient) ZSize(name string) (val int64, err error) {
 //  返回 scores 返回符合条件的 key 对应的权重.
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) ZKeys(setName string, keyStart string, scoreStart, scoreEnd interface{}, limit int64) (keys []string, err error) {
-	resp, err := c.Client.Do("zkeys", setName, keyStart, scoreStart, scoreEnd, limit)
+	resp, err := c.do("zkeys", setName, keyStart, scoreStart, scoreEnd, limit)
 
 	if err != nil {
-		return nil, fmt.Errorf("ZKeys %s %v %v %v %v error: %s", setName, keyStart, scoreStart, scoreEnd, limit, err.Error())
+		return nil, err
 	}
 	if len(resp) > 0 && resp[0] == "ok" {
 		size := len(resp)
@@ -432,10 +431,10 @@ func (c *DbClient) ZKeys(setName string, keyStart string, scoreStart, scoreEnd i
 //  返回 val 符合条件的 score 的求和
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) ZSum(setName string, scoreStart, scoreEnd interface{}) (val int64, err error) {
-	resp, err := c.Client.Do("zsum", setName, scoreStart, scoreEnd)
+	resp, err := c.do("zsum", setName, scoreStart, scoreEnd)
 
 	if err != nil {
-		return 0, fmt.Errorf("ZSum %s %v %v  error: %s", setName, scoreStart, scoreEnd, err.Error())
+		return 0, err
 	}
 	if len(resp) > 0 && resp[0] == "ok" {
 		val = to.Int64(resp[1])
@@ -452,10 +451,10 @@ func (c *DbClient) ZSum(setName string, scoreStart, scoreEnd interface{}) (val i
 //  返回 val 符合条件的 score 的平均值
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) ZAvg(setName string, scoreStart, scoreEnd interface{}) (val int64, err error) {
-	resp, err := c.Client.Do("zavg", setName, scoreStart, scoreEnd)
+	resp, err := c.do("zavg", setName, scoreStart, scoreEnd)
 
 	if err != nil {
-		return 0, fmt.Errorf("ZAvg %s %v %v  error: %s", setName, scoreStart, scoreEnd, err.Error())
+		return 0, err
 	}
 	if len(resp) > 0 && resp[0] == "ok" {
 		val = to.Int64(resp[1])
@@ -471,10 +470,10 @@ func (c *DbClient) ZAvg(setName string, scoreStart, scoreEnd interface{}) (val i
 //  返回 val 排名
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) ZRank(setName, key string) (val int64, err error) {
-	resp, err := c.Client.Do("zrank", setName, key)
+	resp, err := c.do("zrank", setName, key)
 
 	if err != nil {
-		return 0, fmt.Errorf("ZRank %s %s  error: %s", setName, key, err.Error())
+		return 0, err
 	}
 	if len(resp) > 0 && resp[0] == "ok" {
 		val = to.Int64(resp[1])
@@ -490,10 +489,10 @@ func (c *DbClient) ZRank(setName, key string) (val int64, err error) {
 //  返回 val 排名
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) ZRRank(setName, key string) (val int64, err error) {
-	resp, err := c.Client.Do("zrrank", setName, key)
+	resp, err := c.do("zrrank", setName, key)
 
 	if err != nil {
-		return 0, fmt.Errorf("ZRRank %s %s  error: %s", setName, key, err.Error())
+		return 0, err
 	}
 	if len(resp) > 0 && resp[0] == "ok" {
 		val = to.Int64(resp[1])
@@ -510,10 +509,10 @@ func (c *DbClient) ZRRank(setName, key string) (val int64, err error) {
 //  返回 val 排名
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) ZRange(setName string, offset, limit int64) (val map[string]int64, err error) {
-	resp, err := c.Client.Do("zrange", setName, offset, limit)
+	resp, err := c.do("zrange", setName, offset, limit)
 
 	if err != nil {
-		return nil, fmt.Errorf("ZRange %s %v %v  error: %s", setName, offset, limit, err.Error())
+		return nil, err
 	}
 	if len(resp) > 0 && resp[0] == "ok" {
 		val = make(map[string]int64)
@@ -534,10 +533,10 @@ func (c *DbClient) ZRange(setName string, offset, limit int64) (val map[string]i
 //  返回 val 排名
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) ZRangeSlice(setName string, offset, limit int64) (key []string, val []int64, err error) {
-	resp, err := c.Client.Do("zrange", setName, offset, limit)
+	resp, err := c.do("zrange", setName, offset, limit)
 
 	if err != nil {
-		return nil, nil, fmt.Errorf("ZRangeSlice %s %v %v  error: %s", setName, offset, limit, err.Error())
+		return nil, nil, err
 	}
 	if len(resp) > 0 && resp[0] == "ok" {
 		val = []int64{}
@@ -560,10 +559,10 @@ func (c *DbClient) ZRangeSlice(setName string, offset, limit int64) (key []strin
 //  返回 val 排名
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) ZRRange(setName string, offset, limit int64) (val map[string]int64, err error) {
-	resp, err := c.Client.Do("zrrange", setName, offset, limit)
+	resp, err := c.do("zrrange", setName, offset, limit)
 
 	if err != nil {
-		return nil, fmt.Errorf("ZRRange %s %v %v error: %s", setName, offset, limit, err.Error())
+		return nil, err
 	}
 	if len(resp) > 0 && resp[0] == "ok" {
 		val = make(map[string]int64)
@@ -585,10 +584,10 @@ func (c *DbClient) ZRRange(setName string, offset, limit int64) (val map[string]
 //  返回 val 排名
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) ZRRangeSlice(setName string, offset, limit int64) (key []string, val []int64, err error) {
-	resp, err := c.Client.Do("zrrange", setName, offset, limit)
+	resp, err := c.do("zrrange", setName, offset, limit)
 
 	if err != nil {
-		return nil, nil, fmt.Errorf("ZRRangeSlice %s %v %v error: %s", setName, offset, limit, err.Error())
+		return nil, nil, err
 	}
 	if len(resp) > 0 && resp[0] == "ok" {
 		val = []int64{}
@@ -610,10 +609,10 @@ func (c *DbClient) ZRRangeSlice(setName string, offset, limit int64) (key []stri
 //  end  区间结束，包含end值
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) ZRemRangeByRank(setName string, start, end int64) (err error) {
-	resp, err := c.Client.Do("zremrangebyrank", setName, start, end)
+	resp, err := c.do("zremrangebyrank", setName, start, end)
 
 	if err != nil {
-		return fmt.Errorf("ZRemRangeByRank %s %v %v error: %s", setName, start, end, err.Error())
+		return err
 	}
 	if len(resp) > 0 && resp[0] == "ok" {
 		return nil
@@ -627,10 +626,10 @@ func (c *DbClient) ZRemRangeByRank(setName string, start, end int64) (err error)
 //  end  区间结束，包含end值
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) ZRemRangeByScore(setName string, start, end int64) (err error) {
-	resp, err := c.Client.Do("zremrangebyscore", setName, start, end)
+	resp, err := c.do("zremrangebyscore", setName, start, end)
 
 	if err != nil {
-		return fmt.Errorf("ZRemRangeByScore %s %v %v  error: %s", setName, start, end, err.Error())
+		return err
 	}
 	if len(resp) > 0 && resp[0] == "ok" {
 		return nil
@@ -644,10 +643,10 @@ func (c *DbClient) ZRemRangeByScore(setName string, start, end int64) (err error
 //  返回 包含 key-score 的map
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) ZPopFront(setName string, limit int64) (val map[string]int64, err error) {
-	resp, err := c.Client.Do("zpop_front", setName, limit)
+	resp, err := c.do("zpop_front", setName, limit)
 
 	if err != nil {
-		return nil, fmt.Errorf("ZPopFront %s %v  error: %s", setName, limit, err.Error())
+		return nil, err
 	}
 	size := len(resp)
 	if size > 0 && resp[0] == "ok" {
@@ -666,10 +665,10 @@ func (c *DbClient) ZPopFront(setName string, limit int64) (val map[string]int64,
 //  返回 包含 key-score 的map
 //  返回 err，可能的错误，操作成功返回 nil
 func (c *DbClient) ZPopBack(setName string, limit int64) (val map[string]int64, err error) {
-	resp, err := c.Client.Do("zpop_back", setName, limit)
+	resp, err := c.do("zpop_back", setName, limit)
 
 	if err != nil {
-		return nil, fmt.Errorf("ZPopBack %s %v  error: %s", setName, limit, err.Error())
+		return nil, err
 	}
 	size := len(resp)
 	if size > 0 && resp[0] == "ok" {
@@ -0,0 +1,391 @@
+package gossdb_client
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/houbin910902/gossdb_client/gossdb/ssdb"
+)
+
+//  zset 权重区间的一个边界, 用于 *Float 系列方法.
+//  Value 为 ±Inf 时表示无穷, Exclusive 为 true 时不包含边界值本身.
+//  ssdb 的权重是 int64, 区间只支持闭区间: 小数的边界向区间内部取整, 开区间的整数边界向区间内部移动 1,
+//  超出 int64 的边界按 int64 的最小值或最大值处理. Value 为 NaN 时命令返回 ssdb.ErrBadArguments
+type ScoreBound struct {
+	Value     float64
+	Exclusive bool
+}
+
+var (
+	//  负无穷, 对应协议中的空字符串
+	NegInf = ScoreBound{Value: math.Inf(-1)}
+	//  正无穷, 对应协议中的空字符串
+	PosInf = ScoreBound{Value: math.Inf(1)}
+)
+
+//  包含 score 的边界
+func Inclusive(score float64) ScoreBound {
+	return ScoreBound{Value: score}
+}
+
+//  不包含 score 的边界
+func Exclusive(score float64) ScoreBound {
+	return ScoreBound{Value: score, Exclusive: true}
+}
+
+//  转换为命令参数. inward 为区间内部相对这个边界的方向, 下界为 +1, 上界为 -1
+func (b ScoreBound) arg(inward float64) (string, error) {
+	v := b.Value
+	if math.IsNaN(v) {
+		return "", fmt.Errorf("%w: score bound is NaN", ssdb.ErrBadArguments)
+	}
+	if math.IsInf(v, 0) && math.Signbit(v) == (inward > 0) {
+		// 区间外侧的无穷, 即 -inf 下界或 +inf 上界
+		return "", nil
+	}
+	r := math.Floor(v)
+	if inward > 0 {
+		r = math.Ceil(v)
+	}
+	var n int64
+	switch {
+	case r < -(1 << 63):
+		return strconv.FormatInt(math.MinInt64, 10), nil
+	case r >= 1<<63:
+		return strconv.FormatInt(math.MaxInt64, 10), nil
+	default:
+		n = int64(r)
+	}
+	// 小数的边界取整后已经不包含边界值本身
+	if b.Exclusive && r == v {
+		if inward > 0 && n < math.MaxInt64 {
+			n++
+		} else if inward < 0 && n > math.MinInt64 {
+			n--
+		}
+	}
+	return strconv.FormatInt(n, 10), nil
+}
+
+//  转换区间的两个边界, inward 为 start 一侧的方向, 参见 ScoreBound.arg
+func scoreBounds(start, end ScoreBound, inward float64) (string, string, error) {
+	s, err := start.arg(inward)
+	if err != nil {
+		return "", "", err
+	}
+	e, err := end.arg(-inward)
+	return s, e, err
+}
+
+//  权重转换为命令参数. ssdb 的权重是 int64, 带小数或超出 int64 的权重返回 ssdb.ErrBadArguments
+func scoreArg(score float64) (string, error) {
+	if score != math.Trunc(score) || score < -(1<<63) || score >= 1<<63 {
+		return "", fmt.Errorf("%w: score %v is not an int64", ssdb.ErrBadArguments, score)
+	}
+	return strconv.FormatInt(int64(score), 10), nil
+}
+
+//  权重转换为字符串, 用作遍历的游标
+func formatScore(score float64) string {
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
+
+//  解析服务端返回的权重, 格式不对时返回 ErrProtocol
+func parseScore(s string, paras ...interface{}) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, &CommandError{Args: paras, Msg: s, Err: ErrProtocol}
+	}
+	return f, nil
+}
+
+//  解析 key-score 交替排列的响应
+func parseKeyScores(resp []string, paras ...interface{}) (keys []string, scores []float64, err error) {
+	size := len(resp)
+	keys = make([]string, 0, (size-1)/2)
+	scores = make([]float64, 0, (size-1)/2)
+	for i := 1; i+1 < size; i += 2 {
+		score, err := parseScore(resp[i+1], paras...)
+		if err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, resp[i])
+		scores = append(scores, score)
+	}
+	return keys, scores, nil
+}
+
+//  设置 zset 中指定 key 对应的权重值. ssdb 的权重是 int64, float64 只能精确表示 2^53 以内的整数.
+//  setName zset 名称
+//  key zset 中的 key.
+//  score key 对应的权重值, 带小数或超出 int64 时返回 ssdb.ErrBadArguments, 不会发出命令
+//  返回 err, 可能的错误, 操作成功返回 nil
+func (c *DbClient) ZSetFloat(setName, key string, score float64) (err error) {
+	s, err := scoreArg(score)
+	if err != nil {
+		return newCommandError([]interface{}{"zset", setName, key, score}, err)
+	}
+	resp, err := c.do("zset", setName, key, s)
+	if err != nil {
+		return err
+	}
+	if len(resp) > 0 && resp[0] == "ok" {
+		return nil
+	}
+	return handError(resp, setName, key)
+}
+
+//  获取zset中指定 key 对应的权重值.
+//  setName zset名称
+//  key zset 中的 key.
+//  返回 score key 对应的权重值, key 不存在时返回 ErrNotFound
+//  返回 err, 可能的错误, 操作成功返回 nil
+func (c *DbClient) ZGetFloat(setName, key string) (score float64, err error) {
+	resp, err := c.do("zget", setName, key)
+	if err != nil {
+		return 0, err
+	}
+	if len(resp) == 2 && resp[0] == "ok" {
+		return parseScore(resp[1], setName, key)
+	}
+	return 0, handError(resp, setName, key)
+}
+
+//  使 zset 中的 key 对应的值增加 num. 参数 num 可以为负数, 与 ZSetFloat 相同不能带小数.
+//  setName zset名称
+//  key 要增加权重的key
+//  num 要增加权重值
+//  返回 增加后的新权重值
+//  返回 err，可能的错误，操作成功返回 nil
+func (c *DbClient) ZIncRFloat(setName string, key string, num float64) (float64, error) {
+	s, err := scoreArg(num)
+	if err != nil {
+		return 0, newCommandError([]interface{}{"zincr", setName, key, num}, err)
+	}
+	resp, err := c.do("zincr", setName, key, s)
+	if err != nil {
+		return 0, err
+	}
+	if len(resp) > 1 && resp[0] == "ok" {
+		return parseScore(resp[1], setName, key)
+	}
+	return 0, handError(resp, setName, key)
+}
+
+//  批量设置 zset 中的 key-score.
+//  setName zset名称
+//  kvs 包含 key-score 的map, 任何一个权重带小数或超出 int64 时返回 ssdb.ErrBadArguments, 不会发出命令
+//  返回 err，可能的错误，操作成功返回 nil
+func (c *DbClient) MultiZSetFloat(setName string, kvs map[string]float64) (err error) {
+	if len(kvs) == 0 {
+		return nil
+	}
+	args := make([]string, 0, len(kvs)*2)
+	for k, v := range kvs {
+		s, err := scoreArg(v)
+		if err != nil {
+			return newCommandError([]interface{}{"multi_zset", setName, kvs}, err)
+		}
+		args = append(args, k, s)
+	}
+	resp, err := c.do("multi_zset", setName, args)
+	if err != nil {
+		return err
+	}
+	if len(resp) > 0 && resp[0] == "ok" {
+		return nil
+	}
+	return handError(resp, setName, kvs)
+}
+
+//  批量获取 zset 中的 key-score.
+//  setName zset名称
+//  key 要获取key的列表，支持多个key
+//  返回 val 包含 key-score 的map, 不存在的 key 不会出现在结果中
+//  返回 err，可能的错误，操作成功返回 nil
+func (c *DbClient) MultiZGetFloat(setName string, key ...string) (val map[string]float64, err error) {
+	if len(key) == 0 {
+		return make(map[string]float64), nil
+	}
+	resp, err := c.do("multi_zget", setName, key)
+	if err != nil {
+		return nil, err
+	}
+	keys, scores, err := parseKeyScores(resp, setName, key)
+	if err != nil {
+		return nil, err
+	}
+	val = make(map[string]float64, len(keys))
+	for i, k := range keys {
+		val[k] = scores[i]
+	}
+	return val, nil
+}
+
+//  列出 zset 中的 key-score 列表, 参见 ZScan.
+//  setName zset名称
+//  keyStart scoreStart 对应的 key, 为空时 scoreStart 按普通边界处理.
+//  scoreStart 返回 key 的最小权重值
+//  scoreEnd 返回 key 的最大权重值
+//  limit  最多返回这么多个元素.
+//  返回 keys 返回符合条件的 key 的数组.
+//  返回 scores 返回符合条件的 key 对应的权重.
+//  返回 err，可能的错误，操作成功返回 nil
+func (c *DbClient) ZScanFloat(setName string, keyStart string, scoreStart, scoreEnd ScoreBound, limit int64) (keys []string, scores []float64, err error) {
+	start, end, err := scoreBounds(scoreStart, scoreEnd, 1)
+	if err != nil {
+		return nil, nil, newCommandError([]interface{}{"zscan", setName, keyStart, scoreStart, scoreEnd, limit}, err)
+	}
+	return c.doKeyScores("zscan", setName, keyStart, start, end, limit)
+}
+
+//  列出 zset 中的 key-score 列表, 反向顺序, 参见 ZrScan.
+//  setName zset名称
+//  keyStart scoreStart 对应的 key.
+//  scoreStart 返回 key 的最大权重值
+//  scoreEnd 返回 key 的最小权重值
+//  limit  最多返回这么多个元素.
+//  返回 keys 返回符合条件的 key 的数组.
+//  返回 scores 返回符合条件的 key 对应的权重.
+//  返回 err，可能的错误，操作成功返回 nil
+func (c *DbClient) ZrScanFloat(setName string, keyStart string, scoreStart, scoreEnd ScoreBound, limit int64) (keys []string, scores []float64, err error) {
+	start, end, err := scoreBounds(scoreStart, scoreEnd, -1)
+	if err != nil {
+		return nil, nil, newCommandError([]interface{}{"zrscan", setName, keyStart, scoreStart, scoreEnd, limit}, err)
+	}
+	return c.doKeyScores("zrscan", setName, keyStart, start, end, limit)
+}
+
+//  列出 zset 中权重处于区间内的 key 列表, 参见 ZKeys.
+//  setName zset名称
+//  keyStart scoreStart 对应的 key.
+//  scoreStart 返回 key 的最小权重值
+//  scoreEnd 返回 key 的最大权重值
+//  limit  最多返回这么多个元素.
+//  返回 keys 返回符合条件的 key 的数组.
+//  返回 err，可能的错误，操作成功返回 nil
+func (c *DbClient) ZKeysFloat(setName string, keyStart string, scoreStart, scoreEnd ScoreBound, limit int64) (keys []string, err error) {
+	start, end, err := scoreBounds(scoreStart, scoreEnd, 1)
+	if err != nil {
+		return nil, newCommandError([]interface{}{"zkeys", setName, keyStart, scoreStart, scoreEnd, limit}, err)
+	}
+	return c.ZKeys(setName, keyStart, start, end, limit)
+}
+
+//  返回权重处于区间 [start,end] 的 key 数量.
+//  setName zset名称
+//  start key 的最小权重值
+//  end key 的最大权重值
+//  返回 count 返回符合条件的 key 的数量.
+//  返回 err，可能的错误，操作成功返回 nil
+func (c *DbClient) ZCountFloat(setName string, start, end ScoreBound) (count int64, err error) {
+	s, e, err := scoreBounds(start, end, 1)
+	if err != nil {
+		return 0, newCommandError([]interface{}{"zcount", setName, start, end}, err)
+	}
+	return c.ZCount(setName, s, e)
+}
+
+//  返回权重处于区间 [start,end] 的 score 的和.
+//  setName zset名称
+//  start key 的最小权重值
+//  end key 的最大权重值
+//  返回 val 符合条件的 score 的求和
+//  返回 err，可能的错误，操作成功返回 nil
+func (c *DbClient) ZSumFloat(setName string, start, end ScoreBound) (val float64, err error) {
+	return c.zaggFloat("zsum", setName, start, end)
+}
+
+//  返回权重处于区间 [start,end] 的 score 的平均值.
+//  setName zset名称
+//  start key 的最小权重值
+//  end key 的最大权重值
+//  返回 val 符合条件的 score 的平均值
+//  返回 err，可能的错误，操作成功返回 nil
+func (c *DbClient) ZAvgFloat(setName string, start, end ScoreBound) (val float64, err error) {
+	return c.zaggFloat("zavg", setName, start, end)
+}
+
+func (c *DbClient) zaggFloat(cmd, setName string, start, end ScoreBound) (float64, error) {
+	s, e, err := scoreBounds(start, end, 1)
+	if err != nil {
+		return 0, newCommandError([]interface{}{cmd, setName, start, end}, err)
+	}
+	resp, err := c.do(cmd, setName, s, e)
+	if err != nil {
+		return 0, err
+	}
+	if len(resp) > 1 && resp[0] == "ok" {
+		return parseScore(resp[1], setName, start, end)
+	}
+	return 0, handError(resp, setName, start, end)
+}
+
+//  删除权重处于区间 [start,end] 的元素.
+//  setName zset名称
+//  start 区间开始
+//  end  区间结束
+//  返回 删除的元素个数
+//  返回 err，可能的错误，操作成功返回 nil
+func (c *DbClient) ZRemRangeByScoreFloat(setName string, start, end ScoreBound) (count int64, err error) {
+	s, e, err := scoreBounds(start, end, 1)
+	if err != nil {
+		return 0, newCommandError([]interface{}{"zremrangebyscore", setName, start, end}, err)
+	}
+	resp, err := c.do("zremrangebyscore", setName, s, e)
+	if err != nil {
+		return 0, err
+	}
+	if len(resp) > 1 && resp[0] == "ok" {
+		return strconv.ParseInt(resp[1], 10, 64)
+	}
+	return 0, handError(resp, setName, start, end)
+}
+
+//  根据下标索引区间 [offset, offset + limit) 获取 key和score 数组对, 下标从 0 开始.
+//  setName zset名称
+//  offset 从此下标处开始返回. 从 0 开始.
+//  limit  最多返回这么多个 key-score 对.
+//  返回 keys 和 scores, 按权重从小到大排列
+//  返回 err，可能的错误，操作成功返回 nil
+func (c *DbClient) ZRangeSliceFloat(setName string, offset, limit int64) (keys []string, scores []float64, err error) {
+	return c.doKeyScores("zrange", setName, offset, limit)
+}
+
+//  根据下标索引区间 [offset, offset + limit) 获取 key和score 数组对, 反向顺序获取.
+//  setName zset名称
+//  offset 从此下标处开始返回. 从 0 开始.
+//  limit  最多返回这么多个 key-score 对.
+//  返回 keys 和 scores, 按权重从大到小排列
+//  返回 err，可能的错误，操作成功返回 nil
+func (c *DbClient) ZRRangeSliceFloat(setName string, offset, limit int64) (keys []string, scores []float64, err error) {
+	return c.doKeyScores("zrrange", setName, offset, limit)
+}
+
+//  执行返回 key-score 列表的命令
+func (c *DbClient) doKeyScores(args ...interface{}) (keys []string, scores []float64, err error) {
+	resp, err := c.do(args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return parseKeyScores(resp, args[1:]...)
+}
+
+//  从 zset 首部删除并返回 `limit` 个元素.
+//  setName zset名称
+//  limit 最多要删除并返回这么多个 key-score 对.
+//  返回 keys 和 scores, 按删除的顺序排列
+//  返回 err，可能的错误，操作成功返回 nil
+func (c *DbClient) ZPopFrontFloat(setName string, limit int64) (keys []string, scores []float64, err error) {
+	return c.doKeyScores("zpop_front", setName, limit)
+}
+
+//  从 zset 尾部删除并返回 `limit` 个元素.
+//  setName zset名称
+//  limit 最多要删除并返回这么多个 key-score 对.
+//  返回 keys 和 scores, 按删除的顺序排列
+//  返回 err，可能的错误，操作成功返回 nil
+func (c *DbClient) ZPopBackFloat(setName string, limit int64) (keys []string, scores []float64, err error) {
+	return c.doKeyScores("zpop_back", setName, limit)
+}
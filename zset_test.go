@@ -0,0 +1,116 @@
+package gossdb_client
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/houbin910902/gossdb_client/gossdb/ssdb"
+)
+
+func TestZScan(t *testing.T) {
+	db, _ := newTestClient(t)
+
+	if err := db.MultiZSet("z", map[string]int64{"a": 1, "b": 2, "c": 3}); err != nil {
+		t.Fatal(err)
+	}
+	keys, scores, err := db.ZScan("z", "", "", "", 10)
+	if err != nil || !reflect.DeepEqual(keys, []string{"a", "b", "c"}) || !reflect.DeepEqual(scores, []int64{1, 2, 3}) {
+		t.Fatalf("ZScan = %v, %v, %v", keys, scores, err)
+	}
+	keys, scores, err = db.ZrScan("z", "", "", "", 2)
+	if err != nil || !reflect.DeepEqual(keys, []string{"c", "b"}) || !reflect.DeepEqual(scores, []int64{3, 2}) {
+		t.Fatalf("ZrScan = %v, %v, %v", keys, scores, err)
+	}
+}
+
+func TestMultiZDel(t *testing.T) {
+	db, _ := newTestClient(t)
+
+	if err := db.MultiZSet("z", map[string]int64{"a": 1, "b": 2, "c": 3}); err != nil {
+		t.Fatal(err)
+	}
+	// zset 的名字和多个 key 都要发出
+	if err := db.MultiZDel("z", "a", "c", "missing"); err != nil {
+		t.Fatal(err)
+	}
+	keys, _, err := db.ZScan("z", "", "", "", 10)
+	if err != nil || !reflect.DeepEqual(keys, []string{"b"}) {
+		t.Fatalf("ZScan after MultiZDel = %v, %v", keys, err)
+	}
+}
+
+func TestZSetFloat(t *testing.T) {
+	db, _ := newTestClient(t)
+
+	err := db.MultiZSetFloat("z", map[string]float64{"a": 10, "b": 20, "c": 30, "d": -5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, err := db.ZGetFloat("z", "d"); err != nil || s != -5 {
+		t.Fatalf("ZGetFloat = %v, %v", s, err)
+	}
+	if s, err := db.ZIncRFloat("z", "a", 5); err != nil || s != 15 {
+		t.Fatalf("ZIncRFloat = %v, %v", s, err)
+	}
+
+	// 权重是 int64, 带小数或超出 int64 的权重不会发出
+	if err := db.ZSetFloat("z", "e", 98.5); !errors.Is(err, ssdb.ErrBadArguments) {
+		t.Errorf("ZSetFloat(98.5) err = %v", err)
+	}
+	if _, err := db.ZIncRFloat("z", "a", 0.5); !errors.Is(err, ssdb.ErrBadArguments) {
+		t.Errorf("ZIncRFloat(0.5) err = %v", err)
+	}
+	if err := db.MultiZSetFloat("z", map[string]float64{"e": 1, "f": 1e19}); !errors.Is(err, ssdb.ErrBadArguments) {
+		t.Errorf("MultiZSetFloat(1e19) err = %v", err)
+	}
+	if n, _ := db.ZSize("z"); n != 4 {
+		t.Errorf("ZSize = %d, want 4", n)
+	}
+	if _, err := db.ZCountFloat("z", Inclusive(math.NaN()), PosInf); !errors.Is(err, ssdb.ErrBadArguments) {
+		t.Errorf("ZCountFloat(NaN) err = %v", err)
+	}
+
+	tests := []struct {
+		start, end ScoreBound
+		want       []string
+	}{
+		{NegInf, PosInf, []string{"d", "a", "b", "c"}},
+		{Inclusive(15), Inclusive(30), []string{"a", "b", "c"}},
+		{Exclusive(15), Exclusive(30), []string{"b"}},
+		// 小数的边界向区间内部取整
+		{Inclusive(14.5), Inclusive(20.5), []string{"a", "b"}},
+		{Exclusive(14.5), Exclusive(19.5), []string{"a"}},
+		{Exclusive(20), PosInf, []string{"c"}},
+		{Inclusive(math.Inf(-1)), Inclusive(-1e300), []string{}},
+		{PosInf, PosInf, []string{}},
+	}
+	for _, tt := range tests {
+		keys, _, err := db.ZScanFloat("z", "", tt.start, tt.end, -1)
+		if err != nil || !reflect.DeepEqual(keys, tt.want) {
+			t.Errorf("ZScanFloat(%v, %v) = %v, %v, want %v", tt.start, tt.end, keys, err, tt.want)
+		}
+		if n, err := db.ZCountFloat("z", tt.start, tt.end); err != nil || n != int64(len(tt.want)) {
+			t.Errorf("ZCountFloat(%v, %v) = %d, %v", tt.start, tt.end, n, err)
+		}
+	}
+
+	keys, scores, err := db.ZrScanFloat("z", "", Exclusive(30), NegInf, 2)
+	if err != nil || !reflect.DeepEqual(keys, []string{"b", "a"}) || !reflect.DeepEqual(scores, []float64{20, 15}) {
+		t.Fatalf("ZrScanFloat = %v, %v, %v", keys, scores, err)
+	}
+	if sum, err := db.ZSumFloat("z", Inclusive(20), PosInf); err != nil || sum != 50 {
+		t.Fatalf("ZSumFloat = %v, %v", sum, err)
+	}
+	if avg, err := db.ZAvgFloat("z", Inclusive(15), Inclusive(20)); err != nil || avg != 17.5 {
+		t.Fatalf("ZAvgFloat = %v, %v", avg, err)
+	}
+	if n, err := db.ZRemRangeByScoreFloat("z", NegInf, Exclusive(20)); err != nil || n != 2 {
+		t.Fatalf("ZRemRangeByScoreFloat = %d, %v", n, err)
+	}
+	keys, scores, err = db.ZRRangeSliceFloat("z", 0, 10)
+	if err != nil || !reflect.DeepEqual(keys, []string{"c", "b"}) || !reflect.DeepEqual(scores, []float64{30, 20}) {
+		t.Fatalf("ZRRangeSliceFloat = %v, %v, %v", keys, scores, err)
+	}
+}